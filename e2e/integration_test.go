@@ -6,6 +6,7 @@ package e2e
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
@@ -350,7 +351,7 @@ func TestCreate_ChildEntity_ParentNotFound(t *testing.T) {
 	}
 
 	err := testStore.Create(ctx, studio, studioItem)
-	if err != store.ErrParentNotFound {
+	if !errors.Is(err, store.ErrParentNotFound) {
 		t.Errorf("expected ErrParentNotFound, got %v", err)
 	}
 }
@@ -374,7 +375,7 @@ func TestCreate_DuplicateEntity(t *testing.T) {
 
 	// Second create should fail
 	err := testStore.Create(ctx, org, item)
-	if err != store.ErrAlreadyExists {
+	if !errors.Is(err, store.ErrAlreadyExists) {
 		t.Errorf("expected ErrAlreadyExists, got %v", err)
 	}
 }
@@ -509,7 +510,7 @@ func TestUniqueConstraint_Enforced(t *testing.T) {
 	}
 
 	err := testStore.Create(ctx, studio2, studio2Item)
-	if err != store.ErrDuplicateValue {
+	if !errors.Is(err, store.ErrDuplicateValue) {
 		t.Errorf("expected ErrDuplicateValue, got %v", err)
 	}
 }
@@ -8,6 +8,7 @@ require (
 	github.com/aws/aws-sdk-go-v2/config v1.32.2
 	github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue v1.15.19
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.37.2
+	github.com/aws/smithy-go v1.23.2
 	github.com/google/uuid v1.6.0
 )
 
@@ -25,6 +26,5 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/sso v1.30.5 // indirect
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.35.10 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sts v1.41.2 // indirect
-	github.com/aws/smithy-go v1.23.2 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 )
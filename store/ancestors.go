@@ -0,0 +1,408 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxAncestorDepth bounds how many levels of parents are followed, so a
+// cycle of parent references fails instead of looping.
+const maxAncestorDepth = 64
+
+// maxRefCacheEntries bounds the ancestor cache. When it is full, expired
+// entries are evicted, and if none have expired the cache is cleared.
+const maxRefCacheEntries = 10000
+
+// refCache caches ancestor items by entity reference for a fixed TTL.
+// A nil *refCache caches nothing.
+type refCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]refCacheEntry
+}
+
+type refCacheEntry struct {
+	item    *Item
+	expires time.Time
+}
+
+// newRefCache returns a cache with the given TTL, or nil if ttl is not positive.
+func newRefCache(ttl time.Duration) *refCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &refCache{ttl: ttl, entries: make(map[string]refCacheEntry)}
+}
+
+func (c *refCache) get(ref string) (*Item, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[ref]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.item, true
+}
+
+func (c *refCache) put(ref string, item *Item) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxRefCacheEntries {
+		for ref, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, ref)
+			}
+		}
+		if len(c.entries) >= maxRefCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[ref] = refCacheEntry{item: item, expires: now.Add(c.ttl)}
+}
+
+func (c *refCache) forget(ref string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, ref)
+}
+
+// Ancestors returns the active ancestors of an entity, root first and
+// ending with its parent, e.g. for breadcrumbs. Ancestor references are
+// resolved to items with the registry (see Registry.RegisterTable).
+//
+// With Config.StorePath, the entity is read and the ancestors in its path
+// are fetched in one batch. Otherwise parent_ref is followed from the
+// entity's ParentRef with one read per level, switching to the stored path
+// of the first ancestor that has one. An ancestor that is missing or
+// deleted fails with ErrNotFound, wrapped with its reference.
+func (s *Store) Ancestors(ctx context.Context, entity Entity) ([]*Item, error) {
+	path, hasPath, parentRef, err := s.ancestry(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	if hasPath {
+		return s.pathItems(ctx, path)
+	}
+	return s.walkAncestors(ctx, parentRef)
+}
+
+// HasAncestor reports whether ancestorRef is an ancestor of entity. With a
+// stored path this is a single read of the entity; otherwise the ancestors
+// are read as in Ancestors.
+func (s *Store) HasAncestor(ctx context.Context, entity Entity, ancestorRef string) (bool, error) {
+	path, hasPath, parentRef, err := s.ancestry(ctx, entity)
+	if err != nil {
+		return false, err
+	}
+	if hasPath {
+		return slices.Contains(path, ancestorRef), nil
+	}
+	ancestors, err := s.walkAncestors(ctx, parentRef)
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(ancestors, func(item *Item) bool {
+		return item.EntityRef == ancestorRef
+	}), nil
+}
+
+// ancestry returns an entity's stored path if it has one, and otherwise
+// the reference of its parent to walk from.
+func (s *Store) ancestry(ctx context.Context, entity Entity) ([]string, bool, string, error) {
+	if !s.config.StorePath {
+		if checker, ok := entity.(ParentChecker); ok {
+			return nil, false, checker.ParentRef(), nil
+		}
+		return nil, false, "", nil
+	}
+
+	item, err := s.Get(ctx, entity.TableName(), entity.GetKey())
+	if err != nil {
+		return nil, false, "", err
+	}
+	path, ok := itemPath(item.Raw)
+	return path, ok, item.ParentRef, nil
+}
+
+// walkAncestors reads the ancestors from parentRef up to the root and
+// returns them root first.
+func (s *Store) walkAncestors(ctx context.Context, parentRef string) ([]*Item, error) {
+	var items []*Item
+	for ref := parentRef; ref != ""; {
+		if len(items) == maxAncestorDepth {
+			return nil, fmt.Errorf("trellis: ancestors of %s exceed %d levels", parentRef, maxAncestorDepth)
+		}
+		item, err := s.getRef(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if path, ok := itemPath(item.Raw); ok {
+			rest, err := s.pathItems(ctx, path)
+			if err != nil {
+				return nil, err
+			}
+			slices.Reverse(rest)
+			items = append(items, rest...)
+			break
+		}
+		ref = item.ParentRef
+	}
+	slices.Reverse(items)
+	return items, nil
+}
+
+// pathItems reads the items of a stored path, using the cache for those it
+// holds and one batch for the rest.
+func (s *Store) pathItems(ctx context.Context, path []string) ([]*Item, error) {
+	items := make([]*Item, len(path))
+	var keys []TableKey
+	var missing []int
+	for i, ref := range path {
+		if item, ok := s.ancestors.get(ref); ok {
+			items[i] = item
+			continue
+		}
+		key, err := s.resolveRef(ref)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		missing = append(missing, i)
+	}
+	if len(keys) == 0 {
+		return items, nil
+	}
+
+	fetched, err := s.BatchGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for j, item := range fetched {
+		ref := path[missing[j]]
+		if item == nil {
+			return nil, fmt.Errorf("ancestor %s: %w", ref, ErrNotFound)
+		}
+		s.ancestors.put(ref, item)
+		items[missing[j]] = item
+	}
+	return items, nil
+}
+
+// getRef reads the active item for an entity reference, through the cache.
+func (s *Store) getRef(ctx context.Context, ref string) (*Item, error) {
+	if item, ok := s.ancestors.get(ref); ok {
+		return item, nil
+	}
+	key, err := s.resolveRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	item, err := s.Get(ctx, key.TableName, key.Key)
+	if err != nil {
+		return nil, fmt.Errorf("ancestor %s: %w", ref, err)
+	}
+	s.ancestors.put(ref, item)
+	return item, nil
+}
+
+// resolveRef resolves an entity reference to its table and key with the
+// registry.
+func (s *Store) resolveRef(ref string) (TableKey, error) {
+	entityType, id, ok := strings.Cut(ref, "#")
+	if !ok || s.registry == nil {
+		return TableKey{}, fmt.Errorf("%w: %s", ErrUnknownEntityType, ref)
+	}
+	table, ok := s.registry.TableOf(entityType)
+	if !ok {
+		return TableKey{}, fmt.Errorf("%w: %s", ErrUnknownEntityType, ref)
+	}
+	return TableKey{
+		TableName: table.TableName,
+		Key:       PK{table.KeyAttr: &types.AttributeValueMemberS{Value: id}},
+	}, nil
+}
+
+// itemPath returns an item's stored path and whether it has one. A root
+// entity's path is empty.
+func itemPath(raw map[string]types.AttributeValue) ([]string, bool) {
+	if _, ok := raw["_path"].(*types.AttributeValueMemberL); !ok {
+		return nil, false
+	}
+	var path []string
+	if err := attributevalue.Unmarshal(raw["_path"], &path); err != nil {
+		return nil, false
+	}
+	if path == nil {
+		path = []string{}
+	}
+	return path, true
+}
+
+// pathValue marshals a path for storing in _path.
+func pathValue(path []string) (types.AttributeValue, error) {
+	list, err := attributevalue.MarshalList(path)
+	if err != nil {
+		return nil, fmt.Errorf("marshal path: %w", err)
+	}
+	return &types.AttributeValueMemberL{Value: list}, nil
+}
+
+// setPath sets the _path of a new entity's item when Config.StorePath is
+// set. A parent created in the same batch is looked up in staged by table
+// key, and has its own path set first.
+func (s *Store) setPath(ctx context.Context, entity Entity, item map[string]types.AttributeValue, staged map[string]CreateRequest) error {
+	if !s.config.StorePath {
+		return nil
+	}
+	return s.setPathDepth(ctx, entity, item, staged, 0)
+}
+
+func (s *Store) setPathDepth(ctx context.Context, entity Entity, item map[string]types.AttributeValue, staged map[string]CreateRequest, depth int) error {
+	if _, ok := itemPath(item); ok {
+		return nil
+	}
+	path, err := s.childPath(ctx, entity, staged, depth)
+	if err != nil {
+		return err
+	}
+	v, err := pathValue(path)
+	if err != nil {
+		return err
+	}
+	item["_path"] = v
+	return nil
+}
+
+// childPath returns the path of an entity under its parent: the parent's
+// path followed by the parent's reference. A parent created before paths
+// were stored has its ancestors walked instead.
+func (s *Store) childPath(ctx context.Context, entity Entity, staged map[string]CreateRequest, depth int) ([]string, error) {
+	checker, ok := entity.(ParentChecker)
+	if !ok || checker.ParentRef() == "" {
+		return []string{}, nil
+	}
+	if depth == maxAncestorDepth {
+		return nil, fmt.Errorf("trellis: ancestors of %s exceed %d levels", entity.EntityRef(), maxAncestorDepth)
+	}
+	parentRef := checker.ParentRef()
+
+	var key TableKey
+	if check := checker.ParentCheck(); check != nil {
+		key = TableKey{TableName: check.TableName, Key: check.Key}
+	} else {
+		var err error
+		if key, err = s.resolveRef(parentRef); err != nil {
+			return nil, err
+		}
+	}
+
+	if parent, ok := staged[tableKeyString(key.TableName, key.Key)]; ok {
+		if err := s.setPathDepth(ctx, parent.Entity, parent.Item, staged, depth+1); err != nil {
+			return nil, err
+		}
+		path, _ := itemPath(parent.Item)
+		return append(path, parentRef), nil
+	}
+
+	raw, err := s.getRaw(ctx, key.TableName, key.Key)
+	if errors.Is(err, ErrNotFound) || err == nil && IsDeleted(raw) {
+		return nil, ErrParentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	path, ok := itemPath(raw)
+	if !ok {
+		// The parent predates stored paths
+		ancestors, err := s.walkAncestors(ctx, s.unmarshalItem(raw).ParentRef)
+		if err != nil {
+			return nil, err
+		}
+		path = make([]string, 0, len(ancestors)+1)
+		for _, ancestor := range ancestors {
+			path = append(path, ancestor.EntityRef)
+		}
+	}
+	return append(path, parentRef), nil
+}
+
+// repath rewrites the stored paths of ref's descendants after ref moved to
+// path, level by level. Descendants without a stored path keep resolving
+// their ancestors by walking and are left alone.
+func (s *Store) repath(ctx context.Context, ref string, path []string) error {
+	type node struct {
+		child ChildRef
+		path  []string
+	}
+	parents := []node{{child: ChildRef{Ref: ref}, path: path}}
+	for depth := 0; len(parents) > 0; depth++ {
+		if depth == maxAncestorDepth {
+			return fmt.Errorf("trellis: descendants of %s exceed %d levels", ref, maxAncestorDepth)
+		}
+
+		var level []node
+		for _, parent := range parents {
+			childPath := append(slices.Clone(parent.path), parent.child.Ref)
+			for child, err := range s.QueryAllChildrenIter(ctx, parent.child.Ref) {
+				if err != nil {
+					return err
+				}
+				level = append(level, node{child: child, path: childPath})
+			}
+		}
+
+		err := forEachConcurrent(ctx, len(level), defaultCascadeConcurrency, func(ctx context.Context, i int) error {
+			s.ancestors.forget(level[i].child.Ref)
+			return s.setStoredPath(ctx, level[i].child.TableName, level[i].child.Key, level[i].path)
+		})
+		if err != nil {
+			return err
+		}
+		parents = level
+	}
+	return nil
+}
+
+// setStoredPath replaces an item's stored path, if it has one.
+func (s *Store) setStoredPath(ctx context.Context, table string, key PK, path []string) error {
+	v, err := pathValue(path)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(table),
+		Key:                       key,
+		UpdateExpression:          aws.String("SET #path = :path"),
+		ConditionExpression:       aws.String("attribute_exists(#path)"),
+		ExpressionAttributeNames:  map[string]string{"#path": "_path"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":path": v},
+	})
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,197 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+	"github.com/jacentio/trellis/storetest"
+)
+
+// newTreeStore returns a store over a seeded tree of nodes whose type is
+// registered for resolving ancestors.
+func newTreeStore(t *testing.T, cfg store.Config) (*store.Store, *storetest.DB) {
+	t.Helper()
+	s, db := newMemStore(t, cfg)
+	reg := store.NewRegistry()
+	reg.RegisterTable(store.EntityTable{EntityType: "node", TableName: "nodes"})
+	s.SetRegistry(reg)
+	seedTree(t, s)
+	return s, db
+}
+
+// countReads counts the read requests made to db.
+func countReads(db *storetest.DB) *int {
+	reads := new(int)
+	db.SetInterceptor(func(_ context.Context, op string, _ any) error {
+		if op == "GetItem" || op == "BatchGetItem" {
+			*reads++
+		}
+		return nil
+	})
+	return reads
+}
+
+func ancestorRefs(t *testing.T, s *store.Store, entity store.Entity) []string {
+	t.Helper()
+	items, err := s.Ancestors(context.Background(), entity)
+	if err != nil {
+		t.Fatalf("ancestors of %s: %v", entity.EntityRef(), err)
+	}
+	refs := make([]string, 0, len(items))
+	for _, item := range items {
+		refs = append(refs, item.EntityRef)
+	}
+	return refs
+}
+
+func TestAncestors(t *testing.T) {
+	for _, storePath := range []bool{false, true} {
+		cfg := store.DefaultConfig()
+		cfg.StorePath = storePath
+		s, _ := newTreeStore(t, cfg)
+		ctx := context.Background()
+
+		got := ancestorRefs(t, s, Node{ID: "a1x", ParentID: "a1"})
+		if want := []string{"node#root", "node#a", "node#a1"}; !slices.Equal(got, want) {
+			t.Errorf("path %v: expected %v, got %v", storePath, want, got)
+		}
+		if got := ancestorRefs(t, s, Node{ID: "root"}); len(got) != 0 {
+			t.Errorf("path %v: expected no ancestors of root, got %v", storePath, got)
+		}
+
+		for ref, want := range map[string]bool{"node#a": true, "node#root": true, "node#b": false, "node#a1x": false} {
+			got, err := s.HasAncestor(ctx, Node{ID: "a1x", ParentID: "a1"}, ref)
+			if err != nil || got != want {
+				t.Errorf("path %v: HasAncestor(%s) = %v, %v; expected %v", storePath, ref, got, err, want)
+			}
+		}
+
+		// A deleted ancestor is reported
+		if err := s.Delete(ctx, Node{ID: "a", ParentID: "root"}, store.DeleteOptions{}); err != nil {
+			t.Fatalf("delete a: %v", err)
+		}
+		if _, err := s.Ancestors(ctx, Node{ID: "a1", ParentID: "a"}); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("path %v: expected ErrNotFound, got %v", storePath, err)
+		}
+	}
+}
+
+func TestAncestors_UnknownType(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	seedTree(t, s)
+
+	_, err := s.Ancestors(context.Background(), Node{ID: "a1", ParentID: "a"})
+	if !errors.Is(err, store.ErrUnknownEntityType) {
+		t.Errorf("expected ErrUnknownEntityType, got %v", err)
+	}
+}
+
+func TestAncestors_StoredPath(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.StorePath = true
+	s, db := newTreeStore(t, cfg)
+	ctx := context.Background()
+
+	reads := countReads(db)
+	ok, err := s.HasAncestor(ctx, Node{ID: "a1x", ParentID: "a1"}, "node#a")
+	if err != nil || !ok {
+		t.Fatalf("expected node#a to be an ancestor, got %v, %v", ok, err)
+	}
+	if *reads != 1 {
+		t.Errorf("expected a single read, got %d", *reads)
+	}
+	db.SetInterceptor(nil)
+
+	// Paths follow the subtree when it moves
+	if err := s.Move(ctx, Node{ID: "a1", ParentID: "a"}, Node{ID: "a1", ParentID: "b", Slug: "a1"}, nil, 1); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	got := ancestorRefs(t, s, Node{ID: "a1x", ParentID: "a1"})
+	if want := []string{"node#root", "node#b", "node#a1"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v after move, got %v", want, got)
+	}
+
+	err = s.Move(ctx, Node{ID: "a1", ParentID: "b"}, Node{ID: "a1", ParentID: "a1x", Slug: "a1"}, nil, 2)
+	if !errors.Is(err, store.ErrInvalidMove) {
+		t.Errorf("expected ErrInvalidMove moving under a descendant, got %v", err)
+	}
+
+	// Children may be staged before their parents
+	err = s.Tx().
+		Create(Node{ID: "c1", ParentID: "c"}, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "c1"}}).
+		Create(Node{ID: "c", ParentID: "b"}, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "c"}}).
+		Commit(ctx)
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	item, err := s.Get(ctx, "nodes", Node{ID: "c1"}.GetKey())
+	if err != nil {
+		t.Fatalf("get c1: %v", err)
+	}
+	if want := []string{"node#root", "node#b", "node#c"}; !slices.Equal(item.Path, want) {
+		t.Errorf("expected path %v, got %v", want, item.Path)
+	}
+}
+
+func TestAncestors_LegacyParent(t *testing.T) {
+	s, db := newTreeStore(t, store.DefaultConfig())
+	cfg := store.DefaultConfig()
+	cfg.StorePath = true
+	withPath := store.New(db, cfg)
+	withPath.SetRegistry(s.Registry())
+	ctx := context.Background()
+
+	// The parent's ancestors are walked to start the path
+	item := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "new"}}
+	if err := withPath.Create(ctx, Node{ID: "new", ParentID: "a1x"}, item); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	got, err := withPath.Get(ctx, "nodes", Node{ID: "new"}.GetKey())
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if want := []string{"node#root", "node#a", "node#a1", "node#a1x"}; !slices.Equal(got.Path, want) {
+		t.Errorf("expected path %v, got %v", want, got.Path)
+	}
+}
+
+func TestAncestors_Cache(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.AncestorCacheTTL = time.Minute
+	s, db := newTreeStore(t, cfg)
+	ctx := context.Background()
+	a1x := Node{ID: "a1x", ParentID: "a1"}
+
+	reads := countReads(db)
+	ancestorRefs(t, s, a1x)
+	if *reads != 3 {
+		t.Fatalf("expected 3 reads, got %d", *reads)
+	}
+	ancestorRefs(t, s, a1x)
+	if *reads != 3 {
+		t.Errorf("expected cached ancestors, got %d more reads", *reads-3)
+	}
+
+	// Writes evict the entity written
+	item := map[string]types.AttributeValue{"slug": &types.AttributeValueMemberS{Value: "renamed"}}
+	if err := s.Update(ctx, Node{ID: "a", ParentID: "root"}, item, 1); err != nil {
+		t.Fatalf("update a: %v", err)
+	}
+	*reads = 0
+	items, err := s.Ancestors(ctx, a1x)
+	if err != nil {
+		t.Fatalf("ancestors: %v", err)
+	}
+	if *reads != 1 {
+		t.Errorf("expected 1 read after update, got %d", *reads)
+	}
+	if v, ok := items[1].Raw["slug"].(*types.AttributeValueMemberS); !ok || v.Value != "renamed" {
+		t.Errorf("expected the updated ancestor, got %v", items[1].Raw["slug"])
+	}
+}
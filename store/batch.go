@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// maxBatchGetKeys is DynamoDB's limit on keys per BatchGetItem call.
+	maxBatchGetKeys = 100
+
+	// batchMaxRetries is how many times unprocessed keys are retried.
+	batchMaxRetries = 8
+
+	// batchBaseDelay is the first retry delay; it doubles on each retry.
+	batchBaseDelay = 25 * time.Millisecond
+
+	// batchMaxDelay caps the retry delay.
+	batchMaxDelay = 2 * time.Second
+)
+
+// BatchGet retrieves multiple entities, possibly across tables, using
+// BatchGetItem.
+//
+// The result is aligned with keys: result[i] is the item for keys[i], or nil
+// if it is missing or deleted. Duplicate keys are fetched once. Keys are
+// requested in chunks of 100, and keys DynamoDB leaves unprocessed are
+// retried with exponential backoff; ErrUnprocessedKeys is returned if any
+// remain after the final retry.
+func (s *Store) BatchGet(ctx context.Context, keys []TableKey) ([]*Item, error) {
+	// Deduplicate keys, remembering every position each one was requested at
+	positions := make(map[string][]int, len(keys))
+	var unique []TableKey
+	for i, k := range keys {
+		id := tableKeyString(k.TableName, k.Key)
+		if _, ok := positions[id]; !ok {
+			unique = append(unique, k)
+		}
+		positions[id] = append(positions[id], i)
+	}
+
+	results := make([]*Item, len(keys))
+	for start := 0; start < len(unique); start += maxBatchGetKeys {
+		end := min(start+maxBatchGetKeys, len(unique))
+		err := s.batchGetChunk(ctx, unique[start:end], func(table string, raw map[string]types.AttributeValue, keyNames []string) {
+			if IsDeleted(raw) {
+				return
+			}
+			key := make(PK, len(keyNames))
+			for _, name := range keyNames {
+				key[name] = raw[name]
+			}
+			item := s.unmarshalItem(raw)
+			for _, i := range positions[tableKeyString(table, key)] {
+				results[i] = item
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// batchGetChunk fetches up to 100 keys, retrying unprocessed keys, and
+// passes every returned item to fn with the key attribute names of its table.
+func (s *Store) batchGetChunk(ctx context.Context, keys []TableKey, fn func(table string, raw map[string]types.AttributeValue, keyNames []string)) error {
+	request := make(map[string]types.KeysAndAttributes)
+	keyNames := make(map[string][]string)
+	for _, k := range keys {
+		ka := request[k.TableName]
+		ka.Keys = append(ka.Keys, k.Key)
+		request[k.TableName] = ka
+		if _, ok := keyNames[k.TableName]; !ok {
+			keyNames[k.TableName] = sortedKeyNames(k.Key)
+		}
+	}
+
+	delay := batchBaseDelay
+	for attempt := 0; ; attempt++ {
+		result, err := s.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: request})
+		if err != nil {
+			return err
+		}
+		for table, items := range result.Responses {
+			for _, raw := range items {
+				fn(table, raw, keyNames[table])
+			}
+		}
+
+		if len(result.UnprocessedKeys) == 0 {
+			return nil
+		}
+		if attempt == batchMaxRetries {
+			return fmt.Errorf("batch get: %w", ErrUnprocessedKeys)
+		}
+		request = result.UnprocessedKeys
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, batchMaxDelay)
+	}
+}
+
+// tableKeyString returns a canonical string identifying a key within a table.
+// Every component is quoted, so separators inside names or values cannot
+// make two keys collide.
+func tableKeyString(table string, key PK) string {
+	var b strings.Builder
+	b.WriteString(strconv.Quote(table))
+	for _, name := range sortedKeyNames(key) {
+		b.WriteString("|")
+		b.WriteString(strconv.Quote(name))
+		b.WriteString("=")
+		switch v := key[name].(type) {
+		case *types.AttributeValueMemberS:
+			b.WriteString("S:" + strconv.Quote(v.Value))
+		case *types.AttributeValueMemberN:
+			b.WriteString("N:" + strconv.Quote(v.Value))
+		case *types.AttributeValueMemberB:
+			b.WriteString("B:" + base64.StdEncoding.EncodeToString(v.Value))
+		default:
+			fmt.Fprintf(&b, "%T", v)
+		}
+	}
+	return b.String()
+}
+
+// sortedKeyNames returns the attribute names of a key in sorted order.
+func sortedKeyNames(key PK) []string {
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,104 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/jacentio/trellis/store"
+)
+
+func TestBatchGet_AlignsResultsWithKeys(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	seedChildren(t, s, 3)
+	if err := s.Delete(ctx, Child{ID: "c01"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	keys := []store.TableKey{
+		{TableName: "children", Key: Child{ID: "c00"}.GetKey()},
+		{TableName: "parents", Key: Parent{ID: "p1"}.GetKey()},
+		{TableName: "children", Key: Child{ID: "c01"}.GetKey()},     // deleted
+		{TableName: "children", Key: Child{ID: "missing"}.GetKey()}, // missing
+		{TableName: "children", Key: Child{ID: "c00"}.GetKey()},     // duplicate
+		{TableName: "children", Key: Child{ID: "c02"}.GetKey()},
+	}
+	items, err := s.BatchGet(ctx, keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != len(keys) {
+		t.Fatalf("expected %d results, got %d", len(keys), len(items))
+	}
+
+	want := []string{"child#c00", "parent#p1", "", "", "child#c00", "child#c02"}
+	for i, ref := range want {
+		got := ""
+		if items[i] != nil {
+			got = items[i].EntityRef
+		}
+		if got != ref {
+			t.Errorf("result %d: expected %q, got %q", i, ref, got)
+		}
+	}
+}
+
+func TestBatchGet_ChunksAndRetriesUnprocessed(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	seedChildren(t, s, 150)
+	db.SetBatchGetLimit(40)
+
+	var calls int
+	db.SetInterceptor(func(_ context.Context, op string, input any) error {
+		if op == "BatchGetItem" {
+			calls++
+			var n int
+			for _, ka := range input.(*dynamodb.BatchGetItemInput).RequestItems {
+				n += len(ka.Keys)
+			}
+			if n > 100 {
+				return fmt.Errorf("request has %d keys", n)
+			}
+		}
+		return nil
+	})
+
+	keys := make([]store.TableKey, 150)
+	for i := range keys {
+		keys[i] = store.TableKey{TableName: "children", Key: Child{ID: fmt.Sprintf("c%02d", i)}.GetKey()}
+	}
+	items, err := s.BatchGet(ctx, keys)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, item := range items {
+		if item == nil {
+			t.Errorf("result %d: expected item", i)
+		}
+	}
+	// 100 keys need 3 calls at 40 per call, the remaining 50 need 2
+	if calls != 5 {
+		t.Errorf("expected 5 BatchGetItem calls, got %d", calls)
+	}
+}
+
+func TestBatchGet_RetryHonoursContext(t *testing.T) {
+	client := &fakeClient{
+		batchGetItem: func(in *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+			return &dynamodb.BatchGetItemOutput{UnprocessedKeys: in.RequestItems}, nil
+		},
+	}
+	s := store.New(client, store.DefaultConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.BatchGet(ctx, []store.TableKey{{TableName: "parents", Key: Parent{ID: "p1"}.GetKey()}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected retry wait to honour context cancellation, got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// maxTransactItems is DynamoDB's limit on items per TransactWriteItems call.
+	maxTransactItems = 100
+
+	// maxTransactBytes is DynamoDB's limit on the total size of a transaction.
+	maxTransactBytes = 4 * 1024 * 1024
+)
+
+// errCreateTooLarge is returned for an entity whose create transaction alone
+// exceeds DynamoDB's transaction limits.
+var errCreateTooLarge = errors.New("trellis: create exceeds transaction limits")
+
+// CreateRequest is an entity and its attributes, as passed to Create.
+type CreateRequest struct {
+	Entity Entity
+	Item   map[string]types.AttributeValue
+}
+
+// BulkCreate creates many entities using as few transactions as possible.
+//
+// Each entity keeps its parent check, unique constraint puts and relationship
+// record in the same transaction, and entities are packed into transactions
+// in order within DynamoDB's 100-item and 4 MB limits. Entities sharing a
+// parent share a single parent check. An entity whose parent is created
+// earlier in the same batch is placed in a later transaction.
+//
+// The result is aligned with requests: result[i] is nil if requests[i] was
+// created, or the error Create would have returned (ErrParentNotFound,
+// ErrAlreadyExists, ErrDuplicateValue, ...). A failed condition is wrapped in
+// a *ConditionFailedError whose Index is the item's position in the shared
+// transaction. When a transaction is cancelled, the entities that caused it
+// fail and the rest are retried.
+func (s *Store) BulkCreate(ctx context.Context, requests []CreateRequest) []error {
+	errs := make([]error, len(requests))
+	plans := make([]*createPlan, len(requests))
+	now := time.Now()
+
+	// Entities created in the batch, for paths of their children
+	var staged map[string]CreateRequest
+	if s.config.StorePath {
+		staged = make(map[string]CreateRequest, len(requests))
+		for _, r := range requests {
+			staged[tableKeyString(r.Entity.TableName(), r.Entity.GetKey())] = r
+		}
+	}
+
+	var pending []int
+	for i, r := range requests {
+		if err := s.setPath(ctx, r.Entity, r.Item, staged); err != nil {
+			errs[i] = err
+			continue
+		}
+		plan, err := s.buildCreate(r.Entity, r.Item, now)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		plans[i] = plan
+		pending = append(pending, i)
+	}
+
+	for len(pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			for _, i := range pending {
+				errs[i] = err
+			}
+			break
+		}
+
+		group := newCreateGroup()
+		n := 0
+		for n < len(pending) && group.add(pending[n], plans[pending[n]]) {
+			n++
+		}
+		if n == 0 {
+			errs[pending[0]] = errCreateTooLarge
+			pending = pending[1:]
+			continue
+		}
+
+		retry := s.commitCreateGroup(ctx, group, errs)
+		pending = append(retry, pending[n:]...)
+	}
+
+	return errs
+}
+
+// commitCreateGroup executes a group's transaction and records per-entity
+// results in errs. It returns the members to retry after a cancellation.
+func (s *Store) commitCreateGroup(ctx context.Context, g *createGroup, errs []error) []int {
+	_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: g.items,
+	})
+	if err == nil {
+		for _, i := range g.members {
+			errs[i] = nil
+		}
+		return nil
+	}
+
+	var txErr *types.TransactionCanceledException
+	if errors.As(err, &txErr) {
+		failed := make(map[int]bool)
+		for idx, reason := range txErr.CancellationReasons {
+			if idx >= len(g.items) || g.kinds[idx] == nil || reason.Code == nil || *reason.Code != "ConditionalCheckFailed" {
+				continue
+			}
+			for _, i := range g.owners[idx] {
+				if !failed[i] {
+					failed[i] = true
+					errs[i] = newConditionFailedError(idx, g.items[idx], g.kinds[idx])
+				}
+			}
+		}
+		if len(failed) > 0 {
+			var retry []int
+			for _, i := range g.members {
+				if !failed[i] {
+					retry = append(retry, i)
+				}
+			}
+			return retry
+		}
+	}
+
+	for _, i := range g.members {
+		errs[i] = err
+	}
+	return nil
+}
+
+// createGroup accumulates create plans into a single transaction.
+type createGroup struct {
+	items   []types.TransactWriteItem
+	members []int
+	size    int
+
+	// targets maps each touched table item to its transaction index.
+	targets map[string]int
+
+	// owners and kinds describe each transaction index: the requests that
+	// depend on it, and the error a failed condition maps to.
+	owners [][]int
+	kinds  []error
+}
+
+func newCreateGroup() *createGroup {
+	return &createGroup{targets: make(map[string]int)}
+}
+
+// add adds a plan to the group, reusing an identical parent check already
+// in the group. It returns false, leaving the group unchanged, if the plan
+// would exceed transaction limits or touch an item already in the group.
+func (g *createGroup) add(member int, plan *createPlan) bool {
+	shared := -1
+	newItems, newSize := 0, 0
+	for idx, target := range plan.targets {
+		existing, ok := g.targets[target]
+		if !ok {
+			newItems++
+			newSize += transactItemSize(plan.items[idx])
+			continue
+		}
+		if idx != plan.parentCheckIndex || !sameParentCheck(g.items[existing], plan.items[idx]) {
+			return false
+		}
+		shared = existing
+	}
+	if len(g.items)+newItems > maxTransactItems || g.size+newSize > maxTransactBytes {
+		return false
+	}
+
+	for idx, txItem := range plan.items {
+		if idx == plan.parentCheckIndex && shared >= 0 {
+			if txItem.Update != nil {
+				// Count this child too
+				g.items[shared] = withChildCount(g.items[shared], 1)
+			}
+			g.owners[shared] = append(g.owners[shared], member)
+			continue
+		}
+		g.targets[plan.targets[idx]] = len(g.items)
+		g.items = append(g.items, txItem)
+		g.owners = append(g.owners, []int{member})
+		g.kinds = append(g.kinds, plan.failures[idx])
+	}
+	g.members = append(g.members, member)
+	g.size += newSize
+	return true
+}
+
+// sameParentCheck reports whether two parent checks have the same condition.
+// With child counts tracked, parent checks are updates of the count.
+func sameParentCheck(a, b types.TransactWriteItem) bool {
+	switch {
+	case a.ConditionCheck != nil && b.ConditionCheck != nil:
+		return aws.ToString(a.ConditionCheck.ConditionExpression) == aws.ToString(b.ConditionCheck.ConditionExpression)
+	case a.Update != nil && b.Update != nil:
+		return aws.ToString(a.Update.ConditionExpression) == aws.ToString(b.Update.ConditionExpression)
+	}
+	return false
+}
+
+// transactItemSize estimates the request size of a transaction item.
+func transactItemSize(item types.TransactWriteItem) int {
+	switch {
+	case item.Put != nil:
+		return itemSize(item.Put.Item) + len(*item.Put.TableName)
+	case item.ConditionCheck != nil:
+		return itemSize(item.ConditionCheck.Key) + len(*item.ConditionCheck.TableName)
+	case item.Update != nil:
+		return itemSize(item.Update.Key) + itemSize(item.Update.ExpressionAttributeValues) + len(*item.Update.TableName)
+	case item.Delete != nil:
+		return itemSize(item.Delete.Key) + len(*item.Delete.TableName)
+	}
+	return 0
+}
+
+// itemSize estimates the size of an item as DynamoDB measures it: attribute
+// names plus values.
+func itemSize(item map[string]types.AttributeValue) int {
+	size := 0
+	for name, v := range item {
+		size += len(name) + attributeSize(v)
+	}
+	return size
+}
+
+// attributeSize estimates the size of an attribute value.
+func attributeSize(v types.AttributeValue) int {
+	switch tv := v.(type) {
+	case *types.AttributeValueMemberS:
+		return len(tv.Value)
+	case *types.AttributeValueMemberN:
+		return len(tv.Value)
+	case *types.AttributeValueMemberB:
+		return len(tv.Value)
+	case *types.AttributeValueMemberSS:
+		size := 0
+		for _, s := range tv.Value {
+			size += len(s)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		size := 0
+		for _, n := range tv.Value {
+			size += len(n)
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		size := 0
+		for _, b := range tv.Value {
+			size += len(b)
+		}
+		return size
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, e := range tv.Value {
+			size += 1 + attributeSize(e)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(tv.Value)
+	}
+	return 1
+}
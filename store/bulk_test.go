@@ -0,0 +1,148 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+	"github.com/jacentio/trellis/storetest"
+)
+
+// countTransactions counts TransactWriteItems calls and rejects oversized ones.
+func countTransactions(db *storetest.DB) *int {
+	var n int
+	db.SetInterceptor(func(_ context.Context, op string, input any) error {
+		if op == "TransactWriteItems" {
+			n++
+			if items := len(input.(*dynamodb.TransactWriteItemsInput).TransactItems); items > 100 {
+				return fmt.Errorf("transaction has %d items", items)
+			}
+		}
+		return nil
+	})
+	return &n
+}
+
+func TestBulkCreate_PerEntityResults(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+
+	requests := []store.CreateRequest{
+		{Entity: Parent{ID: "p2"}, Item: makeTestItem("p2", "New parent")},
+		{Entity: Child{ID: "c1", ParentID: "p1"}, Item: makeTestItem("c1", "c1")},
+		{Entity: Child{ID: "c2", ParentID: "p2"}, Item: makeTestItem("c2", "parent in batch")},
+		{Entity: Child{ID: "c3", ParentID: "missing"}, Item: makeTestItem("c3", "orphan")},
+		{Entity: UniqueChild{ID: "u1", ParentID: "p1", Name: "x", Slug: "u1"}, Item: makeTestItem("u1", "x")},
+		{Entity: UniqueChild{ID: "u2", ParentID: "p1", Name: "x", Slug: "u2"}, Item: makeTestItem("u2", "x")},
+		{Entity: Parent{ID: "p1"}, Item: makeTestItem("p1", "exists")},
+		{Entity: Child{ID: "c1", ParentID: "p1"}, Item: makeTestItem("c1", "duplicate in batch")},
+	}
+	errs := s.BulkCreate(ctx, requests)
+
+	want := []error{nil, nil, nil, store.ErrParentNotFound, nil, store.ErrDuplicateValue, store.ErrAlreadyExists, store.ErrAlreadyExists}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(errs))
+	}
+	for i := range want {
+		if !errors.Is(errs[i], want[i]) || (want[i] == nil && errs[i] != nil) {
+			t.Errorf("request %d: expected %v, got %v", i, want[i], errs[i])
+		}
+		var cfe *store.ConditionFailedError
+		if want[i] != nil && !errors.As(errs[i], &cfe) {
+			t.Errorf("request %d: expected a ConditionFailedError, got %#v", i, errs[i])
+		}
+	}
+	var cfe *store.ConditionFailedError
+	if errors.As(errs[3], &cfe) && (cfe.Op != "ConditionCheck" || cfe.TableName != "parents") {
+		t.Errorf("expected the parent check on parents to fail, got %v", cfe)
+	}
+
+	for _, id := range []string{"c1", "c2", "u1"} {
+		table := "children"
+		if id == "u1" {
+			table = "unique_children"
+		}
+		if _, err := s.Get(ctx, table, Child{ID: id}.GetKey()); err != nil {
+			t.Errorf("expected %s to be created, got %v", id, err)
+		}
+	}
+	if _, err := s.Get(ctx, "unique_children", Child{ID: "u2"}.GetKey()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected u2 not to be created, got %v", err)
+	}
+}
+
+func TestBulkCreate_SharesParentCheckAndPacks(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	calls := countTransactions(db)
+
+	requests := make([]store.CreateRequest, 60)
+	for i := range requests {
+		id := fmt.Sprintf("c%02d", i)
+		requests[i] = store.CreateRequest{Entity: Child{ID: id, ParentID: "p1"}, Item: makeTestItem(id, id)}
+	}
+	for i, err := range s.BulkCreate(ctx, requests) {
+		if err != nil {
+			t.Errorf("request %d: unexpected error: %v", i, err)
+		}
+	}
+
+	// One shared parent check plus two puts per child: 49 children per transaction
+	if *calls != 2 {
+		t.Errorf("expected 2 transactions, got %d", *calls)
+	}
+	children, err := s.QueryAllChildren(ctx, "parent#p1")
+	if err != nil || len(children) != 60 {
+		t.Errorf("expected 60 children, got %d (err %v)", len(children), err)
+	}
+}
+
+func TestBulkCreate_RespectsSizeLimit(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	calls := countTransactions(db)
+
+	big := strings.Repeat("x", 1500*1024)
+	requests := make([]store.CreateRequest, 3)
+	for i := range requests {
+		id := fmt.Sprintf("p%d", i)
+		item := makeTestItem(id, id)
+		item["blob"] = &types.AttributeValueMemberS{Value: big}
+		requests[i] = store.CreateRequest{Entity: Parent{ID: id}, Item: item}
+	}
+	for i, err := range s.BulkCreate(context.Background(), requests) {
+		if err != nil {
+			t.Errorf("request %d: unexpected error: %v", i, err)
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("expected 2 transactions, got %d", *calls)
+	}
+}
+
+func TestBulkCreate_TransportError(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	boom := errors.New("boom")
+	db.SetInterceptor(func(context.Context, string, any) error { return boom })
+
+	errs := s.BulkCreate(context.Background(), []store.CreateRequest{
+		{Entity: Parent{ID: "p1"}, Item: makeTestItem("p1", "a")},
+		{Entity: Parent{ID: "p2"}, Item: makeTestItem("p2", "b")},
+	})
+	for i, err := range errs {
+		if !errors.Is(err, boom) {
+			t.Errorf("request %d: expected transport error, got %v", i, err)
+		}
+	}
+}
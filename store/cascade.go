@@ -0,0 +1,252 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// defaultCascadeConcurrency is the default number of parallel requests in a
+// synchronous cascade.
+const defaultCascadeConcurrency = 8
+
+// CascadeSummary describes what a cascading CascadeDelete or Purge deleted.
+// For CascadeDelete, items that were already deleted are not counted.
+type CascadeSummary struct {
+	// Entities is the number of entities deleted, including the root.
+	Entities int
+
+	// Relationships is the number of relationship records deleted.
+	Relationships int
+
+	// UniqueConstraints is the number of unique constraint records deleted.
+	UniqueConstraints int
+
+	// Depth is the number of levels of descendants found below the root.
+	Depth int
+}
+
+// cascadeNode is an entity reached by a synchronous cascade.
+type cascadeNode struct {
+	table string
+	key   PK
+
+	// parent, if set, has its child count decremented with the delete.
+	// Only the root's is; descendants stay counted on their deleted parents.
+	parent *ConditionCheck
+}
+
+// CascadeDelete deletes an entity and all of its descendants without a
+// stream handler, doing what stream.Handler does for each level in turn.
+//
+// The relationship table is walked first, so if the tree exceeds
+// opts.MaxDepth or opts.MaxItems nothing is deleted and ErrCascadeLimit is
+// returned. Then the entity, its descendants, their relationship records and
+// their unique constraints are given the same TTL, parents before children,
+// with at most opts.Concurrency requests in flight. On error or context
+// cancellation the summary reports what was deleted so far; calling
+// CascadeDelete again completes the cascade, unless opts.Strict is set, in
+// which case it returns ErrAlreadyDeleted. A missing entity always returns
+// ErrNotFound.
+func (s *Store) CascadeDelete(ctx context.Context, entity Entity, opts DeleteOptions) (*CascadeSummary, error) {
+	summary := &CascadeSummary{}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultCascadeConcurrency
+	}
+
+	levels, err := s.cascadeLevels(ctx, entity.EntityRef(), opts.MaxDepth, opts.MaxItems, concurrency)
+	if err != nil {
+		return summary, err
+	}
+	summary.Depth = len(levels)
+
+	now := time.Now()
+	var mu sync.Mutex
+	add := func(entities, relationships, uniques int) {
+		mu.Lock()
+		defer mu.Unlock()
+		summary.Entities += entities
+		summary.Relationships += relationships
+		summary.UniqueConstraints += uniques
+	}
+
+	root := cascadeNode{table: entity.TableName(), key: entity.GetKey(), parent: s.countedParent(entity)}
+	ttl, err := s.cascadeNode(ctx, root, now.Add(opts.Retention).Unix(), now, opts.ExpectedVersion, add)
+	if err != nil {
+		return summary, err
+	}
+	if opts.Strict && summary.Entities == 0 {
+		// The root was already deleted
+		return summary, ErrAlreadyDeleted
+	}
+
+	for _, level := range levels {
+		err := forEachConcurrent(ctx, len(level), concurrency, func(ctx context.Context, i int) error {
+			_, err := s.cascadeNode(ctx, level[i], ttl, now, 0, add)
+			if errors.Is(err, ErrNotFound) {
+				// Already purged
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, nil
+}
+
+// cascadeLevels walks the relationship table below entityRef and returns the
+// descendants grouped by depth. It returns ErrCascadeLimit if there are more
+// than maxDepth levels or maxItems descendants (0 = unlimited).
+func (s *Store) cascadeLevels(ctx context.Context, entityRef string, maxDepth, maxItems, concurrency int) ([][]cascadeNode, error) {
+	var levels [][]cascadeNode
+	parents := []string{entityRef}
+	total := 0
+
+	for len(parents) > 0 {
+		children := make([][]ChildRef, len(parents))
+		err := forEachConcurrent(ctx, len(parents), concurrency, func(ctx context.Context, i int) error {
+			for child, err := range s.QueryAllChildrenIter(ctx, parents[i]) {
+				if err != nil {
+					return fmt.Errorf("query children of %s: %w", parents[i], err)
+				}
+				children[i] = append(children[i], child)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var level []cascadeNode
+		var next []string
+		for _, refs := range children {
+			for _, child := range refs {
+				level = append(level, cascadeNode{table: child.TableName, key: child.Key})
+				next = append(next, child.Ref)
+			}
+		}
+		if len(level) == 0 {
+			break
+		}
+
+		total += len(level)
+		if maxDepth > 0 && len(levels)+1 > maxDepth {
+			return nil, fmt.Errorf("%w: deeper than %d levels", ErrCascadeLimit, maxDepth)
+		}
+		if maxItems > 0 && total > maxItems {
+			return nil, fmt.Errorf("%w: more than %d descendants", ErrCascadeLimit, maxItems)
+		}
+		levels = append(levels, level)
+		parents = next
+	}
+
+	return levels, nil
+}
+
+// cascadeNode marks one entity as deleted with ttl, unless it already is, and
+// applies its TTL to its relationship record and unique constraints. It
+// returns the entity's TTL, which is its existing one if already deleted,
+// ErrNotFound if the entity does not exist, or ErrConcurrentModification if
+// expectedVersion is set and does not match.
+func (s *Store) cascadeNode(ctx context.Context, node cascadeNode, ttl int64, now time.Time, expectedVersion int64, add func(entities, relationships, uniques int)) (int64, error) {
+	update := s.softDeleteUpdate(node.table, node.key, ttl, now, expectedVersion, false)
+	raw, deleted, err := s.markDeleted(ctx, update, node.parent, nil)
+	if err == nil && deleted && raw == nil {
+		raw, err = s.getRaw(ctx, node.table, node.key)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if raw == nil {
+		return 0, ErrNotFound
+	}
+	if !deleted {
+		existing, ok := itemTTL(raw)
+		if !ok {
+			return 0, ErrConcurrentModification
+		}
+		ttl = existing
+	}
+
+	item := s.unmarshalItem(raw)
+	entities, relationships, uniques := 0, 0, 0
+	if deleted {
+		entities++
+	}
+	if item.ParentRef != "" {
+		changed, err := s.setRelationshipTTL(ctx, item.EntityRef, item.ParentRef, ttl)
+		if err != nil {
+			return 0, fmt.Errorf("set relationship TTL for %s: %w", item.EntityRef, err)
+		}
+		if changed {
+			relationships++
+		}
+	}
+	if pks, ok := raw["_unique_pks"].(*types.AttributeValueMemberL); ok {
+		for _, v := range pks.Value {
+			pk, ok := v.(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			changed, err := s.setUniqueConstraintTTL(ctx, pk.Value, ttl)
+			if err != nil {
+				return 0, fmt.Errorf("set unique constraint TTL for %s: %w", item.EntityRef, err)
+			}
+			if changed {
+				uniques++
+			}
+		}
+	}
+	add(entities, relationships, uniques)
+	return ttl, nil
+}
+
+// forEachConcurrent calls fn for 0..n-1 with at most concurrency calls in
+// flight. It stops starting new calls after the first error or when ctx is
+// done, waits for running calls, and returns the first error.
+func forEachConcurrent(ctx context.Context, n, concurrency int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	sem := make(chan struct{}, concurrency)
+
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,189 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+)
+
+// Node is a self-referencing test entity for multi-level trees.
+type Node struct {
+	ID       string
+	ParentID string
+	Slug     string
+}
+
+func (n Node) TableName() string  { return "nodes" }
+func (n Node) EntityType() string { return "node" }
+func (n Node) EntityRef() string  { return "node#" + n.ID }
+func (n Node) GetKey() store.PK {
+	return store.PK{"id": &types.AttributeValueMemberS{Value: n.ID}}
+}
+
+func (n Node) ParentRef() string {
+	if n.ParentID == "" {
+		return ""
+	}
+	return "node#" + n.ParentID
+}
+
+func (n Node) ParentCheck() *store.ConditionCheck {
+	if n.ParentID == "" {
+		return nil
+	}
+	return &store.ConditionCheck{
+		TableName: "nodes",
+		Key:       store.PK{"id": &types.AttributeValueMemberS{Value: n.ParentID}},
+	}
+}
+
+func (n Node) UniqueFields() map[string]string {
+	if n.Slug == "" {
+		return nil
+	}
+	return map[string]string{"slug": n.Slug}
+}
+
+// seedTree creates root -> a, b; a -> a1, a2; a1 -> a1x, each with a slug
+// except the root.
+func seedTree(t *testing.T, s *store.Store) {
+	t.Helper()
+	nodes := []Node{
+		{ID: "root"},
+		{ID: "a", ParentID: "root", Slug: "a"},
+		{ID: "b", ParentID: "root", Slug: "b"},
+		{ID: "a1", ParentID: "a", Slug: "a1"},
+		{ID: "a2", ParentID: "a", Slug: "a2"},
+		{ID: "a1x", ParentID: "a1", Slug: "a1x"},
+	}
+	for _, n := range nodes {
+		item := map[string]types.AttributeValue{
+			"id":   &types.AttributeValueMemberS{Value: n.ID},
+			"slug": &types.AttributeValueMemberS{Value: n.Slug},
+		}
+		if err := s.Create(context.Background(), n, item); err != nil {
+			t.Fatalf("create %s: %v", n.ID, err)
+		}
+	}
+}
+
+func TestCascadeDelete_Tree(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 4
+	s, db := newMemStore(t, cfg)
+	ctx := context.Background()
+	seedTree(t, s)
+
+	_, err := s.CascadeDelete(ctx, Node{ID: "root"}, store.DeleteOptions{ExpectedVersion: 2})
+	if !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification for stale version, got %v", err)
+	}
+
+	summary, err := s.CascadeDelete(ctx, Node{ID: "root"}, store.DeleteOptions{Concurrency: 2, ExpectedVersion: 1})
+	if err != nil {
+		t.Fatalf("cascade delete: %v", err)
+	}
+	want := store.CascadeSummary{Entities: 6, Relationships: 5, UniqueConstraints: 5, Depth: 3}
+	if *summary != want {
+		t.Errorf("expected summary %+v, got %+v", want, *summary)
+	}
+
+	rootTTL := db.Item("nodes", Node{ID: "root"}.GetKey())["ttl"].(*types.AttributeValueMemberN).Value
+	for _, table := range []string{"nodes", cfg.RelationshipTable, cfg.UniqueTable} {
+		for _, item := range db.Items(table) {
+			if !store.IsDeleted(item) {
+				t.Errorf("expected %s item %v to be deleted", table, item)
+			}
+			if ttl := item["ttl"].(*types.AttributeValueMemberN).Value; ttl != rootTTL {
+				t.Errorf("expected TTL %s, got %s", rootTTL, ttl)
+			}
+		}
+	}
+
+	// Running again finds nothing left to delete
+	summary, err = s.CascadeDelete(ctx, Node{ID: "root"}, store.DeleteOptions{})
+	if err != nil {
+		t.Fatalf("repeat cascade delete: %v", err)
+	}
+	if summary.Entities != 0 || summary.Relationships != 0 || summary.UniqueConstraints != 0 {
+		t.Errorf("expected nothing deleted on repeat, got %+v", summary)
+	}
+
+	if _, err := s.CascadeDelete(ctx, Node{ID: "missing"}, store.DeleteOptions{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestCascadeDelete_Limits(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	seedTree(t, s)
+
+	for _, opts := range []store.DeleteOptions{
+		{Sync: true, MaxDepth: 2},
+		{Sync: true, MaxItems: 4},
+	} {
+		if err := s.Delete(ctx, Node{ID: "root"}, opts); !errors.Is(err, store.ErrCascadeLimit) {
+			t.Errorf("%+v: expected ErrCascadeLimit, got %v", opts, err)
+		}
+	}
+	for _, item := range db.Items("nodes") {
+		if store.IsDeleted(item) {
+			t.Errorf("expected nothing deleted when over limit, got %v", item["id"])
+		}
+	}
+
+	// Subtrees within the limits can be deleted
+	if err := s.Delete(ctx, Node{ID: "a1"}, store.DeleteOptions{Sync: true, MaxDepth: 1, MaxItems: 1}); err != nil {
+		t.Fatalf("delete a1: %v", err)
+	}
+	if _, err := s.Get(ctx, "nodes", Node{ID: "a1x"}.GetKey()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected a1x to be deleted, got %v", err)
+	}
+}
+
+func TestCascadeDelete_RetentionAndRestore(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	seedTree(t, s)
+
+	if err := s.Delete(ctx, Node{ID: "a"}, store.DeleteOptions{Sync: true, Retention: time.Hour}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if trash := listTrash(t, s, "nodes"); len(trash) != 4 {
+		t.Fatalf("expected 4 nodes in trash, got %d", len(trash))
+	}
+
+	if err := s.Restore(ctx, Node{ID: "a", ParentID: "root"}, 2, store.RestoreOptions{Subtree: true}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if trash := listTrash(t, s, "nodes"); len(trash) != 0 {
+		t.Errorf("expected empty trash after restore, got %d", len(trash))
+	}
+}
+
+func TestCascadeDelete_ContextCancelled(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	seedTree(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	db.SetInterceptor(func(_ context.Context, op string, _ any) error {
+		if op == "UpdateItem" {
+			cancel()
+		}
+		return nil
+	})
+
+	summary, err := s.CascadeDelete(ctx, Node{ID: "root"}, store.DeleteOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if summary.Entities > 1 {
+		t.Errorf("expected cascade to stop after cancellation, got %+v", summary)
+	}
+}
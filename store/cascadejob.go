@@ -0,0 +1,365 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// cascadeJobAttr stamps the entities of a tracked cascade that are yet to be
+// processed by the stream handler with the reference of the cascade's root,
+// which identifies its job.
+const cascadeJobAttr = "_cascade_job"
+
+// cascadeJobRetention is how long a finished job is kept before DynamoDB
+// expires it.
+const cascadeJobRetention = 7 * 24 * time.Hour
+
+// CascadeJobStatus is the state of a cascade job.
+type CascadeJobStatus string
+
+const (
+	// CascadeRunning means descendants of the root are still to be
+	// processed by the stream handler.
+	CascadeRunning CascadeJobStatus = "running"
+
+	// CascadeCompleted means the whole subtree has been deleted.
+	CascadeCompleted CascadeJobStatus = "completed"
+
+	// CascadeFailed means the cascade finished, but some TTL writes were
+	// abandoned under stream.LogAndContinue; see CascadeJob.Failures.
+	CascadeFailed CascadeJobStatus = "failed"
+)
+
+// CascadeJob tracks the cascade of a Delete with Config.TrackCascadeJobs.
+type CascadeJob struct {
+	// EntityRef is the reference of the deleted root.
+	EntityRef string
+
+	// Status is the state of the cascade.
+	Status CascadeJobStatus
+
+	// StartedAt is when the root was deleted.
+	StartedAt time.Time
+
+	// FinishedAt is when the last entity of the subtree was processed.
+	// Zero while the cascade is running.
+	FinishedAt time.Time
+
+	// ChildrenProcessed is the number of descendants deleted so far.
+	ChildrenProcessed int64
+
+	// Failures is the number of TTL writes abandoned under
+	// stream.LogAndContinue.
+	Failures int64
+
+	// LastError describes the most recent failed TTL write, including
+	// those retried under stream.FailRecord.
+	LastError string
+
+	// Pending is the number of deleted entities, the root included, that
+	// the stream handler has yet to process.
+	Pending int64
+
+	// Notified reports whether the handler's completion hook has run.
+	Notified bool
+}
+
+// CascadeEntity identifies an entity processed by a cascade.
+type CascadeEntity struct {
+	TableName string
+	Key       PK
+}
+
+// CascadeJobUpdate is the progress a stream handler records on a job.
+type CascadeJobUpdate struct {
+	// Pending is added to the job's pending count: the children about to
+	// be deleted before they are, and -1 for an entity done.
+	Pending int64
+
+	// ChildrenProcessed is added to the job's count of deleted descendants.
+	ChildrenProcessed int64
+
+	// Failures is added to the job's count of abandoned TTL writes.
+	Failures int64
+
+	// LastError, if set, replaces the job's last error.
+	LastError string
+
+	// Done, if set, is an entity of the job that has been processed. Its
+	// stamp is removed in the same transaction as the update, so an entity
+	// is only counted as done once; the update is skipped if it already
+	// was.
+	Done *CascadeEntity
+}
+
+// CascadeStatus returns the job of the cascade of entityRef, or ErrNotFound
+// if none is recorded: Config.TrackCascadeJobs is off, the entity was not
+// deleted with DeleteOptions.Cascade, it was restored, or the job expired
+// after it finished.
+func (s *Store) CascadeStatus(ctx context.Context, entityRef string) (*CascadeJob, error) {
+	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.config.CascadeJobTable),
+		Key:            s.cascadeJobKey(entityRef),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrNotFound
+	}
+	return unmarshalCascadeJob(result.Item), nil
+}
+
+// SetCascadeTTL sets ttl on an entity like SetTTLByKey, and stamps it as
+// part of the job of the cascade of jobRef. It reports whether the entity
+// was deleted by this call, and if it already had a TTL, whether it is
+// stamped as part of the same job, such as by an earlier try whose response
+// was lost. An entity deleted otherwise is neither.
+func (s *Store) SetCascadeTTL(ctx context.Context, table string, key PK, ttl int64, jobRef string) (deleted, stamped bool, err error) {
+	_, err = s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           aws.String(table),
+		Key:                                 key,
+		UpdateExpression:                    aws.String("SET #ttl = :ttl, #deleted_at = :now, #version = #version + :one, #job = :job"),
+		ConditionExpression:                 aws.String("attribute_not_exists(#ttl)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		ExpressionAttributeNames: map[string]string{
+			"#ttl":        "ttl",
+			"#deleted_at": "deleted_at",
+			"#version":    "version",
+			"#job":        cascadeJobAttr,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ttl": &types.AttributeValueMemberN{Value: strconv.FormatInt(ttl, 10)},
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+			":one": &types.AttributeValueMemberN{Value: "1"},
+			":job": &types.AttributeValueMemberS{Value: jobRef},
+		},
+	})
+
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		v, ok := condErr.Item[cascadeJobAttr].(*types.AttributeValueMemberS)
+		return false, ok && v.Value == jobRef, nil
+	}
+	return err == nil, false, err
+}
+
+// UpdateCascadeJob records a stream handler's progress on the job of the
+// cascade of jobRef and returns the job. Once no entities are pending, the
+// job is marked finished: CascadeFailed if any TTL writes were abandoned,
+// CascadeCompleted otherwise. It fails with ErrNotFound if the job does not
+// exist.
+func (s *Store) UpdateCascadeJob(ctx context.Context, jobRef string, update CascadeJobUpdate) (*CascadeJob, error) {
+	setExpr := "SET #updated_at = :now"
+	exprValues := map[string]types.AttributeValue{
+		":now":       &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		":pending":   &types.AttributeValueMemberN{Value: strconv.FormatInt(update.Pending, 10)},
+		":processed": &types.AttributeValueMemberN{Value: strconv.FormatInt(update.ChildrenProcessed, 10)},
+		":failures":  &types.AttributeValueMemberN{Value: strconv.FormatInt(update.Failures, 10)},
+	}
+	exprNames := map[string]string{
+		"#updated_at": "updated_at",
+		"#pending":    "pending",
+		"#processed":  "children_processed",
+		"#failures":   "failures",
+	}
+	if update.LastError != "" {
+		setExpr += ", #last_error = :last_error"
+		exprNames["#last_error"] = "last_error"
+		exprValues[":last_error"] = &types.AttributeValueMemberS{Value: update.LastError}
+	}
+	jobUpdate := &types.Update{
+		TableName:                 aws.String(s.config.CascadeJobTable),
+		Key:                       s.cascadeJobKey(jobRef),
+		UpdateExpression:          aws.String(setExpr + " ADD #pending :pending, #processed :processed, #failures :failures"),
+		ConditionExpression:       aws.String("attribute_exists(pk)"),
+		ExpressionAttributeNames:  exprNames,
+		ExpressionAttributeValues: exprValues,
+	}
+
+	var job *CascadeJob
+	if update.Done == nil {
+		result, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 jobUpdate.TableName,
+			Key:                       jobUpdate.Key,
+			UpdateExpression:          jobUpdate.UpdateExpression,
+			ConditionExpression:       jobUpdate.ConditionExpression,
+			ExpressionAttributeNames:  jobUpdate.ExpressionAttributeNames,
+			ExpressionAttributeValues: jobUpdate.ExpressionAttributeValues,
+			ReturnValues:              types.ReturnValueAllNew,
+		})
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		job = unmarshalCascadeJob(result.Attributes)
+	} else {
+		_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				{Update: &types.Update{
+					TableName:           aws.String(update.Done.TableName),
+					Key:                 update.Done.Key,
+					UpdateExpression:    aws.String("REMOVE #job"),
+					ConditionExpression: aws.String("#job = :job"),
+					ExpressionAttributeNames: map[string]string{
+						"#job": cascadeJobAttr,
+					},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":job": &types.AttributeValueMemberS{Value: jobRef},
+					},
+				}},
+				{Update: jobUpdate},
+			},
+		})
+		var txErr *types.TransactionCanceledException
+		if errors.As(err, &txErr) && len(txErr.CancellationReasons) == 2 {
+			switch {
+			case aws.ToString(txErr.CancellationReasons[1].Code) == "ConditionalCheckFailed":
+				return nil, ErrNotFound
+			case aws.ToString(txErr.CancellationReasons[0].Code) == "ConditionalCheckFailed":
+				// Already counted as done
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if job, err = s.CascadeStatus(ctx, jobRef); err != nil {
+			return nil, err
+		}
+	}
+
+	if job.Status != CascadeRunning || job.Pending > 0 {
+		return job, nil
+	}
+	return s.finishCascadeJob(ctx, jobRef, job.Failures)
+}
+
+// MarkCascadeJobNotified records that the completion hook of a finished job
+// has run, so a retried record does not run it again.
+func (s *Store) MarkCascadeJobNotified(ctx context.Context, jobRef string) error {
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.config.CascadeJobTable),
+		Key:                 s.cascadeJobKey(jobRef),
+		UpdateExpression:    aws.String("SET #notified = :true"),
+		ConditionExpression: aws.String("attribute_exists(pk)"),
+		ExpressionAttributeNames: map[string]string{
+			"#notified": "notified",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":true": &types.AttributeValueMemberBOOL{Value: true},
+		},
+	})
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// finishCascadeJob marks a running job with nothing pending as finished,
+// failed if failures is non-zero, and returns it. If another call finished
+// it first, the job is returned as is.
+func (s *Store) finishCascadeJob(ctx context.Context, jobRef string, failures int64) (*CascadeJob, error) {
+	status := CascadeCompleted
+	if failures > 0 {
+		status = CascadeFailed
+	}
+	now := time.Now()
+	result, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.config.CascadeJobTable),
+		Key:                 s.cascadeJobKey(jobRef),
+		UpdateExpression:    aws.String("SET #status = :status, #finished_at = :finished_at, #ttl = :ttl"),
+		ConditionExpression: aws.String("#status = :running AND #pending <= :zero"),
+		ExpressionAttributeNames: map[string]string{
+			"#status":      "status",
+			"#finished_at": "finished_at",
+			"#ttl":         "ttl",
+			"#pending":     "pending",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":status":      &types.AttributeValueMemberS{Value: string(status)},
+			":running":     &types.AttributeValueMemberS{Value: string(CascadeRunning)},
+			":finished_at": &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+			":ttl":         &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(cascadeJobRetention).Unix(), 10)},
+			":zero":        &types.AttributeValueMemberN{Value: "0"},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return s.CascadeStatus(ctx, jobRef)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalCascadeJob(result.Attributes), nil
+}
+
+// startCascadeJob stamps the root of a cascade in update, built by
+// softDeleteUpdate, and returns the put that records its job.
+func (s *Store) startCascadeJob(update *types.Update, entityRef string, now time.Time) *types.Put {
+	update.UpdateExpression = aws.String(aws.ToString(update.UpdateExpression) + ", #job = :job")
+	update.ExpressionAttributeNames["#job"] = cascadeJobAttr
+	update.ExpressionAttributeValues[":job"] = &types.AttributeValueMemberS{Value: entityRef}
+
+	item := s.cascadeJobKey(entityRef)
+	item["entity_ref"] = &types.AttributeValueMemberS{Value: entityRef}
+	item["status"] = &types.AttributeValueMemberS{Value: string(CascadeRunning)}
+	item["started_at"] = &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)}
+	item["pending"] = &types.AttributeValueMemberN{Value: "1"}
+	item["children_processed"] = &types.AttributeValueMemberN{Value: "0"}
+	item["failures"] = &types.AttributeValueMemberN{Value: "0"}
+	return &types.Put{
+		TableName: aws.String(s.config.CascadeJobTable),
+		Item:      item,
+	}
+}
+
+// cascadeJobKey returns the key of the job of the cascade of entityRef.
+func (s *Store) cascadeJobKey(entityRef string) PK {
+	return PK{"pk": &types.AttributeValueMemberS{Value: entityRef}}
+}
+
+// unmarshalCascadeJob converts a cascade job record to a CascadeJob.
+func unmarshalCascadeJob(raw map[string]types.AttributeValue) *CascadeJob {
+	job := &CascadeJob{}
+	if v, ok := raw["entity_ref"].(*types.AttributeValueMemberS); ok {
+		job.EntityRef = v.Value
+	}
+	if v, ok := raw["status"].(*types.AttributeValueMemberS); ok {
+		job.Status = CascadeJobStatus(v.Value)
+	}
+	if v, ok := raw["started_at"].(*types.AttributeValueMemberS); ok {
+		job.StartedAt, _ = time.Parse(time.RFC3339, v.Value)
+	}
+	if v, ok := raw["finished_at"].(*types.AttributeValueMemberS); ok {
+		job.FinishedAt, _ = time.Parse(time.RFC3339, v.Value)
+	}
+	if v, ok := raw["last_error"].(*types.AttributeValueMemberS); ok {
+		job.LastError = v.Value
+	}
+	if v, ok := raw["notified"].(*types.AttributeValueMemberBOOL); ok {
+		job.Notified = v.Value
+	}
+	for name, dst := range map[string]*int64{
+		"children_processed": &job.ChildrenProcessed,
+		"failures":           &job.Failures,
+		"pending":            &job.Pending,
+	} {
+		if v, ok := raw[name].(*types.AttributeValueMemberN); ok {
+			*dst, _ = strconv.ParseInt(v.Value, 10, 64)
+		}
+	}
+	return job
+}
@@ -0,0 +1,87 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jacentio/trellis/store"
+)
+
+func TestCascadeJob(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.TrackCascadeJobs = true
+	s, db := newMemStore(t, cfg)
+	ctx := context.Background()
+
+	for _, id := range []string{"p1", "p2"} {
+		if err := s.Create(ctx, Parent{ID: id}, makeTestItem(id, "Parent")); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := s.Create(ctx, Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "Child")); err != nil {
+		t.Fatalf("create c1: %v", err)
+	}
+
+	// Only cascading deletes are tracked
+	if err := s.Delete(ctx, Parent{ID: "p2"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete p2: %v", err)
+	}
+	if _, err := s.CascadeStatus(ctx, "parent#p2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected no job without Cascade, got %v", err)
+	}
+
+	if err := s.Delete(ctx, Parent{ID: "p1"}, store.DeleteOptions{Cascade: true}); err != nil {
+		t.Fatalf("delete p1: %v", err)
+	}
+	job, err := s.CascadeStatus(ctx, "parent#p1")
+	if err != nil || job.EntityRef != "parent#p1" || job.Status != store.CascadeRunning || job.Pending != 1 {
+		t.Fatalf("expected a running job for parent#p1, got %+v (err %v)", job, err)
+	}
+
+	// The child is counted before it is deleted, and each entity is done once
+	if _, err := s.UpdateCascadeJob(ctx, "parent#p1", store.CascadeJobUpdate{Pending: 1}); err != nil {
+		t.Fatalf("count child: %v", err)
+	}
+	deleted, stamped, err := s.SetCascadeTTL(ctx, "children", Child{ID: "c1"}.GetKey(), 1, "parent#p1")
+	if err != nil || !deleted || stamped {
+		t.Fatalf("expected c1 to be deleted, got %v, %v (err %v)", deleted, stamped, err)
+	}
+	if deleted, stamped, err := s.SetCascadeTTL(ctx, "children", Child{ID: "c1"}.GetKey(), 1, "parent#p1"); err != nil || deleted || !stamped {
+		t.Errorf("expected c1 to be deleted once and stamped, got %v, %v (err %v)", deleted, stamped, err)
+	}
+	if deleted, stamped, err := s.SetCascadeTTL(ctx, "children", Child{ID: "c1"}.GetKey(), 1, "parent#p2"); err != nil || deleted || stamped {
+		t.Errorf("expected c1 not stamped by another job, got %v, %v (err %v)", deleted, stamped, err)
+	}
+	done := store.CascadeJobUpdate{Pending: -1, Done: &store.CascadeEntity{TableName: "parents", Key: Parent{ID: "p1"}.GetKey()}}
+	for i := 0; i < 2; i++ {
+		if job, err = s.UpdateCascadeJob(ctx, "parent#p1", done); err != nil {
+			t.Fatalf("finish p1: %v", err)
+		}
+	}
+	if job.Status != store.CascadeRunning || job.Pending != 1 {
+		t.Errorf("expected c1 to be pending, got %+v", job)
+	}
+	done.Done = &store.CascadeEntity{TableName: "children", Key: Child{ID: "c1"}.GetKey()}
+	done.ChildrenProcessed = 1
+	if job, err = s.UpdateCascadeJob(ctx, "parent#p1", done); err != nil {
+		t.Fatalf("finish c1: %v", err)
+	}
+	if job.Status != store.CascadeCompleted || job.Pending != 0 || job.ChildrenProcessed != 1 || job.FinishedAt.IsZero() {
+		t.Errorf("expected a completed job, got %+v", job)
+	}
+	if _, ok := db.Item("parents", Parent{ID: "p1"}.GetKey())["_cascade_job"]; ok {
+		t.Error("expected p1 to be unstamped")
+	}
+
+	// Restoring abandons the job
+	if err := s.Restore(ctx, Parent{ID: "p1"}, 2, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore p1: %v", err)
+	}
+	if _, err := s.CascadeStatus(ctx, "parent#p1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected the job to be removed on restore, got %v", err)
+	}
+	if _, err := s.UpdateCascadeJob(ctx, "parent#p1", store.CascadeJobUpdate{Pending: 1}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound updating a removed job, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// cascadeCheckpointPrefix prefixes the keys of checkpoint records in the
+// cascade job table, keeping them apart from jobs, which are keyed by the
+// root's reference.
+const cascadeCheckpointPrefix = "_checkpoint#"
+
+// CascadeCheckpoint is the progress of a cascade that the stream handler
+// spreads over several invocations, recorded with Config.CheckpointCascades.
+// It is kept in the cascade job table rather than on the deleted entity, so
+// it outlives the entity when its TTL purges it mid-cascade.
+type CascadeCheckpoint struct {
+	// EntityRef is the reference of the entity whose children are being
+	// deleted.
+	EntityRef string
+
+	// ParentRef is the reference of the entity's parent, if any.
+	ParentRef string
+
+	// TableName and Key locate the entity.
+	TableName string
+	Key       PK
+
+	// TTL is the TTL the cascade gives the entity's children.
+	TTL int64
+
+	// JobRef is the reference of the cascade job the entity belongs to, if
+	// any.
+	JobRef string
+
+	// Cursor is the ListChildren cursor of the children still to be
+	// deleted, or empty once the cascade of the entity is done.
+	Cursor string
+}
+
+// CascadeCheckpoint returns the unfinished checkpoint of the cascade of
+// entityRef that gives its children ttl, as recorded by
+// SetCascadeCheckpoint. ok is false if there is none, or the entity was
+// restored or deleted again with another TTL since, in which case the
+// cascade should stop. An entity that is missing was purged by its TTL,
+// which does not stop the cascade.
+func (s *Store) CascadeCheckpoint(ctx context.Context, entityRef string, ttl int64) (cp *CascadeCheckpoint, ok bool, err error) {
+	raw, err := s.getRaw(ctx, s.config.CascadeJobTable, s.cascadeCheckpointKey(entityRef))
+	if errors.Is(err, ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	cp = unmarshalCascadeCheckpoint(raw)
+	if cp.TTL != ttl || cp.Cursor == "" {
+		return nil, false, nil
+	}
+
+	entity, err := s.getRaw(ctx, cp.TableName, cp.Key)
+	if errors.Is(err, ErrNotFound) {
+		return cp, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if current, hasTTL := itemTTL(entity); !hasTTL || current != ttl {
+		return nil, false, nil
+	}
+	return cp, true, nil
+}
+
+// SetCascadeCheckpoint records the checkpoint of the cascade of
+// cp.EntityRef, advancing it from the cursor from; an empty from starts a
+// new checkpoint, and an empty cp.Cursor marks the cascade done. The write
+// produces a stream record on the cascade job table, which is how a stream
+// handler continues a cascade in a later invocation. Checkpoints expire 7
+// days after they were last written.
+//
+// It fails with ErrConcurrentModification unless the recorded checkpoint is
+// from, or from is empty and none is recorded for a cascade with cp.TTL, so
+// of two runs of the same cascade only one advances it.
+func (s *Store) SetCascadeCheckpoint(ctx context.Context, cp *CascadeCheckpoint, from string) error {
+	now := time.Now()
+	exprNames := map[string]string{
+		"#entity_ref":  "entity_ref",
+		"#table_name":  "table_name",
+		"#key":         "key",
+		"#cascade_ttl": "cascade_ttl",
+		"#updated_at":  "updated_at",
+		"#ttl":         "ttl",
+		"#parent_ref":  "parent_ref",
+		"#job":         "job",
+		"#cursor":      "cursor",
+	}
+	exprValues := map[string]types.AttributeValue{
+		":entity_ref": &types.AttributeValueMemberS{Value: cp.EntityRef},
+		":table_name": &types.AttributeValueMemberS{Value: cp.TableName},
+		":key":        &types.AttributeValueMemberM{Value: cp.Key},
+		":ttl":        &types.AttributeValueMemberN{Value: strconv.FormatInt(cp.TTL, 10)},
+		":updated_at": &types.AttributeValueMemberS{Value: now.UTC().Format(time.RFC3339)},
+		":expires":    &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(cascadeJobRetention).Unix(), 10)},
+	}
+	sets := []string{
+		"#entity_ref = :entity_ref",
+		"#table_name = :table_name",
+		"#key = :key",
+		"#cascade_ttl = :ttl",
+		"#updated_at = :updated_at",
+		"#ttl = :expires",
+	}
+	var removes []string
+	optional := []struct{ name, value string }{
+		{"parent_ref", cp.ParentRef},
+		{"job", cp.JobRef},
+		{"cursor", cp.Cursor},
+	}
+	for _, attr := range optional {
+		if attr.value == "" {
+			removes = append(removes, "#"+attr.name)
+			continue
+		}
+		exprValues[":"+attr.name] = &types.AttributeValueMemberS{Value: attr.value}
+		sets = append(sets, "#"+attr.name+" = :"+attr.name)
+	}
+	updateExpr := "SET " + strings.Join(sets, ", ")
+	if len(removes) > 0 {
+		updateExpr += " REMOVE " + strings.Join(removes, ", ")
+	}
+
+	condExpr := "attribute_not_exists(pk) OR #cascade_ttl <> :ttl"
+	if from != "" {
+		condExpr = "#cascade_ttl = :ttl AND #cursor = :from"
+		exprValues[":from"] = &types.AttributeValueMemberS{Value: from}
+	}
+
+	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.config.CascadeJobTable),
+		Key:                       s.cascadeCheckpointKey(cp.EntityRef),
+		UpdateExpression:          aws.String(updateExpr),
+		ConditionExpression:       aws.String(condExpr),
+		ExpressionAttributeNames:  exprNames,
+		ExpressionAttributeValues: exprValues,
+	})
+
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return ErrConcurrentModification
+	}
+	return err
+}
+
+// cascadeCheckpointKey returns the key of the checkpoint of the cascade of
+// entityRef.
+func (s *Store) cascadeCheckpointKey(entityRef string) PK {
+	return PK{"pk": &types.AttributeValueMemberS{Value: cascadeCheckpointPrefix + entityRef}}
+}
+
+// unmarshalCascadeCheckpoint converts a checkpoint record to a
+// CascadeCheckpoint.
+func unmarshalCascadeCheckpoint(raw map[string]types.AttributeValue) *CascadeCheckpoint {
+	cp := &CascadeCheckpoint{}
+	if v, ok := raw["entity_ref"].(*types.AttributeValueMemberS); ok {
+		cp.EntityRef = v.Value
+	}
+	if v, ok := raw["parent_ref"].(*types.AttributeValueMemberS); ok {
+		cp.ParentRef = v.Value
+	}
+	if v, ok := raw["table_name"].(*types.AttributeValueMemberS); ok {
+		cp.TableName = v.Value
+	}
+	if v, ok := raw["key"].(*types.AttributeValueMemberM); ok {
+		cp.Key = v.Value
+	}
+	if v, ok := raw["cascade_ttl"].(*types.AttributeValueMemberN); ok {
+		cp.TTL, _ = strconv.ParseInt(v.Value, 10, 64)
+	}
+	if v, ok := raw["job"].(*types.AttributeValueMemberS); ok {
+		cp.JobRef = v.Value
+	}
+	if v, ok := raw["cursor"].(*types.AttributeValueMemberS); ok {
+		cp.Cursor = v.Value
+	}
+	return cp
+}
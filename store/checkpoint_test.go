@@ -0,0 +1,93 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+)
+
+func TestCascadeCheckpoint(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	deleted := func(p Parent) *store.CascadeCheckpoint {
+		t.Helper()
+		if err := s.Create(ctx, p, makeTestItem(p.ID, "Parent")); err != nil {
+			t.Fatalf("create %s: %v", p.ID, err)
+		}
+		if err := s.Delete(ctx, p, store.DeleteOptions{}); err != nil {
+			t.Fatalf("delete %s: %v", p.ID, err)
+		}
+		ttl, err := strconv.ParseInt(db.Item("parents", p.GetKey())["ttl"].(*types.AttributeValueMemberN).Value, 10, 64)
+		if err != nil {
+			t.Fatalf("parse ttl: %v", err)
+		}
+		return &store.CascadeCheckpoint{EntityRef: p.EntityRef(), TableName: "parents", Key: p.GetKey(), TTL: ttl}
+	}
+
+	cp := deleted(Parent{ID: "p1"})
+	if _, ok, err := s.CascadeCheckpoint(ctx, cp.EntityRef, cp.TTL); ok || err != nil {
+		t.Errorf("expected no checkpoint, got ok=%v (err %v)", ok, err)
+	}
+
+	// Each checkpoint must follow the last one
+	steps := []struct {
+		from, to string
+		wantErr  error
+	}{
+		{"", "one", nil},
+		{"", "two", store.ErrConcurrentModification},
+		{"one", "two", nil},
+		{"one", "three", store.ErrConcurrentModification},
+		{"two", "", nil},
+		{"", "one", store.ErrConcurrentModification},
+	}
+	for _, step := range steps {
+		cp.Cursor = step.to
+		err := s.SetCascadeCheckpoint(ctx, cp, step.from)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("%q -> %q: expected %v, got %v", step.from, step.to, step.wantErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		got, ok, err := s.CascadeCheckpoint(ctx, cp.EntityRef, cp.TTL)
+		if err != nil || ok != (step.to != "") || ok && got.Cursor != step.to {
+			t.Errorf("%q -> %q: got %+v, ok=%v (err %v)", step.from, step.to, got, ok, err)
+		}
+	}
+
+	// The checkpoint outlives its entity, but not a restore
+	p2 := deleted(Parent{ID: "p2"})
+	p2.Cursor = "one"
+	if err := s.SetCascadeCheckpoint(ctx, p2, ""); err != nil {
+		t.Fatalf("checkpoint p2: %v", err)
+	}
+	p3 := deleted(Parent{ID: "p3"})
+	p3.Cursor = "one"
+	if err := s.SetCascadeCheckpoint(ctx, p3, ""); err != nil {
+		t.Fatalf("checkpoint p3: %v", err)
+	}
+	if _, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: &p2.TableName, Key: p2.Key}); err != nil {
+		t.Fatalf("purge p2: %v", err)
+	}
+	if err := s.Restore(ctx, Parent{ID: "p3"}, 2, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore p3: %v", err)
+	}
+	got, ok, err := s.CascadeCheckpoint(ctx, p2.EntityRef, p2.TTL)
+	if err != nil || !ok || got.Cursor != "one" || got.TableName != "parents" || got.Key["id"] == nil {
+		t.Errorf("expected the checkpoint of purged p2, got %+v, ok=%v (err %v)", got, ok, err)
+	}
+	if _, ok, err := s.CascadeCheckpoint(ctx, p3.EntityRef, p3.TTL); ok || err != nil {
+		t.Errorf("expected no checkpoint for restored p3, got ok=%v (err %v)", ok, err)
+	}
+	if _, ok, err := s.CascadeCheckpoint(ctx, p2.EntityRef, p2.TTL+1); ok || err != nil {
+		t.Errorf("expected no checkpoint for another TTL, got ok=%v (err %v)", ok, err)
+	}
+}
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// errNoChildCount marks a child count decrement on a parent that is missing
+// or was created before child counts were tracked. The decrement is dropped.
+var errNoChildCount = errors.New("trellis: parent has no child count")
+
+// orphanCondition is added to a delete's condition when the count enforces
+// OrphanProtect. It fails for entities created before child counts were
+// tracked, which fall back to HasActiveChildren.
+const orphanCondition = "#child_count <= :zero"
+
+// countsOrphans reports whether a delete's OrphanProtect is enforced by the
+// entity's child count.
+func (s *Store) countsOrphans(opts DeleteOptions) bool {
+	return s.config.TrackChildCount && opts.OrphanProtect && !opts.Cascade
+}
+
+// isUncounted reports whether a failed delete's old item is an active
+// entity without a child count.
+func isUncounted(old map[string]types.AttributeValue) bool {
+	_, counted := itemChildCount(old)
+	return len(old) > 0 && !counted && !IsDeleted(old)
+}
+
+// childCountCheck builds the condition check that a parent tracks its child
+// count, to be turned into a decrement with withChildCount.
+func childCountCheck(check *ConditionCheck) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName:                aws.String(check.TableName),
+			Key:                      check.Key,
+			ConditionExpression:      aws.String("attribute_exists(#child_count)"),
+			ExpressionAttributeNames: map[string]string{"#child_count": "child_count"},
+		},
+	}
+}
+
+// withChildCount returns a copy of a transaction item on a parent that also
+// adds delta to the parent's child count, keeping the item's condition:
+//   - a condition check becomes an update that adds delta
+//   - an update that already adds to the count adds delta more
+//   - any other update gets an ADD clause
+//   - a put of a new parent starts its count at delta more
+func withChildCount(item types.TransactWriteItem, delta int64) types.TransactWriteItem {
+	switch {
+	case item.ConditionCheck != nil:
+		c := item.ConditionCheck
+		names := maps.Clone(c.ExpressionAttributeNames)
+		if names == nil {
+			names = make(map[string]string, 1)
+		}
+		names["#child_count"] = "child_count"
+		values := maps.Clone(c.ExpressionAttributeValues)
+		if values == nil {
+			values = make(map[string]types.AttributeValue, 1)
+		}
+		values[":child_delta"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(delta, 10)}
+		return types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:                 c.TableName,
+				Key:                       c.Key,
+				UpdateExpression:          aws.String("ADD #child_count :child_delta"),
+				ConditionExpression:       c.ConditionExpression,
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		}
+
+	case item.Update != nil:
+		u := *item.Update
+		u.ExpressionAttributeValues = maps.Clone(u.ExpressionAttributeValues)
+		if u.ExpressionAttributeValues == nil {
+			u.ExpressionAttributeValues = make(map[string]types.AttributeValue, 1)
+		}
+		if existing, ok := u.ExpressionAttributeValues[":child_delta"].(*types.AttributeValueMemberN); ok {
+			n, _ := strconv.ParseInt(existing.Value, 10, 64)
+			delta += n
+		} else {
+			u.ExpressionAttributeNames = maps.Clone(u.ExpressionAttributeNames)
+			if u.ExpressionAttributeNames == nil {
+				u.ExpressionAttributeNames = make(map[string]string, 1)
+			}
+			u.ExpressionAttributeNames["#child_count"] = "child_count"
+			u.UpdateExpression = aws.String(aws.ToString(u.UpdateExpression) + " ADD #child_count :child_delta")
+		}
+		u.ExpressionAttributeValues[":child_delta"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(delta, 10)}
+		return types.TransactWriteItem{Update: &u}
+
+	case item.Put != nil:
+		p := *item.Put
+		p.Item = maps.Clone(p.Item)
+		count, _ := itemChildCount(p.Item)
+		p.Item["child_count"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(count+delta, 10)}
+		return types.TransactWriteItem{Put: &p}
+	}
+	return item
+}
+
+// itemChildCount returns an item's child count and whether it has one.
+func itemChildCount(item map[string]types.AttributeValue) (int64, bool) {
+	v, ok := item["child_count"].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v.Value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// countActiveChildren counts the children of entityRef that are not
+// deleted, reading each child item.
+func (s *Store) countActiveChildren(ctx context.Context, entityRef string) (int64, error) {
+	var keys []TableKey
+	for child, err := range s.QueryAllChildrenIter(ctx, entityRef) {
+		if err != nil {
+			return 0, err
+		}
+		keys = append(keys, TableKey{TableName: child.TableName, Key: child.Key})
+	}
+
+	var count int64
+	for start := 0; start < len(keys); start += maxBatchGetKeys {
+		end := min(start+maxBatchGetKeys, len(keys))
+		err := s.batchGetChunk(ctx, keys[start:end], func(_ string, raw map[string]types.AttributeValue, _ []string) {
+			if !IsDeleted(raw) {
+				count++
+			}
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,250 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+	"github.com/jacentio/trellis/storetest"
+)
+
+func trackingConfig() store.Config {
+	cfg := store.DefaultConfig()
+	cfg.TrackChildCount = true
+	return cfg
+}
+
+// childCount returns the stored child_count of an item, or -1 if it has none.
+func childCount(t *testing.T, db *storetest.DB, table string, key store.PK) int64 {
+	t.Helper()
+	v, ok := db.Item(table, key)["child_count"].(*types.AttributeValueMemberN)
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(v.Value, 10, 64)
+	if err != nil {
+		t.Fatalf("parse child_count %q: %v", v.Value, err)
+	}
+	return n
+}
+
+func TestChildCount_CreateAndDelete(t *testing.T) {
+	s, db := newMemStore(t, trackingConfig())
+	ctx := context.Background()
+	parentKey := Parent{ID: "p1"}.GetKey()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	if n := childCount(t, db, "parents", parentKey); n != 0 {
+		t.Fatalf("expected new parent to count 0 children, got %d", n)
+	}
+
+	for _, id := range []string{"c1", "c2"} {
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, "Child")); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	errs := s.BulkCreate(ctx, []store.CreateRequest{
+		{Entity: Child{ID: "c3", ParentID: "p1"}, Item: makeTestItem("c3", "Child")},
+		{Entity: Child{ID: "c4", ParentID: "p1"}, Item: makeTestItem("c4", "Child")},
+	})
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("bulk create %d: %v", i, err)
+		}
+	}
+	if n := childCount(t, db, "parents", parentKey); n != 4 {
+		t.Fatalf("expected 4 children, got %d", n)
+	}
+
+	if err := s.Delete(ctx, Child{ID: "c1", ParentID: "p1"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete c1: %v", err)
+	}
+	// Deleting again must not count it twice
+	if err := s.Delete(ctx, Child{ID: "c1", ParentID: "p1"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete c1 again: %v", err)
+	}
+	if n := childCount(t, db, "parents", parentKey); n != 3 {
+		t.Fatalf("expected 3 children after delete, got %d", n)
+	}
+
+	err := s.Tx().
+		Delete(Child{ID: "c2", ParentID: "p1"}, store.DeleteOptions{}).
+		Delete(Child{ID: "c3", ParentID: "p1"}, store.DeleteOptions{}).
+		Create(Child{ID: "c5", ParentID: "p1"}, makeTestItem("c5", "Child")).
+		Commit(ctx)
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if n := childCount(t, db, "parents", parentKey); n != 2 {
+		t.Fatalf("expected 2 children after tx, got %d", n)
+	}
+
+	err = s.Delete(ctx, Parent{ID: "p1"}, store.DeleteOptions{OrphanProtect: true})
+	if !errors.Is(err, store.ErrHasChildren) {
+		t.Errorf("expected ErrHasChildren, got %v", err)
+	}
+
+	if _, err := s.Purge(ctx, Child{ID: "c4", ParentID: "p1"}, store.PurgeOptions{}); err != nil {
+		t.Fatalf("purge c4: %v", err)
+	}
+	if err := s.Delete(ctx, Child{ID: "c5", ParentID: "p1"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete c5: %v", err)
+	}
+	if n := childCount(t, db, "parents", parentKey); n != 0 {
+		t.Fatalf("expected 0 children, got %d", n)
+	}
+	if err := s.Delete(ctx, Parent{ID: "p1"}, store.DeleteOptions{OrphanProtect: true}); err != nil {
+		t.Errorf("delete childless parent: %v", err)
+	}
+}
+
+func TestChildCount_OrphanProtectRace(t *testing.T) {
+	for _, track := range []bool{false, true} {
+		cfg := store.DefaultConfig()
+		cfg.TrackChildCount = track
+		s, db := newMemStore(t, cfg)
+		ctx := context.Background()
+
+		if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+			t.Fatalf("create parent: %v", err)
+		}
+
+		// A child is created just before the parent is marked deleted
+		db.SetInterceptor(func(_ context.Context, op string, _ any) error {
+			if op != "UpdateItem" {
+				return nil
+			}
+			db.SetInterceptor(nil)
+			return s.Create(ctx, Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "Child"))
+		})
+
+		err := s.Delete(ctx, Parent{ID: "p1"}, store.DeleteOptions{OrphanProtect: true})
+		if !track {
+			if err != nil {
+				t.Fatalf("untracked delete: %v", err)
+			}
+			// Without counts the race leaves an orphan
+			continue
+		}
+		if !errors.Is(err, store.ErrHasChildren) {
+			t.Errorf("expected ErrHasChildren, got %v", err)
+		}
+		if store.IsDeleted(db.Item("parents", Parent{ID: "p1"}.GetKey())) {
+			t.Error("expected parent to remain active")
+		}
+	}
+}
+
+func TestChildCount_Untracked(t *testing.T) {
+	untracked, db := newMemStore(t, store.DefaultConfig())
+	s := store.New(db, trackingConfig())
+	ctx := context.Background()
+
+	for _, id := range []string{"p1", "p2"} {
+		if err := untracked.Create(ctx, Parent{ID: id}, makeTestItem(id, "Parent")); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := untracked.Create(ctx, Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "Child")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+
+	// Parents without a count fall back to querying for children
+	err := s.Delete(ctx, Parent{ID: "p1"}, store.DeleteOptions{OrphanProtect: true})
+	if !errors.Is(err, store.ErrHasChildren) {
+		t.Errorf("expected ErrHasChildren, got %v", err)
+	}
+	err = s.Tx().Delete(Parent{ID: "p1"}, store.DeleteOptions{OrphanProtect: true}).Commit(ctx)
+	if !errors.Is(err, store.ErrHasChildren) {
+		t.Errorf("expected ErrHasChildren from tx, got %v", err)
+	}
+
+	// A fallback that fails for another reason is reported, not retried
+	if err := untracked.Update(ctx, Parent{ID: "p2"}, makeTestItem("p2", "Renamed"), 1); err != nil {
+		t.Fatalf("update p2: %v", err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err = s.Tx().Delete(Parent{ID: "p2"}, store.DeleteOptions{OrphanProtect: true, ExpectedVersion: 1}).Commit(timeout)
+	if !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification from tx at a stale version, got %v", err)
+	}
+	if err := s.Tx().Delete(Parent{ID: "p2"}, store.DeleteOptions{OrphanProtect: true}).Commit(ctx); err != nil {
+		t.Errorf("tx delete childless parent: %v", err)
+	}
+	err = s.Tx().Delete(Parent{ID: "p2"}, store.DeleteOptions{OrphanProtect: true, Strict: true}).Commit(timeout)
+	if !errors.Is(err, store.ErrAlreadyDeleted) {
+		t.Errorf("expected ErrAlreadyDeleted from tx, got %v", err)
+	}
+
+	// Deleting a child does not start a count on its parent
+	if err := s.Delete(ctx, Child{ID: "c1", ParentID: "p1"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete child: %v", err)
+	}
+	if !store.IsDeleted(db.Item("children", Child{ID: "c1"}.GetKey())) {
+		t.Error("expected child to be deleted")
+	}
+	if n := childCount(t, db, "parents", Parent{ID: "p1"}.GetKey()); n != -1 {
+		t.Errorf("expected no child count on p1, got %d", n)
+	}
+}
+
+func TestChildCount_CascadeAndRestore(t *testing.T) {
+	s, db := newMemStore(t, trackingConfig())
+	ctx := context.Background()
+	seedTree(t, s)
+	count := func(id string) int64 {
+		return childCount(t, db, "nodes", Node{ID: id}.GetKey())
+	}
+
+	if n := count("root"); n != 2 {
+		t.Fatalf("expected root to count 2 children, got %d", n)
+	}
+
+	// The cascade root is uncounted from its parent; its descendants stay
+	// counted on their deleted parents
+	if err := s.Delete(ctx, Node{ID: "a", ParentID: "root"}, store.DeleteOptions{Sync: true}); err != nil {
+		t.Fatalf("delete a: %v", err)
+	}
+	if count("root") != 1 || count("a") != 2 {
+		t.Fatalf("expected root 1 and a 2, got %d and %d", count("root"), count("a"))
+	}
+
+	// Restoring alone recounts the active children
+	if err := s.Restore(ctx, Node{ID: "a", ParentID: "root"}, 2, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore a: %v", err)
+	}
+	if count("root") != 2 || count("a") != 0 {
+		t.Fatalf("expected root 2 and a 0, got %d and %d", count("root"), count("a"))
+	}
+	if err := s.Delete(ctx, Node{ID: "a", ParentID: "root"}, store.DeleteOptions{OrphanProtect: true}); err != nil {
+		t.Fatalf("delete restored a: %v", err)
+	}
+
+	// Restoring the subtree counts each descendant as it returns
+	if err := s.Delete(ctx, Node{ID: "b", ParentID: "root"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete b: %v", err)
+	}
+	if err := s.Restore(ctx, Node{ID: "a", ParentID: "root"}, 4, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore a again: %v", err)
+	}
+	// A distinct TTL keeps earlier deletes out of this cascade
+	if err := s.Delete(ctx, Node{ID: "root"}, store.DeleteOptions{Sync: true, Retention: time.Hour}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+	if err := s.Restore(ctx, Node{ID: "root"}, 2, store.RestoreOptions{Subtree: true}); err != nil {
+		t.Fatalf("restore root subtree: %v", err)
+	}
+	for id, want := range map[string]int64{"root": 1, "a": 0} {
+		if n := count(id); n != want {
+			t.Errorf("expected %s to count %d children, got %d", id, want, n)
+		}
+	}
+}
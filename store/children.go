@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ChildFilter selects which children ListChildren and CountChildren return.
+// The zero value selects all active children.
+type ChildFilter struct {
+	// EntityType keeps only children of this type, matched against the
+	// "type#" prefix of their entity reference.
+	EntityType string
+
+	// TableName keeps only children stored in this table.
+	TableName string
+
+	// IncludeDeleted also selects deleted children whose relationship
+	// records have not been purged yet.
+	IncludeDeleted bool
+}
+
+// ListChildrenOptions configures ListChildren.
+type ListChildrenOptions struct {
+	ChildFilter
+
+	// Limit is the maximum number of relationship records to evaluate for
+	// the page (0 = no limit). Deleted and filtered-out children count
+	// towards it, so pages may be short.
+	Limit int32
+
+	// Cursor resumes after a previous page's ChildPage.Cursor.
+	Cursor string
+}
+
+// ChildPage is a single page of ListChildren results.
+type ChildPage struct {
+	// Children are the children in this page.
+	Children []ChildRef
+
+	// Cursor resumes the listing after this page.
+	// Empty when there are no more pages.
+	Cursor string
+}
+
+// ListChildren reads a page of the children of parentRef that match
+// opts.ChildFilter from the relationship table. Shards are read in order, so
+// with multiple shards the children are grouped by shard and sorted by
+// reference within each.
+//
+// Pass an empty cursor for the first page and ChildPage.Cursor for
+// subsequent pages; an empty ChildPage.Cursor means there are no more pages.
+func (s *Store) ListChildren(ctx context.Context, parentRef string, opts ListChildrenOptions) (*ChildPage, error) {
+	shardNum, startKey, err := s.decodeChildCursor(parentRef, opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ChildPage{}
+	var evaluated int32
+	for shardNum < s.config.NumShards {
+		shardPK := fmt.Sprintf("%s#%02x", parentRef, shardNum)
+		input := s.childQueryInput(shardPK, opts.ChildFilter)
+		input.ExclusiveStartKey = startKey
+		if opts.Limit > 0 {
+			input.Limit = aws.Int32(opts.Limit - evaluated)
+		}
+
+		result, err := s.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range result.Items {
+			page.Children = append(page.Children, s.unmarshalChildRef(item, shardPK))
+		}
+		evaluated += result.ScannedCount
+
+		startKey = result.LastEvaluatedKey
+		if len(startKey) == 0 {
+			shardNum++
+		}
+		if opts.Limit > 0 && evaluated >= opts.Limit {
+			break
+		}
+	}
+
+	if shardNum < s.config.NumShards {
+		if len(startKey) == 0 {
+			// Resume at the start of the next shard
+			startKey = map[string]types.AttributeValue{
+				"pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("%s#%02x", parentRef, shardNum)},
+			}
+		}
+		if page.Cursor, err = encodeCursor(s.childCursorScope(parentRef), startKey); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// CountChildren counts the active children of parentRef that match filter,
+// querying every shard concurrently. With Config.TrackChildCount, the
+// parent's Item.ChildCount gives the unfiltered count without a query.
+func (s *Store) CountChildren(ctx context.Context, parentRef string, filter ChildFilter) (int, error) {
+	counts := make([]int, s.config.NumShards)
+	errs := make([]error, s.config.NumShards)
+	var wg sync.WaitGroup
+
+	for shardNum := 0; shardNum < s.config.NumShards; shardNum++ {
+		wg.Add(1)
+		go func(shardNum int) {
+			defer wg.Done()
+
+			input := s.childQueryInput(fmt.Sprintf("%s#%02x", parentRef, shardNum), filter)
+			input.Select = types.SelectCount
+			paginator := dynamodb.NewQueryPaginator(s.client, input)
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					errs[shardNum] = fmt.Errorf("shard %02x: %w", shardNum, err)
+					return
+				}
+				counts[shardNum] += int(page.Count)
+			}
+		}(shardNum)
+	}
+	wg.Wait()
+
+	total := 0
+	for i, n := range counts {
+		if errs[i] != nil {
+			return 0, errs[i]
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// childQueryInput builds the query for the children in one relationship
+// shard that match filter.
+func (s *Store) childQueryInput(shardPK string, filter ChildFilter) *dynamodb.QueryInput {
+	keyCond := "pk = :pk"
+	var filters []string
+	exprNames := make(map[string]string)
+	exprValues := map[string]types.AttributeValue{
+		":pk": &types.AttributeValueMemberS{Value: shardPK},
+	}
+
+	if !filter.IncludeDeleted {
+		filters = append(filters, ActiveFilterExpr())
+		maps.Copy(exprNames, ActiveFilterNames())
+		maps.Copy(exprValues, TTLFilterValues())
+	}
+	if filter.EntityType != "" {
+		keyCond += " AND begins_with(child_ref, :type_prefix)"
+		exprValues[":type_prefix"] = &types.AttributeValueMemberS{Value: filter.EntityType + "#"}
+	}
+	if filter.TableName != "" {
+		filters = append(filters, "#child_table = :child_table")
+		exprNames["#child_table"] = "child_table"
+		exprValues[":child_table"] = &types.AttributeValueMemberS{Value: filter.TableName}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(s.config.RelationshipTable),
+		KeyConditionExpression:    aws.String(keyCond),
+		ExpressionAttributeValues: exprValues,
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String("(" + strings.Join(filters, ") AND (") + ")")
+		input.ExpressionAttributeNames = exprNames
+	}
+	return input
+}
+
+// childCursorScope returns the scope of the ListChildren cursors of
+// parentRef.
+func (s *Store) childCursorScope(parentRef string) cursorScope {
+	return cursorScope{table: s.config.RelationshipTable, partition: parentRef}
+}
+
+// decodeChildCursor decodes a ListChildren cursor into the shard to resume
+// in and the key to resume after, which is nil at the start of a shard.
+func (s *Store) decodeChildCursor(parentRef, cursor string) (int, map[string]types.AttributeValue, error) {
+	key, err := decodeCursor(s.childCursorScope(parentRef), cursor)
+	if err != nil || key == nil {
+		return 0, nil, err
+	}
+
+	pk, ok := key["pk"].(*types.AttributeValueMemberS)
+	if !ok {
+		return 0, nil, ErrInvalidCursor
+	}
+	suffix, ok := strings.CutPrefix(pk.Value, parentRef+"#")
+	if !ok {
+		return 0, nil, ErrInvalidCursor
+	}
+	shardNum, err := strconv.ParseUint(suffix, 16, 8)
+	if err != nil || int(shardNum) >= s.config.NumShards {
+		return 0, nil, ErrInvalidCursor
+	}
+
+	if _, ok := key["child_ref"]; !ok {
+		return int(shardNum), nil, nil
+	}
+	return int(shardNum), key, nil
+}
@@ -0,0 +1,116 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jacentio/trellis/store"
+)
+
+func TestListChildren(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 4
+	s, _ := newMemStore(t, cfg)
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	for _, id := range []string{"c1", "c2", "c3", "c4", "c5"} {
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, "Child")); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	for _, id := range []string{"u1", "u2"} {
+		child := UniqueChild{ID: id, ParentID: "p1", Name: id, Slug: id}
+		if err := s.Create(ctx, child, makeTestItem(id, id)); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := s.Delete(ctx, Child{ID: "c1", ParentID: "p1"}, store.DeleteOptions{Sync: true}); err != nil {
+		t.Fatalf("delete c1: %v", err)
+	}
+
+	// Page through every shard
+	seen := make(map[string]bool)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("pagination did not terminate")
+		}
+		page, err := s.ListChildren(ctx, "parent#p1", store.ListChildrenOptions{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("list children: %v", err)
+		}
+		for _, child := range page.Children {
+			if seen[child.Ref] {
+				t.Errorf("child %s listed twice", child.Ref)
+			}
+			seen[child.Ref] = true
+		}
+		if page.Cursor == "" {
+			break
+		}
+		cursor = page.Cursor
+	}
+	if len(seen) != 6 || seen["child#c1"] {
+		t.Errorf("expected the 6 active children, got %v", seen)
+	}
+
+	for _, tc := range []struct {
+		filter store.ChildFilter
+		want   int
+	}{
+		{store.ChildFilter{}, 6},
+		{store.ChildFilter{EntityType: "child"}, 4},
+		{store.ChildFilter{EntityType: "unique_child"}, 2},
+		{store.ChildFilter{TableName: "unique_children"}, 2},
+		{store.ChildFilter{EntityType: "child", TableName: "unique_children"}, 0},
+		{store.ChildFilter{IncludeDeleted: true}, 7},
+		{store.ChildFilter{EntityType: "child", IncludeDeleted: true}, 5},
+	} {
+		page, err := s.ListChildren(ctx, "parent#p1", store.ListChildrenOptions{ChildFilter: tc.filter})
+		if err != nil {
+			t.Fatalf("%+v: list children: %v", tc.filter, err)
+		}
+		if len(page.Children) != tc.want || page.Cursor != "" {
+			t.Errorf("%+v: expected %d children in one page, got %d (cursor %q)", tc.filter, tc.want, len(page.Children), page.Cursor)
+		}
+		count, err := s.CountChildren(ctx, "parent#p1", tc.filter)
+		if err != nil || count != tc.want {
+			t.Errorf("%+v: expected count %d, got %d (err %v)", tc.filter, tc.want, count, err)
+		}
+	}
+}
+
+func TestListChildren_InvalidCursor(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 2
+	s, _ := newMemStore(t, cfg)
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	for _, id := range []string{"c1", "c2", "c3"} {
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, "Child")); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	page, err := s.ListChildren(ctx, "parent#p1", store.ListChildrenOptions{Limit: 1})
+	if err != nil || page.Cursor == "" {
+		t.Fatalf("expected a cursor, got %q (err %v)", page.Cursor, err)
+	}
+
+	// A cursor only resumes the listing it came from
+	for _, tc := range []struct{ parentRef, cursor string }{
+		{"parent#p1", "garbage"},
+		{"parent#p2", page.Cursor},
+	} {
+		_, err := s.ListChildren(ctx, tc.parentRef, store.ListChildrenOptions{Cursor: tc.cursor})
+		if !errors.Is(err, store.ErrInvalidCursor) {
+			t.Errorf("%s %q: expected ErrInvalidCursor, got %v", tc.parentRef, tc.cursor, err)
+		}
+	}
+}
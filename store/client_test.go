@@ -0,0 +1,171 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+)
+
+// fakeClient is a minimal DynamoDBClient whose behavior is set per test.
+type fakeClient struct {
+	batchGetItem       func(*dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error)
+	getItem            func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	query              func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	scan               func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	updateItem         func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	transactWriteItems func(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+func (f *fakeClient) BatchGetItem(_ context.Context, in *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	return f.batchGetItem(in)
+}
+
+func (f *fakeClient) GetItem(_ context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return f.getItem(in)
+}
+
+func (f *fakeClient) Query(_ context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return f.query(in)
+}
+
+func (f *fakeClient) Scan(_ context.Context, in *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return f.scan(in)
+}
+
+func (f *fakeClient) UpdateItem(_ context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return f.updateItem(in)
+}
+
+func (f *fakeClient) TransactWriteItems(_ context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	return f.transactWriteItems(in)
+}
+
+var _ store.DynamoDBClient = (*fakeClient)(nil)
+
+func TestDynamoDBClient_CreateUsesTransaction(t *testing.T) {
+	var got *dynamodb.TransactWriteItemsInput
+	client := &fakeClient{
+		transactWriteItems: func(in *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			got = in
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+	s := store.New(client, store.DefaultConfig())
+
+	child := Child{ID: "c1", ParentID: "p1"}
+	if err := s.Create(context.Background(), child, makeTestItem("c1", "child")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got == nil {
+		t.Fatal("expected TransactWriteItems to be called")
+	}
+	// Parent check, entity put, relationship put
+	if len(got.TransactItems) != 3 {
+		t.Fatalf("expected 3 transact items, got %d", len(got.TransactItems))
+	}
+	if got.TransactItems[0].ConditionCheck == nil {
+		t.Error("expected first item to be the parent condition check")
+	}
+	if got.TransactItems[2].Put == nil || *got.TransactItems[2].Put.TableName != "trellis_relationships" {
+		t.Error("expected last item to be the relationship put")
+	}
+}
+
+func TestDynamoDBClient_CreateMapsCancellation(t *testing.T) {
+	code := "ConditionalCheckFailed"
+	client := &fakeClient{
+		transactWriteItems: func(*dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			return nil, &types.TransactionCanceledException{
+				CancellationReasons: []types.CancellationReason{{Code: &code}, {}, {}},
+			}
+		},
+	}
+	s := store.New(client, store.DefaultConfig())
+
+	err := s.Create(context.Background(), Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "child"))
+	if !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+}
+
+func TestDynamoDBClient_GetFiltersDeleted(t *testing.T) {
+	client := &fakeClient{
+		getItem: func(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"id":  &types.AttributeValueMemberS{Value: "p1"},
+				"ttl": &types.AttributeValueMemberN{Value: "1000"},
+			}}, nil
+		},
+	}
+	s := store.New(client, store.DefaultConfig())
+
+	_, err := s.Get(context.Background(), "parents", Parent{ID: "p1"}.GetKey())
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestDynamoDBClient_UpdateMapsConditionFailure(t *testing.T) {
+	client := &fakeClient{
+		updateItem: func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			return nil, &types.ConditionalCheckFailedException{}
+		},
+	}
+	s := store.New(client, store.DefaultConfig())
+
+	err := s.Update(context.Background(), Parent{ID: "p1"}, makeTestItem("p1", "new"), 1)
+	if !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification, got %v", err)
+	}
+}
+
+func TestDynamoDBClient_DeleteOrphanProtect(t *testing.T) {
+	updated := false
+	client := &fakeClient{
+		query: func(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+				{"child_ref": &types.AttributeValueMemberS{Value: "child#c1"}},
+			}}, nil
+		},
+		updateItem: func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+			updated = true
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	s := store.New(client, store.DefaultConfig())
+
+	err := s.Delete(context.Background(), Parent{ID: "p1"}, store.DeleteOptions{OrphanProtect: true})
+	if !errors.Is(err, store.ErrHasChildren) {
+		t.Errorf("expected ErrHasChildren, got %v", err)
+	}
+	if updated {
+		t.Error("expected no TTL update when children exist")
+	}
+}
+
+func TestDynamoDBClient_QueryAllChildrenMultiShard(t *testing.T) {
+	client := &fakeClient{
+		query: func(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			pk := in.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value
+			return &dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+				{"child_ref": &types.AttributeValueMemberS{Value: "child@" + pk}},
+			}}, nil
+		},
+	}
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 4
+	s := store.New(client, cfg)
+
+	children, err := s.QueryAllChildren(context.Background(), "parent#p1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(children) != 4 {
+		t.Errorf("expected one child per shard (4), got %d", len(children))
+	}
+}
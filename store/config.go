@@ -1,5 +1,7 @@
 package store
 
+import "time"
+
 // Config holds configuration for the Store.
 type Config struct {
 	// RelationshipTable is the name of the relationship table.
@@ -24,6 +26,52 @@ type Config struct {
 	//   - NumShards=16:  16,000 writes/sec,  48,000 reads/sec per parent
 	//   - NumShards=256: 256,000 writes/sec, 768,000 reads/sec per parent
 	NumShards int
+
+	// TrackChildCount maintains a child_count attribute on every parent,
+	// incremented by Create and decremented by Delete in the same
+	// transaction as the child, so OrphanProtect is enforced atomically by
+	// the delete's condition instead of by a prior query.
+	//
+	// Counts are only as complete as the history they have seen: enable it
+	// before creating children, or backfill child_count first. Descendants
+	// deleted by a cascade stay counted on their (also deleted) parents
+	// until Restore recounts them.
+	// Default: false
+	TrackChildCount bool
+
+	// StorePath stores a materialized path on every entity created: the
+	// references of its ancestors, root first, in the _path attribute.
+	// Create reads the parent to extend its path and Move rewrites the
+	// paths of the moved subtree, so HasAncestor answers from one read.
+	// Parents created before paths were stored are resolved through the
+	// registry.
+	// Default: false
+	StorePath bool
+
+	// AncestorCacheTTL caches the ancestors read by Ancestors and
+	// HasAncestor for this long (0 = no caching). Writes through this Store
+	// evict the entity written; writes made elsewhere may be served stale
+	// until the TTL expires.
+	// Default: 0
+	AncestorCacheTTL time.Duration
+
+	// TrackCascadeJobs records a cascade job in CascadeJobTable for every
+	// Delete with Cascade set and not Sync, which the stream handler
+	// updates as it deletes the subtree. See CascadeStatus.
+	// Default: false
+	TrackCascadeJobs bool
+
+	// CheckpointCascades lets the stream handler spread the cascade of an
+	// entity with many children over several invocations, recording its
+	// progress in CascadeJobTable (see SetCascadeCheckpoint). The handler
+	// resumes from that table's stream, so it must process it too.
+	// Default: false
+	CheckpointCascades bool
+
+	// CascadeJobTable is the name of the table of cascade jobs and
+	// checkpoints.
+	// Default: "trellis_cascade_jobs"
+	CascadeJobTable string
 }
 
 // DefaultConfig returns sensible defaults for small datasets.
@@ -31,6 +79,7 @@ func DefaultConfig() Config {
 	return Config{
 		RelationshipTable: "trellis_relationships",
 		UniqueTable:       "trellis_unique_constraints",
+		CascadeJobTable:   "trellis_cascade_jobs",
 		NumShards:         1,
 	}
 }
@@ -43,6 +92,9 @@ func (c *Config) validate() {
 	if c.UniqueTable == "" {
 		c.UniqueTable = "trellis_unique_constraints"
 	}
+	if c.CascadeJobTable == "" {
+		c.CascadeJobTable = "trellis_cascade_jobs"
+	}
 	if c.NumShards < 1 {
 		c.NumShards = 1
 	}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// cursorValue is the JSON form of a key attribute in a cursor.
+// Key attributes are always scalars, so only S, N and B are supported.
+type cursorValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+	B []byte  `json:"B,omitempty"`
+}
+
+// cursorScope identifies what a cursor pages through, so a cursor is only
+// accepted by the listing it came from.
+type cursorScope struct {
+	// table is the table read.
+	table string
+
+	// partition identifies the part of the table read: the parent for
+	// ListChildren, the index and key condition for a query, or the index
+	// for a scan.
+	partition string
+}
+
+// cursorData is the JSON form of a cursor.
+type cursorData struct {
+	Table     string                 `json:"t"`
+	Partition string                 `json:"p,omitempty"`
+	Key       map[string]cursorValue `json:"k"`
+}
+
+// encodeCursor encodes a LastEvaluatedKey and the scope it belongs to as an
+// opaque, URL-safe cursor. It returns an empty string when key is empty (no
+// more pages).
+func encodeCursor(scope cursorScope, key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	raw := make(map[string]cursorValue, len(key))
+	for name, av := range key {
+		v, ok := toCursorValue(av)
+		if !ok {
+			return "", ErrInvalidCursor
+		}
+		raw[name] = v
+	}
+	data, err := json.Marshal(cursorData{Table: scope.table, Partition: scope.partition, Key: raw})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a cursor produced by encodeCursor for scope.
+// An empty cursor decodes to a nil key (start from the beginning). A cursor
+// from another table or partition fails with ErrInvalidCursor.
+func decodeCursor(scope cursorScope, cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var raw cursorData
+	if err := json.Unmarshal(data, &raw); err != nil || len(raw.Key) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if raw.Table != scope.table || raw.Partition != scope.partition {
+		return nil, ErrInvalidCursor
+	}
+	key := make(map[string]types.AttributeValue, len(raw.Key))
+	for name, v := range raw.Key {
+		switch {
+		case v.S != nil:
+			key[name] = &types.AttributeValueMemberS{Value: *v.S}
+		case v.N != nil:
+			key[name] = &types.AttributeValueMemberN{Value: *v.N}
+		case v.B != nil:
+			key[name] = &types.AttributeValueMemberB{Value: v.B}
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return key, nil
+}
+
+// toCursorValue converts a scalar attribute value to its cursor form.
+func toCursorValue(av types.AttributeValue) (cursorValue, bool) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return cursorValue{S: &v.Value}, true
+	case *types.AttributeValueMemberN:
+		return cursorValue{N: &v.Value}, true
+	case *types.AttributeValueMemberB:
+		return cursorValue{B: v.Value}, true
+	default:
+		return cursorValue{}, false
+	}
+}
+
+// placeholderPattern matches the name and value placeholders of an
+// expression.
+var placeholderPattern = regexp.MustCompile(`[#:][A-Za-z0-9_]+`)
+
+// queryCursorScope returns the scope of QueryPage cursors for input: its
+// table, and a digest of its index, key condition and the placeholders the
+// key condition uses.
+func queryCursorScope(input *QueryInput) cursorScope {
+	h := sha256.New()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	write(input.IndexName)
+	write(input.KeyConditionExpression)
+
+	placeholders := placeholderPattern.FindAllString(input.KeyConditionExpression, -1)
+	slices.Sort(placeholders)
+	for _, p := range slices.Compact(placeholders) {
+		write(p)
+		if p[0] == '#' {
+			write(input.ExpressionAttributeNames[p])
+			continue
+		}
+		v, _ := toCursorValue(input.ExpressionAttributeValues[p])
+		data, _ := json.Marshal(v)
+		write(string(data))
+	}
+	return cursorScope{
+		table:     input.TableName,
+		partition: base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:12]),
+	}
+}
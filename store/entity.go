@@ -40,7 +40,7 @@ type ConditionCheck struct {
 	Key       PK
 
 	// ConditionExpr is an optional custom condition expression.
-	// If empty, ParentExistsCondition() is used (checks existence and not deleted).
+	// If empty, ActiveParentCondition() is used (checks existence and not deleted).
 	ConditionExpr string
 }
 
@@ -70,6 +70,21 @@ type Item struct {
 
 	// ParentRef is the parent's entity reference (empty for root entities).
 	ParentRef string
+
+	// DeletedAt is the Unix time the entity was deleted (zero if active).
+	DeletedAt int64
+
+	// TTL is the Unix time after which DynamoDB may purge the entity
+	// (zero if active).
+	TTL int64
+
+	// ChildCount is the number of active children, when
+	// Config.TrackChildCount is set.
+	ChildCount int64
+
+	// Path is the references of the entity's ancestors, root first, when
+	// Config.StorePath is set.
+	Path []string
 }
 
 // ChildRef represents a reference to a child entity in the relationship table.
@@ -87,6 +102,12 @@ type ChildRef struct {
 	ShardPK string
 }
 
+// TableKey identifies an item by table and primary key.
+type TableKey struct {
+	TableName string
+	Key       PK
+}
+
 // QueryInput defines parameters for querying entities.
 type QueryInput struct {
 	// TableName is the DynamoDB table to query.
@@ -107,9 +128,48 @@ type QueryInput struct {
 	// ExpressionAttributeValues maps expression attribute value placeholders.
 	ExpressionAttributeValues map[string]types.AttributeValue
 
-	// Limit is the maximum number of items to return (0 = no limit).
+	// Limit is the maximum number of items to evaluate per page (0 = no limit).
+	// The TTL filter is applied after the limit, so pages may be short.
 	Limit int32
 
 	// ScanIndexForward determines sort order (true = ascending, false = descending).
 	ScanIndexForward *bool
 }
+
+// ScanInput defines parameters for scanning a table.
+type ScanInput struct {
+	// TableName is the DynamoDB table to scan.
+	TableName string
+
+	// IndexName is the optional GSI/LSI to scan.
+	IndexName string
+
+	// FilterExpression is an optional filter (TTL filter is automatically merged).
+	FilterExpression string
+
+	// ExpressionAttributeNames maps expression attribute name placeholders.
+	ExpressionAttributeNames map[string]string
+
+	// ExpressionAttributeValues maps expression attribute value placeholders.
+	ExpressionAttributeValues map[string]types.AttributeValue
+
+	// Limit is the maximum number of items to evaluate per page (0 = no limit).
+	Limit int32
+
+	// TotalSegments splits the scan into parallel segments (0 or 1 = sequential).
+	TotalSegments int32
+
+	// Concurrency is the maximum number of segments scanned at once.
+	// Default: TotalSegments
+	Concurrency int
+}
+
+// Page is a single page of query results.
+type Page struct {
+	// Items are the active items in this page.
+	Items []*Item
+
+	// Cursor resumes the query after this page.
+	// Empty when there are no more pages.
+	Cursor string
+}
@@ -1,6 +1,9 @@
 package store
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrParentNotFound is returned when the parent entity doesn't exist or is deleted.
@@ -23,4 +26,75 @@ var (
 
 	// ErrAlreadyDeleted is returned when attempting to delete an already-deleted entity.
 	ErrAlreadyDeleted = errors.New("trellis: entity is already deleted")
+
+	// ErrNotDeleted is returned when attempting to restore an entity that is not deleted.
+	ErrNotDeleted = errors.New("trellis: entity is not deleted")
+
+	// ErrCascadeLimit is returned when a synchronous cascade would exceed its depth or item limit.
+	ErrCascadeLimit = errors.New("trellis: cascade exceeds configured limits")
+
+	// ErrUnprocessedKeys is returned when DynamoDB leaves keys unprocessed after all retries.
+	ErrUnprocessedKeys = errors.New("trellis: keys remained unprocessed after retries")
+
+	// ErrInvalidTransaction is returned when staged operations cannot form a single transaction.
+	ErrInvalidTransaction = errors.New("trellis: invalid transaction")
+
+	// ErrInvalidMove is returned when Move is not given one entity under two different parents,
+	// or the new parent is the entity itself or one of its descendants.
+	ErrInvalidMove = errors.New("trellis: invalid move")
+
+	// ErrUnknownEntityType is returned when an entity reference's type is not in the registry.
+	ErrUnknownEntityType = errors.New("trellis: entity type not registered")
+
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	// or comes from another table or partition.
+	ErrInvalidCursor = errors.New("trellis: invalid pagination cursor")
 )
+
+// DuplicateValueError is returned when a unique constraint is violated.
+// It identifies the conflicting field and matches ErrDuplicateValue with errors.Is.
+type DuplicateValueError struct {
+	// EntityType is the type of the entity being written.
+	EntityType string
+
+	// Field is the unique field whose value collided.
+	Field string
+
+	// Value is the conflicting value.
+	Value string
+}
+
+func (e *DuplicateValueError) Error() string {
+	return fmt.Sprintf("trellis: duplicate value %q for unique field %s.%s", e.Value, e.EntityType, e.Field)
+}
+
+// Is reports whether target is ErrDuplicateValue.
+func (e *DuplicateValueError) Is(target error) bool {
+	return target == ErrDuplicateValue
+}
+
+// ConditionFailedError is returned when a transaction is cancelled because
+// the condition on one of its items failed. Err is the mapped error, such as
+// ErrParentNotFound or a *DuplicateValueError, and is matched by errors.Is
+// and errors.As.
+type ConditionFailedError struct {
+	// Index is the position of the failed item in the transaction.
+	Index int
+
+	// Op is the item's operation: ConditionCheck, Put, Update or Delete.
+	Op string
+
+	// TableName is the table the item targets.
+	TableName string
+
+	// Err is the mapped error.
+	Err error
+}
+
+func (e *ConditionFailedError) Error() string {
+	return fmt.Sprintf("%v (transaction item %d: %s on %s)", e.Err, e.Index, e.Op, e.TableName)
+}
+
+func (e *ConditionFailedError) Unwrap() error {
+	return e.Err
+}
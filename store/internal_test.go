@@ -8,76 +8,6 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-// --- joinStrings Tests ---
-
-func TestJoinStrings_Empty(t *testing.T) {
-	result := joinStrings([]string{}, ", ")
-	if result != "" {
-		t.Errorf("expected empty string for empty slice, got %q", result)
-	}
-}
-
-func TestJoinStrings_Single(t *testing.T) {
-	result := joinStrings([]string{"one"}, ", ")
-	if result != "one" {
-		t.Errorf("expected 'one', got %q", result)
-	}
-}
-
-func TestJoinStrings_Multiple(t *testing.T) {
-	result := joinStrings([]string{"a", "b", "c"}, ", ")
-	if result != "a, b, c" {
-		t.Errorf("expected 'a, b, c', got %q", result)
-	}
-}
-
-func TestJoinStrings_EmptySeparator(t *testing.T) {
-	result := joinStrings([]string{"a", "b", "c"}, "")
-	if result != "abc" {
-		t.Errorf("expected 'abc', got %q", result)
-	}
-}
-
-func TestJoinStrings_DifferentSeparators(t *testing.T) {
-	tests := []struct {
-		name     string
-		strs     []string
-		sep      string
-		expected string
-	}{
-		{"comma", []string{"a", "b"}, ",", "a,b"},
-		{"space", []string{"a", "b"}, " ", "a b"},
-		{"newline", []string{"a", "b"}, "\n", "a\nb"},
-		{"multi-char", []string{"a", "b"}, " AND ", "a AND b"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := joinStrings(tt.strs, tt.sep)
-			if result != tt.expected {
-				t.Errorf("expected %q, got %q", tt.expected, result)
-			}
-		})
-	}
-}
-
-func TestJoinStrings_EmptyStrings(t *testing.T) {
-	result := joinStrings([]string{"", "", ""}, ", ")
-	if result != ", , " {
-		t.Errorf("expected ', , ', got %q", result)
-	}
-}
-
-func TestJoinStrings_UpdateExpression(t *testing.T) {
-	// Test a realistic use case: building SET clauses
-	clauses := []string{"#attr0 = :val0", "#attr1 = :val1", "#updated_at = :updated_at"}
-	result := joinStrings(clauses, ", ")
-	expected := "#attr0 = :val0, #attr1 = :val1, #updated_at = :updated_at"
-	if result != expected {
-		t.Errorf("expected %q, got %q", expected, result)
-	}
-}
-
 // --- unmarshalItem Tests ---
 
 func TestUnmarshalItem_Full(t *testing.T) {
@@ -305,92 +235,128 @@ func TestUnmarshalChildRef_WrongKeyType(t *testing.T) {
 	}
 }
 
-// --- mapCreateTransactionError Tests ---
+// --- mapTransactionError Tests ---
 
-func TestMapCreateTransactionError_NilError(t *testing.T) {
-	s := &Store{}
-	err := s.mapCreateTransactionError(nil, 0, 1)
+// testCreateTransaction returns the items and failure mapping of a create
+// transaction: parent check, unique put, entity put, relationship put.
+func testCreateTransaction() ([]types.TransactWriteItem, []error) {
+	items := []types.TransactWriteItem{
+		{ConditionCheck: &types.ConditionCheck{TableName: aws.String("parents")}},
+		{Put: &types.Put{TableName: aws.String("unique")}},
+		{Put: &types.Put{TableName: aws.String("children")}},
+		{Put: &types.Put{TableName: aws.String("relationships")}},
+	}
+	failures := []error{
+		ErrParentNotFound,
+		&DuplicateValueError{EntityType: "child", Field: "name", Value: "dup"},
+		ErrAlreadyExists,
+		nil,
+	}
+	return items, failures
+}
+
+func TestMapTransactionError_NilError(t *testing.T) {
+	items, failures := testCreateTransaction()
+	err := mapTransactionError(nil, items, failures)
 	if err != nil {
 		t.Errorf("expected nil, got %v", err)
 	}
 }
 
-func TestMapCreateTransactionError_NonTransactionError(t *testing.T) {
-	s := &Store{}
+func TestMapTransactionError_NonTransactionError(t *testing.T) {
+	items, failures := testCreateTransaction()
 	originalErr := errors.New("some other error")
-	err := s.mapCreateTransactionError(originalErr, 0, 1)
+	err := mapTransactionError(originalErr, items, failures)
 	if err != originalErr {
 		t.Errorf("expected original error, got %v", err)
 	}
 }
 
-func TestMapCreateTransactionError_ParentCheckFailure(t *testing.T) {
-	s := &Store{}
+func TestMapTransactionError_ParentCheckFailure(t *testing.T) {
+	items, failures := testCreateTransaction()
 	code := "ConditionalCheckFailed"
 	txErr := &types.TransactionCanceledException{
 		CancellationReasons: []types.CancellationReason{
 			{Code: &code}, // Index 0 - parent check
-			{},            // Index 1 - entity put
+			{}, {}, {},
 		},
 	}
 
-	err := s.mapCreateTransactionError(txErr, 0, 1)
+	err := mapTransactionError(txErr, items, failures)
 	if !errors.Is(err, ErrParentNotFound) {
 		t.Errorf("expected ErrParentNotFound, got %v", err)
 	}
+	var condErr *ConditionFailedError
+	if !errors.As(err, &condErr) || condErr.Index != 0 || condErr.Op != "ConditionCheck" || condErr.TableName != "parents" {
+		t.Errorf("expected ConditionFailedError for item 0, got %#v", err)
+	}
 }
 
-func TestMapCreateTransactionError_EntityPutFailure(t *testing.T) {
-	s := &Store{}
+func TestMapTransactionError_EntityPutFailure(t *testing.T) {
+	items, failures := testCreateTransaction()
 	code := "ConditionalCheckFailed"
 	txErr := &types.TransactionCanceledException{
 		CancellationReasons: []types.CancellationReason{
-			{},            // Index 0 - parent check (no failure)
-			{Code: &code}, // Index 1 - entity put
+			{}, {},
+			{Code: &code}, // Index 2 - entity put
+			{},
 		},
 	}
 
-	err := s.mapCreateTransactionError(txErr, 0, 1)
+	err := mapTransactionError(txErr, items, failures)
 	if !errors.Is(err, ErrAlreadyExists) {
 		t.Errorf("expected ErrAlreadyExists, got %v", err)
 	}
+	var condErr *ConditionFailedError
+	if !errors.As(err, &condErr) || condErr.Index != 2 || condErr.Op != "Put" {
+		t.Errorf("expected ConditionFailedError for item 2, got %#v", err)
+	}
 }
 
-func TestMapCreateTransactionError_UniqueConstraintFailure(t *testing.T) {
-	s := &Store{}
+func TestMapTransactionError_UniqueConstraintFailure(t *testing.T) {
+	items, failures := testCreateTransaction()
 	code := "ConditionalCheckFailed"
 	txErr := &types.TransactionCanceledException{
 		CancellationReasons: []types.CancellationReason{
-			{},            // Index 0 - parent check
-			{},            // Index 1 - entity put
-			{Code: &code}, // Index 2 - unique constraint
+			{},
+			{Code: &code}, // Index 1 - unique constraint
+			{}, {},
 		},
 	}
 
-	err := s.mapCreateTransactionError(txErr, 0, 1)
+	err := mapTransactionError(txErr, items, failures)
 	if !errors.Is(err, ErrDuplicateValue) {
 		t.Errorf("expected ErrDuplicateValue, got %v", err)
 	}
+	var dupErr *DuplicateValueError
+	if !errors.As(err, &dupErr) || dupErr.Field != "name" || dupErr.Value != "dup" || dupErr.EntityType != "child" {
+		t.Errorf("expected DuplicateValueError for child.name, got %#v", err)
+	}
 }
 
-func TestMapCreateTransactionError_NoParentCheck(t *testing.T) {
-	s := &Store{}
+func TestMapTransactionError_VersionConditionFailure(t *testing.T) {
+	items := []types.TransactWriteItem{
+		{Delete: &types.Delete{TableName: aws.String("unique")}},
+		{Put: &types.Put{TableName: aws.String("unique")}},
+		{Update: &types.Update{TableName: aws.String("children")}},
+	}
+	failures := []error{nil, &DuplicateValueError{Field: "slug"}, ErrConcurrentModification}
 	code := "ConditionalCheckFailed"
 	txErr := &types.TransactionCanceledException{
 		CancellationReasons: []types.CancellationReason{
-			{Code: &code}, // Index 0 - entity put (parentCheckIndex is -1)
+			{}, {},
+			{Code: &code}, // Index 2 - entity update
 		},
 	}
 
-	// parentCheckIndex = -1 means no parent check
-	err := s.mapCreateTransactionError(txErr, -1, 0)
-	if !errors.Is(err, ErrAlreadyExists) {
-		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	err := mapTransactionError(txErr, items, failures)
+	if !errors.Is(err, ErrConcurrentModification) || errors.Is(err, ErrDuplicateValue) {
+		t.Errorf("expected ErrConcurrentModification, got %v", err)
 	}
 }
 
-func TestMapCreateTransactionError_OtherCancellationCode(t *testing.T) {
-	s := &Store{}
+func TestMapTransactionError_OtherCancellationCode(t *testing.T) {
+	items, failures := testCreateTransaction()
 	code := "TransactionConflict" // Different code
 	txErr := &types.TransactionCanceledException{
 		CancellationReasons: []types.CancellationReason{
@@ -398,90 +364,40 @@ func TestMapCreateTransactionError_OtherCancellationCode(t *testing.T) {
 		},
 	}
 
-	err := s.mapCreateTransactionError(txErr, 0, 1)
+	err := mapTransactionError(txErr, items, failures)
 	// Should return original error when code is not ConditionalCheckFailed
-	if err == nil || errors.Is(err, ErrParentNotFound) || errors.Is(err, ErrAlreadyExists) {
-		t.Error("expected original transaction error")
+	if err != txErr {
+		t.Errorf("expected original transaction error, got %v", err)
 	}
 }
 
-func TestMapCreateTransactionError_NilCode(t *testing.T) {
-	s := &Store{}
+func TestMapTransactionError_NilCode(t *testing.T) {
+	items, failures := testCreateTransaction()
 	txErr := &types.TransactionCanceledException{
 		CancellationReasons: []types.CancellationReason{
 			{Code: nil}, // Nil code
 		},
 	}
 
-	err := s.mapCreateTransactionError(txErr, 0, 1)
+	err := mapTransactionError(txErr, items, failures)
 	// Should return original error when code is nil
 	if err != txErr {
 		t.Errorf("expected original error for nil code, got %v", err)
 	}
 }
 
-// --- mapUpdateTransactionError Tests ---
-
-func TestMapUpdateTransactionError_NilError(t *testing.T) {
-	s := &Store{}
-	err := s.mapUpdateTransactionError(nil)
-	if err != nil {
-		t.Errorf("expected nil, got %v", err)
-	}
-}
-
-func TestMapUpdateTransactionError_NonTransactionError(t *testing.T) {
-	s := &Store{}
-	originalErr := errors.New("some other error")
-	err := s.mapUpdateTransactionError(originalErr)
-	if err != originalErr {
-		t.Errorf("expected original error, got %v", err)
-	}
-}
-
-func TestMapUpdateTransactionError_ConditionalCheckFailed(t *testing.T) {
-	s := &Store{}
-	code := "ConditionalCheckFailed"
-	txErr := &types.TransactionCanceledException{
-		CancellationReasons: []types.CancellationReason{
-			{Code: &code},
-		},
-	}
-
-	err := s.mapUpdateTransactionError(txErr)
-	if !errors.Is(err, ErrDuplicateValue) {
-		t.Errorf("expected ErrDuplicateValue, got %v", err)
-	}
-}
-
-func TestMapUpdateTransactionError_OtherCode(t *testing.T) {
-	s := &Store{}
-	code := "TransactionConflict"
-	txErr := &types.TransactionCanceledException{
-		CancellationReasons: []types.CancellationReason{
-			{Code: &code},
-		},
-	}
-
-	err := s.mapUpdateTransactionError(txErr)
-	// Should return original error for other codes
-	if err == nil || errors.Is(err, ErrDuplicateValue) {
-		t.Error("expected original error")
-	}
-}
-
-func TestMapUpdateTransactionError_MultipleReasons(t *testing.T) {
-	s := &Store{}
+func TestMapTransactionError_MultipleReasons(t *testing.T) {
+	items, failures := testCreateTransaction()
 	code := "ConditionalCheckFailed"
 	txErr := &types.TransactionCanceledException{
 		CancellationReasons: []types.CancellationReason{
 			{Code: nil},
 			{Code: &code}, // Second reason is the failure
-			{Code: nil},
+			{Code: &code},
 		},
 	}
 
-	err := s.mapUpdateTransactionError(txErr)
+	err := mapTransactionError(txErr, items, failures)
 	if !errors.Is(err, ErrDuplicateValue) {
 		t.Errorf("expected ErrDuplicateValue, got %v", err)
 	}
@@ -588,3 +504,122 @@ func TestStore_RelationshipPK_SingleShard(t *testing.T) {
 
 // Ensure aws import is used
 var _ = aws.String("test")
+
+// --- Cursor Tests ---
+
+func TestCursor_RoundTrip(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"pk":        &types.AttributeValueMemberS{Value: "parent#1#00"},
+		"child_ref": &types.AttributeValueMemberS{Value: "child/with+chars"},
+		"n":         &types.AttributeValueMemberN{Value: "42"},
+		"b":         &types.AttributeValueMemberB{Value: []byte{0xff, 0x00}},
+	}
+	scope := cursorScope{table: "relationships", partition: "parent#1"}
+	cursor, err := encodeCursor(scope, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, c := range cursor {
+		if c == '+' || c == '/' || c == '=' {
+			t.Fatalf("cursor %q is not URL-safe", cursor)
+		}
+	}
+
+	decoded, err := decodeCursor(scope, cursor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := decoded["child_ref"].(*types.AttributeValueMemberS).Value; v != "child/with+chars" {
+		t.Errorf("expected child_ref to round-trip, got %q", v)
+	}
+	if v := decoded["n"].(*types.AttributeValueMemberN).Value; v != "42" {
+		t.Errorf("expected n to round-trip, got %q", v)
+	}
+	if v := decoded["b"].(*types.AttributeValueMemberB).Value; len(v) != 2 || v[0] != 0xff {
+		t.Errorf("expected b to round-trip, got %v", v)
+	}
+}
+
+func TestCursor_Empty(t *testing.T) {
+	cursor, err := encodeCursor(cursorScope{table: "t"}, nil)
+	if err != nil || cursor != "" {
+		t.Errorf("expected empty cursor, got %q (err %v)", cursor, err)
+	}
+	key, err := decodeCursor(cursorScope{table: "t"}, "")
+	if err != nil || key != nil {
+		t.Errorf("expected nil key, got %v (err %v)", key, err)
+	}
+}
+
+func TestCursor_Invalid(t *testing.T) {
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24", "e30", "eyJhIjp7fX0", `eyJ0IjoidCIsImsiOnsiYSI6e319fQ`} {
+		if _, err := decodeCursor(cursorScope{table: "t"}, cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: expected ErrInvalidCursor, got %v", cursor, err)
+		}
+	}
+	_, err := encodeCursor(cursorScope{table: "t"}, map[string]types.AttributeValue{"m": &types.AttributeValueMemberM{}})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for non-scalar key, got %v", err)
+	}
+}
+
+func TestCursor_Scope(t *testing.T) {
+	scope := cursorScope{table: "t", partition: "p"}
+	key := map[string]types.AttributeValue{"pk": &types.AttributeValueMemberS{Value: "x"}}
+	cursor, err := encodeCursor(scope, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, other := range []cursorScope{{table: "t"}, {table: "u", partition: "p"}} {
+		if _, err := decodeCursor(other, cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("scope %+v: expected ErrInvalidCursor, got %v", other, err)
+		}
+	}
+
+	// Query scopes differ by key condition value, not by filter value
+	input := func(pk, filter string) *QueryInput {
+		return &QueryInput{
+			TableName:              "t",
+			KeyConditionExpression: "pk = :pk",
+			FilterExpression:       "x = :x",
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: pk},
+				":x":  &types.AttributeValueMemberS{Value: filter},
+			},
+		}
+	}
+	if queryCursorScope(input("a", "1")) != queryCursorScope(input("a", "2")) {
+		t.Error("expected the filter value not to change the query scope")
+	}
+	if queryCursorScope(input("a", "1")) == queryCursorScope(input("b", "1")) {
+		t.Error("expected the partition key value to change the query scope")
+	}
+}
+
+// --- tableKeyString Tests ---
+
+func TestTableKeyString_NoCollisions(t *testing.T) {
+	s := func(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+	pairs := [][2]string{
+		{
+			tableKeyString("t", PK{"a": s("x|b=S:y")}),
+			tableKeyString("t", PK{"a": s("x"), "b": s("y")}),
+		},
+		{
+			tableKeyString("t|a=S:x", PK{"b": s("y")}),
+			tableKeyString("t", PK{"a": s("x"), "b": s("y")}),
+		},
+		{
+			tableKeyString("t", PK{"a=S:x|b": s("y")}),
+			tableKeyString("t", PK{"a": s("x"), "b": s("y")}),
+		},
+	}
+	for _, p := range pairs {
+		if p[0] == p[1] {
+			t.Errorf("expected distinct keys, both are %s", p[0])
+		}
+	}
+	if tableKeyString("t", PK{"a": s("x"), "b": s("y")}) != tableKeyString("t", PK{"b": s("y"), "a": s("x")}) {
+		t.Error("expected the same key to give the same string")
+	}
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// QueryIter returns an iterator over query results with automatic TTL
+// filtering. Pages are fetched lazily as the caller ranges over the
+// iterator, and no further pages are read once the loop exits.
+//
+// On failure the iterator yields a nil item and the error, then stops.
+func (s *Store) QueryIter(ctx context.Context, input *QueryInput) iter.Seq2[*Item, error] {
+	return func(yield func(*Item, error) bool) {
+		paginator := dynamodb.NewQueryPaginator(s.client, s.buildQueryInput(input))
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, raw := range page.Items {
+				if !yield(s.unmarshalItem(raw), nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// QueryAllChildrenIter returns an iterator over all children of an entity
+// (including deleted ones).
+//
+// With multiple shards, every shard is queried concurrently and children
+// are yielded in the order they arrive, so no ordering is guaranteed.
+// Outstanding shard queries are cancelled when the loop exits early or a
+// shard fails, and the iterator does not return until they have stopped.
+func (s *Store) QueryAllChildrenIter(ctx context.Context, parentRef string) iter.Seq2[ChildRef, error] {
+	numShards := s.config.NumShards
+	if numShards < 1 {
+		numShards = 1
+	}
+
+	// Fast path for single shard (default)
+	if numShards == 1 {
+		return func(yield func(ChildRef, error) bool) {
+			err := s.queryChildrenShard(ctx, fmt.Sprintf("%s#00", parentRef), func(child ChildRef) bool {
+				return yield(child, nil)
+			})
+			if err != nil {
+				yield(ChildRef{}, err)
+			}
+		}
+	}
+
+	return func(yield func(ChildRef, error) bool) {
+		// Multi-shard fan-out, merged as results arrive
+		ctx, cancel := context.WithCancel(ctx)
+
+		type result struct {
+			child ChildRef
+			err   error
+		}
+		results := make(chan result)
+		var wg sync.WaitGroup
+
+		for shardNum := 0; shardNum < numShards; shardNum++ {
+			wg.Add(1)
+			go func(shardNum int) {
+				defer wg.Done()
+
+				send := func(r result) bool {
+					select {
+					case results <- r:
+						return true
+					case <-ctx.Done():
+						return false
+					}
+				}
+
+				shardPK := fmt.Sprintf("%s#%02x", parentRef, shardNum)
+				err := s.queryChildrenShard(ctx, shardPK, func(child ChildRef) bool {
+					return send(result{child: child})
+				})
+				if err != nil {
+					send(result{err: fmt.Errorf("shard %02x: %w", shardNum, err)})
+				}
+			}(shardNum)
+		}
+
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		// Stop outstanding shard queries and wait for them to exit
+		defer func() {
+			cancel()
+			for range results {
+			}
+		}()
+
+		for r := range results {
+			if r.err != nil {
+				yield(ChildRef{}, r.err)
+				return
+			}
+			if !yield(r.child, nil) {
+				return
+			}
+		}
+	}
+}
+
+// queryChildrenShard pages through one relationship shard, passing each
+// child to fn until fn returns false.
+func (s *Store) queryChildrenShard(ctx context.Context, shardPK string, fn func(ChildRef) bool) error {
+	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
+		TableName:              aws.String(s.config.RelationshipTable),
+		KeyConditionExpression: aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: shardPK},
+		},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Items {
+			if !fn(s.unmarshalChildRef(item, shardPK)) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
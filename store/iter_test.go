@@ -0,0 +1,183 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/internal/shard"
+	"github.com/jacentio/trellis/store"
+	"github.com/jacentio/trellis/storetest"
+)
+
+// seedChildren creates parent p1 with count children.
+func seedChildren(t *testing.T, s *store.Store, count int) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		id := fmt.Sprintf("c%02d", i)
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, id)); err != nil {
+			t.Fatalf("create child %s: %v", id, err)
+		}
+	}
+}
+
+// countQueries counts Query calls made against db.
+func countQueries(db *storetest.DB) *atomic.Int32 {
+	var n atomic.Int32
+	db.SetInterceptor(func(_ context.Context, op string, _ any) error {
+		if op == "Query" {
+			n.Add(1)
+		}
+		return nil
+	})
+	return &n
+}
+
+func TestQueryIter_StopsEarly(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	seedChildren(t, s, 10)
+	queries := countQueries(db)
+
+	input := &store.QueryInput{
+		TableName:              store.DefaultConfig().RelationshipTable,
+		KeyConditionExpression: "pk = :pk",
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "parent#p1#00"},
+		},
+		Limit: 2,
+	}
+
+	var seen int
+	for item, err := range s.QueryIter(context.Background(), input) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if item.Raw["child_ref"] == nil {
+			t.Error("expected relationship item")
+		}
+		seen++
+		if seen == 3 {
+			break
+		}
+	}
+
+	if seen != 3 {
+		t.Errorf("expected 3 items, got %d", seen)
+	}
+	if n := queries.Load(); n != 2 {
+		t.Errorf("expected 2 page reads, got %d", n)
+	}
+}
+
+func TestQueryIter_Error(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	boom := errors.New("boom")
+	db.SetInterceptor(func(context.Context, string, any) error { return boom })
+
+	input := &store.QueryInput{
+		TableName:              "parents",
+		KeyConditionExpression: "id = :id",
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id": &types.AttributeValueMemberS{Value: "p1"},
+		},
+	}
+	var errs int
+	for item, err := range s.QueryIter(context.Background(), input) {
+		if !errors.Is(err, boom) || item != nil {
+			t.Errorf("expected nil item and injected error, got %v, %v", item, err)
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("expected exactly one error, got %d", errs)
+	}
+}
+
+func TestQueryAllChildrenIter_MultiShard(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 4
+	s, _ := newMemStore(t, cfg)
+	seedChildren(t, s, 12)
+
+	seen := make(map[string]bool)
+	for child, err := range s.QueryAllChildrenIter(context.Background(), "parent#p1") {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if seen[child.Ref] {
+			t.Errorf("child %s yielded twice", child.Ref)
+		}
+		seen[child.Ref] = true
+		if child.TableName != "children" || child.Key["id"] == nil {
+			t.Errorf("unexpected child ref: %+v", child)
+		}
+	}
+	if len(seen) != 12 {
+		t.Errorf("expected 12 children, got %d", len(seen))
+	}
+}
+
+func TestQueryAllChildrenIter_BreakCancelsShards(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 8
+	s, db := newMemStore(t, cfg)
+	seedChildren(t, s, 20)
+
+	// Every shard but the one holding c00 blocks until cancelled, so the
+	// loop below only returns if breaking cancels outstanding queries.
+	open := shard.RelationshipPK("parent#p1", "child#c00", cfg.NumShards)
+	db.SetInterceptor(func(ctx context.Context, op string, input any) error {
+		in, ok := input.(*dynamodb.QueryInput)
+		if !ok || op != "Query" {
+			return nil
+		}
+		if in.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value != open {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	var seen int
+	for _, err := range s.QueryAllChildrenIter(context.Background(), "parent#p1") {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		seen++
+		break
+	}
+	if seen != 1 {
+		t.Errorf("expected 1 child, got %d", seen)
+	}
+}
+
+func TestQueryAllChildrenIter_ShardError(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 4
+	s, db := newMemStore(t, cfg)
+	seedChildren(t, s, 4)
+
+	boom := errors.New("boom")
+	db.SetInterceptor(func(_ context.Context, op string, input any) error {
+		if in, ok := input.(*dynamodb.QueryInput); ok && op == "Query" {
+			if pk := in.ExpressionAttributeValues[":pk"].(*types.AttributeValueMemberS).Value; pk == "parent#p1#02" {
+				return boom
+			}
+		}
+		return nil
+	})
+
+	_, err := s.QueryAllChildren(context.Background(), "parent#p1")
+	if !errors.Is(err, boom) {
+		t.Errorf("expected shard error, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/internal/shard"
+)
+
+// Move reparents an entity. from and to are the same entity under its
+// current and its new parent; item holds attributes to set alongside, such
+// as the new parent's ID, and may be nil.
+//
+// In one transaction Move checks that the new parent exists and is active,
+// rewrites parent_ref with a version check, replaces the relationship record
+// and re-keys every unique constraint to the new parent's scope, claiming
+// the values of to's UniqueFields. It fails with ErrParentNotFound if the
+// new parent is missing or deleted, a *DuplicateValueError if a unique value
+// is taken under the new parent, and ErrConcurrentModification unless the
+// entity is at expectedVersion under from's parent.
+//
+// Moving an entity under itself or one of its descendants fails with
+// ErrInvalidMove. The new parent's ancestors are read for this as in
+// Ancestors, so without Config.StorePath the type of every ancestor, root
+// types included, must be registered with RegisterTable, or Move fails with
+// ErrUnknownEntityType.
+//
+// Descendants are unaffected: their relationship records and constraints are
+// scoped to the moved entity, whose reference does not change. With
+// Config.StorePath, the entity's path is rewritten in the transaction; the
+// paths of its descendants are rewritten after the transaction commits, and
+// if that fails the error is returned with the move already committed.
+func (s *Store) Move(ctx context.Context, from, to Entity, item map[string]types.AttributeValue, expectedVersion int64) error {
+	fromPC, _ := from.(ParentChecker)
+	toPC, _ := to.(ParentChecker)
+	switch {
+	case from.TableName() != to.TableName() || from.EntityRef() != to.EntityRef():
+		return fmt.Errorf("%w: %s and %s are different entities", ErrInvalidMove, from.EntityRef(), to.EntityRef())
+	case fromPC == nil || fromPC.ParentRef() == "" || toPC == nil || toPC.ParentRef() == "":
+		return fmt.Errorf("%w: %s has no parent", ErrInvalidMove, to.EntityRef())
+	case fromPC.ParentRef() == toPC.ParentRef():
+		return fmt.Errorf("%w: %s is already under %s", ErrInvalidMove, to.EntityRef(), toPC.ParentRef())
+	}
+	oldParentRef, newParentRef := fromPC.ParentRef(), toPC.ParentRef()
+	if newParentRef == to.EntityRef() {
+		return fmt.Errorf("%w: %s cannot be its own parent", ErrInvalidMove, to.EntityRef())
+	}
+
+	// The old constraints are read from the item; pinning its version keeps
+	// them current until the transaction commits
+	raw, err := s.getRaw(ctx, to.TableName(), to.GetKey())
+	if err != nil {
+		return err
+	}
+	if IsDeleted(raw) {
+		return ErrNotFound
+	}
+	if current := s.unmarshalItem(raw); current.Version != expectedVersion || current.ParentRef != oldParentRef {
+		return ErrConcurrentModification
+	}
+
+	var path []string
+	if s.config.StorePath {
+		if path, err = s.childPath(ctx, to, nil, 0); err != nil {
+			return err
+		}
+		if slices.Contains(path, to.EntityRef()) {
+			return fmt.Errorf("%w: %s is an ancestor of %s", ErrInvalidMove, to.EntityRef(), newParentRef)
+		}
+	} else if err := s.checkNotAncestor(ctx, toPC, to.EntityRef()); err != nil {
+		return err
+	}
+
+	var items []types.TransactWriteItem
+	var failures []error
+	add := func(txItem types.TransactWriteItem, failure error) {
+		items = append(items, txItem)
+		failures = append(failures, failure)
+	}
+
+	if check := toPC.ParentCheck(); check != nil {
+		checkItem := parentCheckItem(check, time.Now())
+		if s.config.TrackChildCount {
+			checkItem = withChildCount(checkItem, 1)
+		}
+		add(checkItem, ErrParentNotFound)
+	}
+
+	// Re-key unique constraints to the new parent's scope
+	oldPKs, hasOldPKs := raw["_unique_pks"].(*types.AttributeValueMemberL)
+	if hasOldPKs {
+		for _, v := range oldPKs.Value {
+			if pk, ok := v.(*types.AttributeValueMemberS); ok {
+				add(types.TransactWriteItem{
+					Delete: &types.Delete{
+						TableName: aws.String(s.config.UniqueTable),
+						Key: PK{
+							"pk": pk,
+							"sk": &types.AttributeValueMemberS{Value: "CONSTRAINT"},
+						},
+					},
+				}, nil)
+			}
+		}
+	}
+	var uniquePKs []string
+	if uf, ok := to.(UniqueFielder); ok {
+		entityType := to.EntityType()
+		for field, value := range uf.UniqueFields() {
+			uniquePKs = append(uniquePKs, shard.UniqueConstraintPK(newParentRef, entityType, field, value))
+			_, put := s.uniqueConstraintPut(newParentRef, entityType, field, value, to.EntityRef())
+			add(put, &DuplicateValueError{EntityType: entityType, Field: field, Value: value})
+		}
+	}
+
+	update, err := s.buildEntityUpdate(to, item, expectedVersion, uniquePKs)
+	if err != nil {
+		return err
+	}
+	update.ExpressionAttributeNames["#parent_ref"] = "parent_ref"
+	update.ExpressionAttributeValues[":parent_ref"] = &types.AttributeValueMemberS{Value: newParentRef}
+	update.UpdateExpression = aws.String(aws.ToString(update.UpdateExpression) + ", #parent_ref = :parent_ref")
+	if path != nil {
+		v, err := pathValue(path)
+		if err != nil {
+			return err
+		}
+		update.ExpressionAttributeNames["#path"] = "_path"
+		update.ExpressionAttributeValues[":path"] = v
+		update.UpdateExpression = aws.String(aws.ToString(update.UpdateExpression) + ", #path = :path")
+	}
+	if len(uniquePKs) == 0 && hasOldPKs {
+		update.ExpressionAttributeNames["#unique_pks"] = "_unique_pks"
+		update.UpdateExpression = aws.String(aws.ToString(update.UpdateExpression) + " REMOVE #unique_pks")
+	}
+	add(types.TransactWriteItem{Update: update}, ErrConcurrentModification)
+
+	add(types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName: aws.String(s.config.RelationshipTable),
+			Key: PK{
+				"pk":        &types.AttributeValueMemberS{Value: s.relationshipPK(oldParentRef, to.EntityRef())},
+				"child_ref": &types.AttributeValueMemberS{Value: to.EntityRef()},
+			},
+		},
+	}, nil)
+	_, put := s.relationshipPut(to.EntityRef(), newParentRef, to.TableName(), to.GetKey())
+	add(put, nil)
+
+	if parent := s.countedParent(from); parent != nil {
+		add(withChildCount(childCountCheck(parent), -1), errNoChildCount)
+	}
+
+	// A retry drops the decrement of an old parent without a count
+	for {
+		_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		err = mapTransactionError(err, items, failures)
+
+		var condErr *ConditionFailedError
+		if errors.Is(err, errNoChildCount) && errors.As(err, &condErr) {
+			items = append(items[:condErr.Index], items[condErr.Index+1:]...)
+			failures = append(failures[:condErr.Index], failures[condErr.Index+1:]...)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	s.ancestors.forget(to.EntityRef())
+	if path != nil {
+		if err := s.repath(ctx, to.EntityRef(), path); err != nil {
+			return fmt.Errorf("moved %s but rewriting descendant paths failed: %w", to.EntityRef(), err)
+		}
+	}
+	return nil
+}
+
+// checkNotAncestor fails with ErrInvalidMove if ref is an ancestor of the
+// parent of child, walking parent_ref upwards from it. A missing or deleted
+// parent fails with ErrParentNotFound.
+func (s *Store) checkNotAncestor(ctx context.Context, child ParentChecker, ref string) error {
+	var key TableKey
+	if check := child.ParentCheck(); check != nil {
+		key = TableKey{TableName: check.TableName, Key: check.Key}
+	} else {
+		var err error
+		if key, err = s.resolveRef(child.ParentRef()); err != nil {
+			return err
+		}
+	}
+
+	raw, err := s.getRaw(ctx, key.TableName, key.Key)
+	if errors.Is(err, ErrNotFound) || err == nil && IsDeleted(raw) {
+		return ErrParentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	ancestors, err := s.walkAncestors(ctx, s.unmarshalItem(raw).ParentRef)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(ancestors, func(item *Item) bool { return item.EntityRef == ref }) {
+		return fmt.Errorf("%w: %s is an ancestor of %s", ErrInvalidMove, ref, child.ParentRef())
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+)
+
+func TestMove(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	for _, id := range []string{"p1", "p2"} {
+		if err := s.Create(ctx, Parent{ID: id}, makeTestItem(id, "Parent")); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	from := UniqueChild{ID: "u1", ParentID: "p1", Name: "alpha", Slug: "alpha"}
+	if err := s.Create(ctx, from, makeTestItem("u1", "alpha")); err != nil {
+		t.Fatalf("create u1: %v", err)
+	}
+	to := from
+	to.ParentID = "p2"
+	item := map[string]types.AttributeValue{
+		"parent_id": &types.AttributeValueMemberS{Value: "p2"},
+	}
+
+	if err := s.Move(ctx, from, to, item, 2); !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification for stale version, got %v", err)
+	}
+	missing := from
+	missing.ParentID = "p3"
+	if err := s.Move(ctx, from, missing, nil, 1); !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+	if err := s.Move(ctx, from, from, nil, 1); !errors.Is(err, store.ErrInvalidMove) {
+		t.Errorf("expected ErrInvalidMove for the same parent, got %v", err)
+	}
+
+	if err := s.Move(ctx, from, to, item, 1); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	got, err := s.Get(ctx, "unique_children", to.GetKey())
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.ParentRef != "parent#p2" || got.Version != 2 {
+		t.Errorf("expected parent#p2 at version 2, got %s at version %d", got.ParentRef, got.Version)
+	}
+	if v, ok := got.Raw["parent_id"].(*types.AttributeValueMemberS); !ok || v.Value != "p2" {
+		t.Errorf("expected parent_id p2, got %v", got.Raw["parent_id"])
+	}
+
+	for parentRef, want := range map[string]int{"parent#p1": 0, "parent#p2": 1} {
+		children, err := s.QueryAllChildren(ctx, parentRef)
+		if err != nil {
+			t.Fatalf("query children of %s: %v", parentRef, err)
+		}
+		if len(children) != want {
+			t.Errorf("expected %d children of %s, got %d", want, parentRef, len(children))
+		}
+	}
+
+	// The values are free under the old parent and taken under the new one
+	if err := s.Create(ctx, UniqueChild{ID: "u2", ParentID: "p1", Name: "alpha", Slug: "alpha"}, makeTestItem("u2", "alpha")); err != nil {
+		t.Errorf("create under old parent: %v", err)
+	}
+	err = s.Create(ctx, UniqueChild{ID: "u3", ParentID: "p2", Name: "alpha", Slug: "other"}, makeTestItem("u3", "alpha"))
+	if !errors.Is(err, store.ErrDuplicateValue) {
+		t.Errorf("expected ErrDuplicateValue under new parent, got %v", err)
+	}
+}
+
+func TestMove_DuplicateValue(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	for _, id := range []string{"p1", "p2"} {
+		if err := s.Create(ctx, Parent{ID: id}, makeTestItem(id, "Parent")); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	from := UniqueChild{ID: "u1", ParentID: "p1", Name: "alpha", Slug: "one"}
+	for _, child := range []UniqueChild{from, {ID: "u2", ParentID: "p2", Name: "beta", Slug: "one"}} {
+		if err := s.Create(ctx, child, makeTestItem(child.ID, child.Name)); err != nil {
+			t.Fatalf("create %s: %v", child.ID, err)
+		}
+	}
+	before := len(db.Items("trellis_unique_constraints"))
+
+	to := from
+	to.ParentID = "p2"
+	err := s.Move(ctx, from, to, nil, 1)
+	var dupErr *store.DuplicateValueError
+	if !errors.As(err, &dupErr) || dupErr.Field != "slug" || dupErr.Value != "one" {
+		t.Fatalf("expected DuplicateValueError for slug, got %v", err)
+	}
+
+	// Nothing was moved
+	got, err := s.Get(ctx, "unique_children", from.GetKey())
+	if err != nil || got.ParentRef != "parent#p1" || got.Version != 1 {
+		t.Errorf("expected u1 unchanged under parent#p1, got %+v (err %v)", got, err)
+	}
+	if n := len(db.Items("trellis_unique_constraints")); n != before {
+		t.Errorf("expected %d constraints, got %d", before, n)
+	}
+}
+
+func TestMove_ChildCount(t *testing.T) {
+	s, db := newMemStore(t, trackingConfig())
+	ctx := context.Background()
+
+	for _, id := range []string{"p1", "p2"} {
+		if err := s.Create(ctx, Parent{ID: id}, makeTestItem(id, "Parent")); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := s.Create(ctx, Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "Child")); err != nil {
+		t.Fatalf("create c1: %v", err)
+	}
+
+	repo := store.NewRepository[Child](s)
+	if err := repo.Move(ctx, Child{ID: "c1", ParentID: "p1"}, Child{ID: "c1", ParentID: "p2", Name: "moved"}, 1); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	for id, want := range map[string]int64{"p1": 0, "p2": 1} {
+		if n := childCount(t, db, "parents", Parent{ID: id}.GetKey()); n != want {
+			t.Errorf("expected %s to count %d children, got %d", id, want, n)
+		}
+	}
+	if err := s.Delete(ctx, Parent{ID: "p1"}, store.DeleteOptions{OrphanProtect: true}); err != nil {
+		t.Errorf("delete emptied parent: %v", err)
+	}
+}
+
+func TestMove_Cycle(t *testing.T) {
+	s, _ := newTreeStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	from := Node{ID: "a", ParentID: "root", Slug: "a"}
+
+	for _, parentID := range []string{"a", "a1", "a1x"} {
+		to := from
+		to.ParentID = parentID
+		if err := s.Move(ctx, from, to, nil, 1); !errors.Is(err, store.ErrInvalidMove) {
+			t.Errorf("expected ErrInvalidMove moving under %s, got %v", parentID, err)
+		}
+	}
+
+	// Nothing was moved
+	got, err := s.Get(ctx, "nodes", from.GetKey())
+	if err != nil || got.ParentRef != "node#root" || got.Version != 1 {
+		t.Errorf("expected a unchanged under node#root, got %+v (err %v)", got, err)
+	}
+}
+
+func TestMove_UnregisteredAncestors(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	seedTree(t, s)
+	ctx := context.Background()
+	from := Node{ID: "b", ParentID: "root", Slug: "b"}
+	to := Node{ID: "b", ParentID: "a1", Slug: "b"}
+
+	// The ancestors of a1 cannot be read without their types registered
+	if err := s.Move(ctx, from, to, nil, 1); !errors.Is(err, store.ErrUnknownEntityType) {
+		t.Errorf("expected ErrUnknownEntityType, got %v", err)
+	}
+
+	// Stored paths need no registry
+	cfg := store.DefaultConfig()
+	cfg.StorePath = true
+	s, _ = newMemStore(t, cfg)
+	seedTree(t, s)
+	if err := s.Move(ctx, from, to, nil, 1); err != nil {
+		t.Errorf("expected move with stored paths to succeed, got %v", err)
+	}
+}
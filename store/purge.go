@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// errConstraintOwned marks a unique constraint that now belongs to another
+// entity and must not be purged.
+var errConstraintOwned = errors.New("trellis: unique constraint owned by another entity")
+
+// PurgeOptions configures Purge.
+type PurgeOptions struct {
+	// Cascade also purges all descendants, deepest first. Without it, Purge
+	// fails with ErrHasChildren if the entity has active children.
+	Cascade bool
+
+	// MaxDepth limits how many levels below the entity a cascading purge
+	// may reach (0 = unlimited).
+	MaxDepth int
+
+	// MaxItems limits how many descendants a cascading purge may delete
+	// (0 = unlimited).
+	MaxItems int
+
+	// Concurrency bounds the requests a cascading purge runs in parallel
+	// (default 8).
+	Concurrency int
+}
+
+// Purge physically deletes an entity, active or soft-deleted, without
+// waiting for the TTL sweep. The entity item, its relationship record and
+// every constraint in _unique_pks are deleted in one transaction; the entity
+// delete is conditioned on the version read, so a concurrent update fails
+// the purge with ErrConcurrentModification.
+//
+// With opts.Cascade, descendants are purged first, deepest level first, each
+// in its own transaction, so an interrupted purge can be run again. Limits
+// are checked before anything is deleted, as in CascadeDelete.
+func (s *Store) Purge(ctx context.Context, entity Entity, opts PurgeOptions) (*CascadeSummary, error) {
+	defer s.ancestors.forget(entity.EntityRef())
+
+	summary := &CascadeSummary{}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultCascadeConcurrency
+	}
+
+	if !opts.Cascade {
+		hasChildren, err := s.HasActiveChildren(ctx, entity.EntityRef())
+		if err != nil {
+			return summary, err
+		}
+		if hasChildren {
+			return summary, ErrHasChildren
+		}
+	}
+
+	raw, err := s.getRaw(ctx, entity.TableName(), entity.GetKey())
+	if err != nil {
+		return summary, err
+	}
+
+	var mu sync.Mutex
+	add := func(relationships, uniques int) {
+		mu.Lock()
+		defer mu.Unlock()
+		summary.Entities++
+		summary.Relationships += relationships
+		summary.UniqueConstraints += uniques
+	}
+
+	if opts.Cascade {
+		levels, err := s.cascadeLevels(ctx, entity.EntityRef(), opts.MaxDepth, opts.MaxItems, concurrency)
+		if err != nil {
+			return summary, err
+		}
+		summary.Depth = len(levels)
+
+		for depth := len(levels) - 1; depth >= 0; depth-- {
+			level := levels[depth]
+			err := forEachConcurrent(ctx, len(level), concurrency, func(ctx context.Context, i int) error {
+				raw, err := s.getRaw(ctx, level[i].table, level[i].key)
+				if errors.Is(err, ErrNotFound) {
+					// Already purged
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				return s.purgeItem(ctx, level[i].table, level[i].key, raw, nil, add)
+			})
+			if err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	// A deleted entity was uncounted from its parent when it was deleted
+	var parent *ConditionCheck
+	if !IsDeleted(raw) {
+		parent = s.countedParent(entity)
+	}
+	return summary, s.purgeItem(ctx, entity.TableName(), entity.GetKey(), raw, parent, add)
+}
+
+// purgeItem deletes an entity item, its relationship record and its unique
+// constraints in one transaction, decrementing parent's child count if
+// parent is non-nil. Constraints that another entity has claimed since ours
+// were purged by TTL are left alone, as is a parent without a count.
+func (s *Store) purgeItem(ctx context.Context, table string, key PK, raw map[string]types.AttributeValue, parent *ConditionCheck, add func(relationships, uniques int)) error {
+	item := s.unmarshalItem(raw)
+
+	items := []types.TransactWriteItem{{
+		Delete: &types.Delete{
+			TableName:                aws.String(table),
+			Key:                      key,
+			ConditionExpression:      aws.String("#version = :version"),
+			ExpressionAttributeNames: map[string]string{"#version": "version"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(item.Version, 10)},
+			},
+		},
+	}}
+	failures := []error{ErrConcurrentModification}
+
+	relationships := 0
+	if item.ParentRef != "" {
+		items = append(items, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: aws.String(s.config.RelationshipTable),
+				Key: PK{
+					"pk":        &types.AttributeValueMemberS{Value: s.relationshipPK(item.ParentRef, item.EntityRef)},
+					"child_ref": &types.AttributeValueMemberS{Value: item.EntityRef},
+				},
+			},
+		})
+		failures = append(failures, nil)
+		relationships = 1
+	}
+
+	if pks, ok := raw["_unique_pks"].(*types.AttributeValueMemberL); ok {
+		for _, v := range pks.Value {
+			pk, ok := v.(*types.AttributeValueMemberS)
+			if !ok {
+				continue
+			}
+			items = append(items, types.TransactWriteItem{
+				Delete: &types.Delete{
+					TableName: aws.String(s.config.UniqueTable),
+					Key: PK{
+						"pk": pk,
+						"sk": &types.AttributeValueMemberS{Value: "CONSTRAINT"},
+					},
+					ConditionExpression:      aws.String("attribute_not_exists(pk) OR #entity_ref = :ref"),
+					ExpressionAttributeNames: map[string]string{"#entity_ref": "entity_ref"},
+					ExpressionAttributeValues: map[string]types.AttributeValue{
+						":ref": &types.AttributeValueMemberS{Value: item.EntityRef},
+					},
+				},
+			})
+			failures = append(failures, errConstraintOwned)
+		}
+	}
+
+	counted := 0
+	if parent != nil {
+		items = append(items, withChildCount(childCountCheck(parent), -1))
+		failures = append(failures, errNoChildCount)
+		counted = 1
+	}
+
+	// Each retry drops one constraint owned by another entity or the
+	// parent count
+	for {
+		_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		err = mapTransactionError(err, items, failures)
+		if err == nil {
+			add(relationships, len(items)-1-relationships-counted)
+			return nil
+		}
+
+		var condErr *ConditionFailedError
+		if !errors.As(err, &condErr) || !errors.Is(err, errConstraintOwned) && !errors.Is(err, errNoChildCount) {
+			return fmt.Errorf("purge %s: %w", item.EntityRef, err)
+		}
+		if errors.Is(err, errNoChildCount) {
+			counted = 0
+		}
+		items = append(items[:condErr.Index], items[condErr.Index+1:]...)
+		failures = append(failures[:condErr.Index], failures[condErr.Index+1:]...)
+	}
+}
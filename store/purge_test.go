@@ -0,0 +1,149 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+)
+
+func TestPurge_Entity(t *testing.T) {
+	cfg := store.DefaultConfig()
+	s, db := newMemStore(t, cfg)
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	child := UniqueChild{ID: "u1", ParentID: "p1", Name: "name", Slug: "slug"}
+	if err := s.Create(ctx, child, makeTestItem("u1", "name")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+
+	if _, err := s.Purge(ctx, Parent{ID: "p1"}, store.PurgeOptions{}); !errors.Is(err, store.ErrHasChildren) {
+		t.Errorf("expected ErrHasChildren, got %v", err)
+	}
+
+	summary, err := s.Purge(ctx, child, store.PurgeOptions{})
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	want := store.CascadeSummary{Entities: 1, Relationships: 1, UniqueConstraints: 2}
+	if *summary != want {
+		t.Errorf("expected summary %+v, got %+v", want, *summary)
+	}
+	for _, table := range []string{"unique_children", cfg.RelationshipTable, cfg.UniqueTable} {
+		if items := db.Items(table); len(items) != 0 {
+			t.Errorf("expected %s to be empty, got %d items", table, len(items))
+		}
+	}
+
+	// Unique values are free immediately
+	if err := s.Create(ctx, UniqueChild{ID: "u2", ParentID: "p1", Name: "name", Slug: "slug"}, makeTestItem("u2", "name")); err != nil {
+		t.Errorf("expected unique values to be free, got %v", err)
+	}
+	if _, err := s.Purge(ctx, child, store.PurgeOptions{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPurge_Cascade(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 4
+	s, db := newMemStore(t, cfg)
+	ctx := context.Background()
+	seedTree(t, s)
+
+	// Soft-deleted entities are purged too
+	if err := s.Delete(ctx, Node{ID: "a2", ParentID: "a"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete a2: %v", err)
+	}
+
+	if _, err := s.Purge(ctx, Node{ID: "root"}, store.PurgeOptions{Cascade: true, MaxDepth: 2}); !errors.Is(err, store.ErrCascadeLimit) {
+		t.Errorf("expected ErrCascadeLimit, got %v", err)
+	}
+	if items := db.Items("nodes"); len(items) != 6 {
+		t.Errorf("expected nothing purged over the limit, got %d nodes left", len(items))
+	}
+
+	summary, err := s.Purge(ctx, Node{ID: "root"}, store.PurgeOptions{Cascade: true, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	want := store.CascadeSummary{Entities: 6, Relationships: 5, UniqueConstraints: 5, Depth: 3}
+	if *summary != want {
+		t.Errorf("expected summary %+v, got %+v", want, *summary)
+	}
+	for _, table := range []string{"nodes", cfg.RelationshipTable, cfg.UniqueTable} {
+		if items := db.Items(table); len(items) != 0 {
+			t.Errorf("expected %s to be empty, got %d items", table, len(items))
+		}
+	}
+}
+
+func TestPurge_KeepsConstraintClaimedByOthers(t *testing.T) {
+	cfg := store.DefaultConfig()
+	s, db := newMemStore(t, cfg)
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	child := UniqueChild{ID: "u1", ParentID: "p1", Name: "name", Slug: "slug"}
+	if err := s.Create(ctx, child, makeTestItem("u1", "name")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+	deleteLikeCascade(t, s, db, child)
+
+	// The constraints are swept by TTL, and another entity claims the name
+	for _, row := range db.Items(cfg.UniqueTable) {
+		key := store.PK{"pk": row["pk"], "sk": row["sk"]}
+		if _, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: &cfg.UniqueTable, Key: key}); err != nil {
+			t.Fatalf("sweep constraint: %v", err)
+		}
+	}
+	if err := s.Create(ctx, UniqueChild{ID: "u2", ParentID: "p1", Name: "name", Slug: "other"}, makeTestItem("u2", "name")); err != nil {
+		t.Fatalf("create claimer: %v", err)
+	}
+
+	if _, err := s.Purge(ctx, child, store.PurgeOptions{}); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if rows := db.Items(cfg.UniqueTable); len(rows) != 2 {
+		t.Errorf("expected the claimer's 2 constraints to remain, got %d", len(rows))
+	}
+}
+
+func TestPurge_ConcurrentUpdate(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	parent := Parent{ID: "p1"}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+
+	// Another writer updates the entity between Purge's read and its delete
+	updated := false
+	db.SetInterceptor(func(ctx context.Context, op string, _ any) error {
+		if op == "TransactWriteItems" && !updated {
+			updated = true
+			update := map[string]types.AttributeValue{"name": &types.AttributeValueMemberS{Value: "Edited"}}
+			if err := s.Update(ctx, parent, update, 1); err != nil {
+				t.Errorf("concurrent update: %v", err)
+			}
+		}
+		return nil
+	})
+
+	if _, err := s.Purge(ctx, parent, store.PurgeOptions{}); !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification, got %v", err)
+	}
+	if _, err := s.Get(ctx, "parents", parent.GetKey()); err != nil {
+		t.Errorf("expected entity to survive, got %v", err)
+	}
+}
@@ -15,10 +15,26 @@ type Relationship struct {
 	ParentKeyAttr string
 }
 
+// EntityTable maps an entity type to the table its entities are stored in,
+// so an entity reference can be resolved to an item.
+type EntityTable struct {
+	// EntityType is the entity type (e.g., "organization").
+	EntityType string
+
+	// TableName is the DynamoDB table name for the type (e.g., "organizations").
+	TableName string
+
+	// KeyAttr is the partition key attribute holding the ID part of the
+	// entity reference.
+	// Default: "id"
+	KeyAttr string
+}
+
 // Registry holds all known entity relationships for cascade operations.
 type Registry struct {
 	relationships []Relationship
 	byParent      map[string][]Relationship
+	tables        map[string]EntityTable
 }
 
 // NewRegistry creates a new empty Registry.
@@ -26,6 +42,7 @@ func NewRegistry() *Registry {
 	return &Registry{
 		relationships: []Relationship{},
 		byParent:      make(map[string][]Relationship),
+		tables:        make(map[string]EntityTable),
 	}
 }
 
@@ -50,3 +67,26 @@ func (r *Registry) AllRelationships() []Relationship {
 func (r *Registry) HasChildren(parentType string) bool {
 	return len(r.byParent[parentType]) > 0
 }
+
+// RegisterTable maps an entity type to its table for resolving ancestors.
+// Types registered as a Relationship's ChildType are resolved from its
+// ChildTableName without this, so only root types need registering.
+func (r *Registry) RegisterTable(t EntityTable) {
+	if t.KeyAttr == "" {
+		t.KeyAttr = "id"
+	}
+	r.tables[t.EntityType] = t
+}
+
+// TableOf returns the table entities of the given type are stored in.
+func (r *Registry) TableOf(entityType string) (EntityTable, bool) {
+	if t, ok := r.tables[entityType]; ok {
+		return t, true
+	}
+	for _, rel := range r.relationships {
+		if rel.ChildType == entityType {
+			return EntityTable{EntityType: entityType, TableName: rel.ChildTableName, KeyAttr: "id"}, true
+		}
+	}
+	return EntityTable{}, false
+}
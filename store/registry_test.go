@@ -368,3 +368,30 @@ func TestRegistry_UnicodeTypes(t *testing.T) {
 		t.Error("expected unicode child type")
 	}
 }
+
+func TestRegistry_TableOf(t *testing.T) {
+	r := store.NewRegistry()
+	r.Register(store.Relationship{
+		ParentType:     "organization",
+		ChildType:      "studio",
+		ChildTableName: "studios",
+		ParentKeyAttr:  "organization_id",
+	})
+	r.RegisterTable(store.EntityTable{EntityType: "organization", TableName: "organizations", KeyAttr: "org_id"})
+
+	tests := []struct {
+		entityType string
+		want       store.EntityTable
+		ok         bool
+	}{
+		{"organization", store.EntityTable{EntityType: "organization", TableName: "organizations", KeyAttr: "org_id"}, true},
+		{"studio", store.EntityTable{EntityType: "studio", TableName: "studios", KeyAttr: "id"}, true},
+		{"title", store.EntityTable{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := r.TableOf(tt.entityType)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("TableOf(%q) = %+v, %v; expected %+v, %v", tt.entityType, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Record is a typed entity together with its ORM-managed metadata.
+type Record[T Entity] struct {
+	// Entity is the unmarshalled entity.
+	Entity T
+
+	// Version is the optimistic lock version.
+	Version int64
+
+	// CreatedAt is the ISO 8601 creation timestamp.
+	CreatedAt string
+
+	// UpdatedAt is the ISO 8601 last update timestamp.
+	UpdatedAt string
+
+	// EntityRef is the type-qualified entity reference.
+	EntityRef string
+
+	// ParentRef is the parent's entity reference (empty for root entities).
+	ParentRef string
+}
+
+// Repository provides typed access to entities of type T.
+//
+// Entities are marshalled with the attributevalue package, so dynamodbav
+// struct tags control attribute names. ORM-managed attributes (version,
+// created_at, updated_at, entity_ref, parent_ref, ttl, _unique_pks,
+// child_count, _path) are set by the Store and returned in Record rather
+// than on T.
+type Repository[T Entity] struct {
+	store *Store
+}
+
+// NewRepository creates a Repository for entities of type T backed by s.
+func NewRepository[T Entity](s *Store) *Repository[T] {
+	return &Repository[T]{store: s}
+}
+
+// Store returns the underlying Store for operations not covered by the
+// typed API.
+func (r *Repository[T]) Store() *Store {
+	return r.store
+}
+
+// Create marshals entity and creates it with parent validation and unique
+// constraints.
+func (r *Repository[T]) Create(ctx context.Context, entity T) error {
+	item, err := attributevalue.MarshalMap(entity)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", entity.EntityType(), err)
+	}
+	return r.store.Create(ctx, entity, item)
+}
+
+// Get retrieves the entity identified by key, returning ErrNotFound if it
+// is deleted or missing. Only the fields used by TableName and GetKey need
+// to be set on key.
+func (r *Repository[T]) Get(ctx context.Context, key T) (*Record[T], error) {
+	item, err := r.store.Get(ctx, key.TableName(), key.GetKey())
+	if err != nil {
+		return nil, err
+	}
+	return r.unmarshal(item)
+}
+
+// Update marshals entity and updates it with optimistic locking.
+// Key attributes are never rewritten.
+func (r *Repository[T]) Update(ctx context.Context, entity T, expectedVersion int64) error {
+	item, err := attributevalue.MarshalMap(entity)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", entity.EntityType(), err)
+	}
+	for k := range entity.GetKey() {
+		delete(item, k)
+	}
+	return r.store.Update(ctx, entity, item, expectedVersion)
+}
+
+// Move marshals to and moves the entity from from's parent to to's, with
+// optimistic locking. Key attributes are never rewritten.
+func (r *Repository[T]) Move(ctx context.Context, from, to T, expectedVersion int64) error {
+	item, err := attributevalue.MarshalMap(to)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", to.EntityType(), err)
+	}
+	for k := range to.GetKey() {
+		delete(item, k)
+	}
+	return r.store.Move(ctx, from, to, item, expectedVersion)
+}
+
+// Delete deletes an entity by setting its TTL.
+func (r *Repository[T]) Delete(ctx context.Context, entity T, opts DeleteOptions) error {
+	return r.store.Delete(ctx, entity, opts)
+}
+
+// Query queries entities with automatic TTL filtering and unmarshals the
+// results into records.
+func (r *Repository[T]) Query(ctx context.Context, input *QueryInput) ([]*Record[T], error) {
+	items, err := r.store.Query(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return r.unmarshalAll(items)
+}
+
+// QueryIter returns an iterator over query results, unmarshalling each item
+// as it is read. See Store.QueryIter.
+func (r *Repository[T]) QueryIter(ctx context.Context, input *QueryInput) iter.Seq2[*Record[T], error] {
+	return func(yield func(*Record[T], error) bool) {
+		for item, err := range r.store.QueryIter(ctx, input) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			rec, err := r.unmarshal(item)
+			if !yield(rec, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// unmarshalAll converts items to records, preserving order.
+func (r *Repository[T]) unmarshalAll(items []*Item) ([]*Record[T], error) {
+	records := make([]*Record[T], 0, len(items))
+	for _, item := range items {
+		rec, err := r.unmarshal(item)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// unmarshal converts an Item to a Record.
+func (r *Repository[T]) unmarshal(item *Item) (*Record[T], error) {
+	rec := &Record[T]{
+		Version:   item.Version,
+		CreatedAt: item.CreatedAt,
+		UpdatedAt: item.UpdatedAt,
+		EntityRef: item.EntityRef,
+		ParentRef: item.ParentRef,
+	}
+	if err := UnmarshalEntity(item.Raw, &rec.Entity); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// UnmarshalEntity unmarshals a raw item into out, a pointer to an entity.
+// It is the inverse of the marshalling used by Repository and may be used
+// with items returned by the untyped API.
+func UnmarshalEntity(raw map[string]types.AttributeValue, out any) error {
+	if err := attributevalue.UnmarshalMap(raw, out); err != nil {
+		return fmt.Errorf("unmarshal entity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+)
+
+// Widget is a typed child entity with dynamodbav tags.
+type Widget struct {
+	ID       string   `dynamodbav:"id"`
+	ParentID string   `dynamodbav:"parent_id"`
+	Name     string   `dynamodbav:"name"`
+	Tags     []string `dynamodbav:"tags,omitempty,stringset"`
+	Count    int      `dynamodbav:"count"`
+}
+
+func (w Widget) TableName() string  { return "widgets" }
+func (w Widget) EntityRef() string  { return "widget#" + w.ID }
+func (w Widget) EntityType() string { return "widget" }
+func (w Widget) GetKey() store.PK {
+	return store.PK{"id": &types.AttributeValueMemberS{Value: w.ID}}
+}
+
+func (w Widget) ParentRef() string { return "parent#" + w.ParentID }
+func (w Widget) ParentCheck() *store.ConditionCheck {
+	return &store.ConditionCheck{
+		TableName: "parents",
+		Key:       store.PK{"id": &types.AttributeValueMemberS{Value: w.ParentID}},
+	}
+}
+
+func TestRepository_CreateGetUpdate(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+
+	repo := store.NewRepository[Widget](s)
+	w := Widget{ID: "w1", ParentID: "p1", Name: "first", Tags: []string{"a", "b"}, Count: 3}
+	if err := repo.Create(ctx, w); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	rec, err := repo.Get(ctx, Widget{ID: "w1"})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if rec.Entity.Name != "first" || rec.Entity.Count != 3 || len(rec.Entity.Tags) != 2 {
+		t.Errorf("unexpected entity: %+v", rec.Entity)
+	}
+	if rec.Version != 1 || rec.EntityRef != "widget#w1" || rec.ParentRef != "parent#p1" || rec.CreatedAt == "" {
+		t.Errorf("unexpected metadata: %+v", rec)
+	}
+
+	w.Name = "renamed"
+	if err := repo.Update(ctx, w, rec.Version); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := repo.Update(ctx, w, rec.Version); !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification, got %v", err)
+	}
+
+	rec, err = repo.Get(ctx, Widget{ID: "w1"})
+	if err != nil {
+		t.Fatalf("get after update: %v", err)
+	}
+	if rec.Entity.Name != "renamed" || rec.Version != 2 {
+		t.Errorf("expected renamed at version 2, got %q at %d", rec.Entity.Name, rec.Version)
+	}
+}
+
+func TestRepository_CreateMapsErrors(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	repo := store.NewRepository[Widget](s)
+
+	err := repo.Create(context.Background(), Widget{ID: "w1", ParentID: "missing"})
+	if !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+}
+
+func TestRepository_DeleteAndQuery(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+
+	repo := store.NewRepository[*Widget](s)
+	for _, id := range []string{"w1", "w2"} {
+		if err := repo.Create(ctx, &Widget{ID: id, ParentID: "p1", Name: id}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := repo.Delete(ctx, &Widget{ID: "w1"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.Get(ctx, &Widget{ID: "w1"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	for _, id := range []string{"w1", "w2"} {
+		records, err := repo.Query(ctx, &store.QueryInput{
+			TableName:              "widgets",
+			KeyConditionExpression: "id = :id",
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":id": &types.AttributeValueMemberS{Value: id},
+			},
+		})
+		if err != nil {
+			t.Fatalf("query %s: %v", id, err)
+		}
+		want := 0
+		if id == "w2" {
+			want = 1
+		}
+		if len(records) != want {
+			t.Fatalf("query %s: expected %d records, got %d", id, want, len(records))
+		}
+		if want == 1 && records[0].Entity.Name != "w2" {
+			t.Errorf("expected w2, got %q", records[0].Entity.Name)
+		}
+	}
+}
+
+func TestUnmarshalEntity(t *testing.T) {
+	var w Widget
+	err := store.UnmarshalEntity(map[string]types.AttributeValue{
+		"id":      &types.AttributeValueMemberS{Value: "w1"},
+		"name":    &types.AttributeValueMemberS{Value: "n"},
+		"version": &types.AttributeValueMemberN{Value: "4"},
+	}, &w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.ID != "w1" || w.Name != "n" {
+		t.Errorf("unexpected widget: %+v", w)
+	}
+}
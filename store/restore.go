@@ -0,0 +1,350 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/internal/shard"
+)
+
+// RestoreOptions configures restore behavior.
+type RestoreOptions struct {
+	// Subtree also restores descendants deleted by the same cascade,
+	// identified by a TTL equal to the entity's.
+	Subtree bool
+}
+
+// Restore undeletes a soft-deleted entity that DynamoDB has not yet purged.
+//
+// It removes the entity's TTL, checking expectedVersion against the version
+// of the deleted item, and re-activates its relationship record and unique
+// constraints in one transaction, re-creating constraints already purged
+// with the entity's UniqueFields. The parent must be active. If another
+// entity has claimed one of its unique values in the meantime, Restore fails
+// with a *DuplicateValueError.
+//
+// With Config.TrackChildCount, the entity's child count is set to its active
+// children and its parent's is incremented.
+//
+// With Subtree set, descendants are restored parent-first after the entity,
+// each in its own transaction; failures are joined into the returned error.
+// A cascade still in progress may delete descendants again after they are
+// restored, so restore only once the stream has settled.
+func (s *Store) Restore(ctx context.Context, entity Entity, expectedVersion int64, opts RestoreOptions) error {
+	raw, err := s.getDeleted(ctx, entity.TableName(), entity.GetKey())
+	if err != nil {
+		return err
+	}
+	ttl, _ := itemTTL(raw)
+	now := time.Now()
+
+	childCount, err := s.restoredChildCount(ctx, entity.EntityRef())
+	if err != nil {
+		return err
+	}
+	var fields map[string]string
+	if uf, ok := entity.(UniqueFielder); ok {
+		fields = uf.UniqueFields()
+	}
+	plan := s.buildRestore(entity.TableName(), entity.GetKey(), raw, fields, expectedVersion, childCount)
+	if checker, ok := entity.(ParentChecker); ok {
+		if check := checker.ParentCheck(); check != nil {
+			checkItem := parentCheckItem(check, now)
+			if s.config.TrackChildCount {
+				checkItem = withChildCount(checkItem, 1)
+			}
+			plan.items = append(plan.items, checkItem)
+			plan.failures = append(plan.failures, ErrParentNotFound)
+		}
+	}
+
+	if err := s.commitRestore(ctx, plan); err != nil {
+		return err
+	}
+	if !opts.Subtree {
+		return nil
+	}
+	root := ChildRef{Ref: entity.EntityRef(), TableName: entity.TableName(), Key: entity.GetKey()}
+	return s.restoreSubtree(ctx, root, ttl)
+}
+
+// restoredChildCount returns the child count to store on a restored entity:
+// its children that are active now. Descendants deleted by the same cascade
+// are counted again as they are restored. It returns 0 if child counts are
+// not tracked.
+func (s *Store) restoredChildCount(ctx context.Context, entityRef string) (int64, error) {
+	if !s.config.TrackChildCount {
+		return 0, nil
+	}
+	count, err := s.countActiveChildren(ctx, entityRef)
+	if err != nil {
+		return 0, fmt.Errorf("count children of %s: %w", entityRef, err)
+	}
+	return count, nil
+}
+
+// restoreSubtree restores the descendants of root whose TTL equals ttl,
+// breadth first so parents are always active before their children.
+func (s *Store) restoreSubtree(ctx context.Context, root ChildRef, ttl int64) error {
+	var errs []error
+	queue := []ChildRef{root}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for child, err := range s.QueryAllChildrenIter(ctx, parent.Ref) {
+			if err != nil {
+				return errors.Join(append(errs, fmt.Errorf("query children of %s: %w", parent.Ref, err))...)
+			}
+
+			raw, err := s.getDeleted(ctx, child.TableName, child.Key)
+			if errors.Is(err, ErrNotFound) || errors.Is(err, ErrNotDeleted) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("restore %s: %w", child.Ref, err))
+				continue
+			}
+			// Deleted separately, not by this cascade
+			if childTTL, _ := itemTTL(raw); childTTL != ttl {
+				continue
+			}
+
+			childCount, err := s.restoredChildCount(ctx, child.Ref)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("restore %s: %w", child.Ref, err))
+				continue
+			}
+			plan := s.buildRestore(child.TableName, child.Key, raw, nil, s.unmarshalItem(raw).Version, childCount)
+			if s.config.TrackChildCount {
+				check := &ConditionCheck{TableName: parent.TableName, Key: parent.Key}
+				plan.items = append(plan.items, withChildCount(parentCheckItem(check, time.Now()), 1))
+				plan.failures = append(plan.failures, ErrParentNotFound)
+			}
+			if err := s.commitRestore(ctx, plan); err != nil {
+				errs = append(errs, fmt.Errorf("restore %s: %w", child.Ref, err))
+				continue
+			}
+			queue = append(queue, child)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// getDeleted reads an item that is expected to be soft-deleted. It returns
+// ErrNotFound if the item is missing and ErrNotDeleted if it has no TTL.
+func (s *Store) getDeleted(ctx context.Context, table string, key PK) (map[string]types.AttributeValue, error) {
+	raw, err := s.getRaw(ctx, table, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := itemTTL(raw); !ok {
+		return nil, ErrNotDeleted
+	}
+	return raw, nil
+}
+
+// getRaw reads an item with a consistent read, whether or not it is deleted.
+// It returns ErrNotFound if the item is missing.
+func (s *Store) getRaw(ctx context.Context, table string, key PK) (map[string]types.AttributeValue, error) {
+	result, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(table),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrNotFound
+	}
+	return result.Item, nil
+}
+
+// restorePlan holds the transaction items that restore one entity.
+type restorePlan struct {
+	items []types.TransactWriteItem
+
+	// failures holds the error each item's failed condition maps to.
+	failures []error
+}
+
+// buildRestore builds the transaction items that restore a deleted item:
+// the version-checked TTL removal, its relationship record, its unique
+// constraints and, with cascade jobs tracked, the removal of its job. With
+// child counts tracked, the item's count is set to childCount. fields are
+// the entity's unique fields, if known, used to describe constraints that
+// are re-created (see restoredUniqueFields).
+func (s *Store) buildRestore(table string, key PK, raw map[string]types.AttributeValue, fields map[string]string, expectedVersion, childCount int64) *restorePlan {
+	plan := &restorePlan{}
+	item := s.unmarshalItem(raw)
+	ttl, _ := itemTTL(raw)
+
+	setExpr := "SET #version = #version + :one, #updated_at = :updated_at"
+	exprNames := map[string]string{
+		"#ttl":         "ttl",
+		"#deleted_at":  "deleted_at",
+		"#version":     "version",
+		"#updated_at":  "updated_at",
+		"#cascade_job": cascadeJobAttr,
+	}
+	exprValues := map[string]types.AttributeValue{
+		":one":              &types.AttributeValueMemberN{Value: "1"},
+		":updated_at":       &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		":expected_version": &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)},
+		":ttl":              &types.AttributeValueMemberN{Value: strconv.FormatInt(ttl, 10)},
+	}
+	if s.config.TrackChildCount {
+		setExpr += ", #child_count = :child_count"
+		exprNames["#child_count"] = "child_count"
+		exprValues[":child_count"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(childCount, 10)}
+	}
+
+	plan.items = append(plan.items, types.TransactWriteItem{
+		Update: &types.Update{
+			TableName:                 aws.String(table),
+			Key:                       key,
+			UpdateExpression:          aws.String(setExpr + " REMOVE #ttl, #deleted_at, #cascade_job"),
+			ConditionExpression:       aws.String("#version = :expected_version AND #ttl = :ttl"),
+			ExpressionAttributeNames:  exprNames,
+			ExpressionAttributeValues: exprValues,
+		},
+	})
+	plan.failures = append(plan.failures, ErrConcurrentModification)
+
+	// Restoring abandons the entity's cascade job, if any
+	if s.config.TrackCascadeJobs {
+		plan.items = append(plan.items, types.TransactWriteItem{
+			Delete: &types.Delete{
+				TableName: aws.String(s.config.CascadeJobTable),
+				Key:       s.cascadeJobKey(item.EntityRef),
+			},
+		})
+		plan.failures = append(plan.failures, nil)
+	}
+
+	if item.ParentRef == "" {
+		return plan
+	}
+
+	_, put := s.relationshipPut(item.EntityRef, item.ParentRef, table, key)
+	plan.items = append(plan.items, put)
+	plan.failures = append(plan.failures, nil)
+
+	// Re-activate our constraints, re-creating any already purged with the
+	// attributes Create gives them. The condition fails only if another
+	// entity has claimed the value.
+	uniquePKs, _ := raw["_unique_pks"].(*types.AttributeValueMemberL)
+	if uniquePKs == nil {
+		return plan
+	}
+	entityType, _, _ := strings.Cut(item.EntityRef, "#")
+	described := restoredUniqueFields(item.ParentRef, entityType, fields, raw)
+	for _, v := range uniquePKs.Value {
+		pk, ok := v.(*types.AttributeValueMemberS)
+		if !ok {
+			continue
+		}
+		setExpr := "SET #entity_ref = :ref, #parent_ref = :parent, #entity_type = :entity_type"
+		exprNames := map[string]string{
+			"#ttl":         "ttl",
+			"#deleted_at":  "deleted_at",
+			"#entity_ref":  "entity_ref",
+			"#parent_ref":  "parent_ref",
+			"#entity_type": "entity_type",
+		}
+		exprValues := map[string]types.AttributeValue{
+			":ref":         &types.AttributeValueMemberS{Value: item.EntityRef},
+			":parent":      &types.AttributeValueMemberS{Value: item.ParentRef},
+			":entity_type": &types.AttributeValueMemberS{Value: entityType},
+		}
+		if field, ok := described[pk.Value]; ok {
+			setExpr += ", #field_name = :field_name, #field_value = :field_value"
+			exprNames["#field_name"] = "field_name"
+			exprNames["#field_value"] = "field_value"
+			exprValues[":field_name"] = &types.AttributeValueMemberS{Value: field.name}
+			exprValues[":field_value"] = &types.AttributeValueMemberS{Value: field.value}
+		}
+
+		plan.items = append(plan.items, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName: aws.String(s.config.UniqueTable),
+				Key: PK{
+					"pk": pk,
+					"sk": &types.AttributeValueMemberS{Value: "CONSTRAINT"},
+				},
+				UpdateExpression:                    aws.String(setExpr + " REMOVE #ttl, #deleted_at"),
+				ConditionExpression:                 aws.String("attribute_not_exists(pk) OR #entity_ref = :ref"),
+				ExpressionAttributeNames:            exprNames,
+				ExpressionAttributeValues:           exprValues,
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			},
+		})
+		plan.failures = append(plan.failures, &DuplicateValueError{})
+	}
+
+	return plan
+}
+
+// uniqueField is the field and value a unique constraint claims.
+type uniqueField struct {
+	name, value string
+}
+
+// restoredUniqueFields returns, by constraint key, the fields a deleted
+// item's constraints may claim. Constraint keys are hashes, so candidates
+// are hashed to match them: the entity's unique fields if known, then the
+// item's string attributes, which covers fields stored under their own
+// name. A constraint without a match is restored without its field.
+func restoredUniqueFields(parentRef, entityType string, fields map[string]string, raw map[string]types.AttributeValue) map[string]uniqueField {
+	described := make(map[string]uniqueField)
+	match := func(name, value string) {
+		pk := shard.UniqueConstraintPK(parentRef, entityType, name, value)
+		if _, ok := described[pk]; !ok {
+			described[pk] = uniqueField{name: name, value: value}
+		}
+	}
+	for name, value := range fields {
+		match(name, value)
+	}
+	for name, v := range raw {
+		if s, ok := v.(*types.AttributeValueMemberS); ok {
+			match(name, s.Value)
+		}
+	}
+	return described
+}
+
+// commitRestore executes a restore plan. A failed unique constraint is
+// described using the conflicting constraint record.
+func (s *Store) commitRestore(ctx context.Context, plan *restorePlan) error {
+	_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: plan.items,
+	})
+	mapped := mapTransactionError(err, plan.items, plan.failures)
+
+	var condErr *ConditionFailedError
+	var dupErr *DuplicateValueError
+	var txErr *types.TransactionCanceledException
+	if errors.As(mapped, &condErr) && errors.As(mapped, &dupErr) && errors.As(err, &txErr) {
+		existing := txErr.CancellationReasons[condErr.Index].Item
+		if v, ok := existing["entity_type"].(*types.AttributeValueMemberS); ok {
+			dupErr.EntityType = v.Value
+		}
+		if v, ok := existing["field_name"].(*types.AttributeValueMemberS); ok {
+			dupErr.Field = v.Value
+		}
+		if v, ok := existing["field_value"].(*types.AttributeValueMemberS); ok {
+			dupErr.Value = v.Value
+		}
+	}
+	return mapped
+}
@@ -0,0 +1,283 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+	"github.com/jacentio/trellis/storetest"
+)
+
+// deleteLikeCascade deletes an entity and propagates its TTL to its
+// relationship record and unique constraints, as the stream handler does.
+func deleteLikeCascade(t *testing.T, s *store.Store, db *storetest.DB, entity store.Entity) int64 {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.Delete(ctx, entity, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete %s: %v", entity.EntityRef(), err)
+	}
+	raw := db.Item(entity.TableName(), entity.GetKey())
+	ttl, err := strconv.ParseInt(raw["ttl"].(*types.AttributeValueMemberN).Value, 10, 64)
+	if err != nil {
+		t.Fatalf("parse ttl: %v", err)
+	}
+	propagateTTL(t, s, db, entity.TableName(), entity.GetKey(), ttl)
+	return ttl
+}
+
+// propagateTTL applies ttl to an item's relationship record and unique
+// constraints.
+func propagateTTL(t *testing.T, s *store.Store, db *storetest.DB, table string, key store.PK, ttl int64) {
+	t.Helper()
+	ctx := context.Background()
+	raw := db.Item(table, key)
+	ref := raw["entity_ref"].(*types.AttributeValueMemberS).Value
+	if parent, ok := raw["parent_ref"].(*types.AttributeValueMemberS); ok {
+		if err := s.SetRelationshipTTL(ctx, ref, parent.Value, ttl); err != nil {
+			t.Fatalf("set relationship ttl: %v", err)
+		}
+	}
+	if pks, ok := raw["_unique_pks"].(*types.AttributeValueMemberL); ok {
+		for _, pk := range pks.Value {
+			if err := s.SetUniqueConstraintTTL(ctx, pk.(*types.AttributeValueMemberS).Value, ttl); err != nil {
+				t.Fatalf("set unique constraint ttl: %v", err)
+			}
+		}
+	}
+}
+
+func TestRestore_Entity(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	child := UniqueChild{ID: "u1", ParentID: "p1", Name: "name", Slug: "slug"}
+	if err := s.Create(ctx, child, makeTestItem("u1", "name")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+	deleteLikeCascade(t, s, db, child)
+
+	if err := s.Restore(ctx, child, 1, store.RestoreOptions{}); !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification for stale version, got %v", err)
+	}
+	if err := s.Restore(ctx, child, 2, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+
+	item, err := s.Get(ctx, "unique_children", child.GetKey())
+	if err != nil {
+		t.Fatalf("get restored child: %v", err)
+	}
+	if item.Version != 3 {
+		t.Errorf("expected version 3, got %d", item.Version)
+	}
+	if has, err := s.HasActiveChildren(ctx, "parent#p1"); err != nil || !has {
+		t.Errorf("expected restored relationship, got %v (err %v)", has, err)
+	}
+	for _, row := range db.Items(store.DefaultConfig().UniqueTable) {
+		if _, ok := row["ttl"]; ok {
+			t.Errorf("expected unique constraint %v to be active", row["field_name"])
+		}
+	}
+
+	// Unique values are claimed again
+	dup := UniqueChild{ID: "u2", ParentID: "p1", Name: "name", Slug: "other"}
+	if err := s.Create(ctx, dup, makeTestItem("u2", "name")); !errors.Is(err, store.ErrDuplicateValue) {
+		t.Errorf("expected ErrDuplicateValue, got %v", err)
+	}
+
+	if err := s.Restore(ctx, child, 3, store.RestoreOptions{}); !errors.Is(err, store.ErrNotDeleted) {
+		t.Errorf("expected ErrNotDeleted, got %v", err)
+	}
+	if err := s.Restore(ctx, Parent{ID: "missing"}, 1, store.RestoreOptions{}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRestore_PurgedConstraints(t *testing.T) {
+	cfg := store.DefaultConfig()
+	s, db := newMemStore(t, cfg)
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	purge := func() {
+		t.Helper()
+		for _, row := range db.Items(cfg.UniqueTable) {
+			_, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+				TableName: &cfg.UniqueTable,
+				Key:       store.PK{"pk": row["pk"], "sk": row["sk"]},
+			})
+			if err != nil {
+				t.Fatalf("purge constraint: %v", err)
+			}
+		}
+	}
+	fields := func() map[string]string {
+		t.Helper()
+		got := make(map[string]string)
+		for _, row := range db.Items(cfg.UniqueTable) {
+			if v, ok := row["entity_type"].(*types.AttributeValueMemberS); !ok || v.Value != "unique_child" {
+				t.Errorf("expected entity_type unique_child, got %v", row["entity_type"])
+			}
+			name, _ := row["field_name"].(*types.AttributeValueMemberS)
+			value, _ := row["field_value"].(*types.AttributeValueMemberS)
+			if name != nil && value != nil {
+				got[name.Value] = value.Value
+			}
+		}
+		return got
+	}
+
+	child := UniqueChild{ID: "u1", ParentID: "p1", Name: "name", Slug: "slug"}
+	if err := s.Create(ctx, child, makeTestItem("u1", "name")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+	deleteLikeCascade(t, s, db, child)
+	purge()
+
+	// Re-created constraints are described like those Create writes
+	if err := s.Restore(ctx, child, 2, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := fields(); len(got) != 2 || got["name"] != "name" || got["slug"] != "slug" {
+		t.Errorf("expected name and slug constraints, got %v", got)
+	}
+
+	// Without the entity's fields, those stored under their own name are
+	// recovered from the item
+	deleteLikeCascade(t, s, db, child)
+	purge()
+	if err := s.Restore(ctx, UniqueChild{ID: "u1", ParentID: "p1"}, 4, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if got := fields(); len(got) != 1 || got["name"] != "name" {
+		t.Errorf("expected the name constraint, got %v", got)
+	}
+	if n := len(db.Items(cfg.UniqueTable)); n != 2 {
+		t.Errorf("expected 2 constraints, got %d", n)
+	}
+}
+
+func TestRestore_RequiresActiveParent(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	parent := Parent{ID: "p1"}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	child := Child{ID: "c1", ParentID: "p1"}
+	if err := s.Create(ctx, child, makeTestItem("c1", "Child")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+	deleteLikeCascade(t, s, db, child)
+	deleteLikeCascade(t, s, db, parent)
+
+	if err := s.Restore(ctx, child, 2, store.RestoreOptions{}); !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+}
+
+func TestRestore_ClaimedUniqueValue(t *testing.T) {
+	cfg := store.DefaultConfig()
+	s, db := newMemStore(t, cfg)
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	child := UniqueChild{ID: "u1", ParentID: "p1", Name: "name", Slug: "slug"}
+	if err := s.Create(ctx, child, makeTestItem("u1", "name")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+	deleteLikeCascade(t, s, db, child)
+
+	// DynamoDB purges the constraints first, and another entity claims the slug
+	for _, row := range db.Items(cfg.UniqueTable) {
+		key := store.PK{"pk": row["pk"], "sk": row["sk"]}
+		if _, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{TableName: &cfg.UniqueTable, Key: key}); err != nil {
+			t.Fatalf("purge constraint: %v", err)
+		}
+	}
+	claimer := UniqueChild{ID: "u2", ParentID: "p1", Name: "other", Slug: "slug"}
+	if err := s.Create(ctx, claimer, makeTestItem("u2", "other")); err != nil {
+		t.Fatalf("create claimer: %v", err)
+	}
+
+	err := s.Restore(ctx, child, 2, store.RestoreOptions{})
+	var dupErr *store.DuplicateValueError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("expected DuplicateValueError, got %v", err)
+	}
+	if dupErr.Field != "slug" || dupErr.Value != "slug" {
+		t.Errorf("expected slug collision, got %+v", dupErr)
+	}
+	if _, err := s.Get(ctx, "unique_children", child.GetKey()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected child to remain deleted, got %v", err)
+	}
+}
+
+func TestRestore_Subtree(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	parent := Parent{ID: "p1"}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	for _, id := range []string{"c1", "c2", "gone"} {
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, id)); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+
+	// "gone" was deleted on its own, before the parent
+	if err := s.SetTTLByKey(ctx, "children", Child{ID: "gone"}.GetKey(), 1000); err != nil {
+		t.Fatalf("delete gone: %v", err)
+	}
+	propagateTTL(t, s, db, "children", Child{ID: "gone"}.GetKey(), 1000)
+
+	ttl := deleteLikeCascade(t, s, db, parent)
+	for _, id := range []string{"c1", "c2"} {
+		key := Child{ID: id}.GetKey()
+		if err := s.SetTTLByKey(ctx, "children", key, ttl); err != nil {
+			t.Fatalf("cascade to %s: %v", id, err)
+		}
+		propagateTTL(t, s, db, "children", key, ttl)
+	}
+
+	if err := s.Restore(ctx, parent, 2, store.RestoreOptions{Subtree: true}); err != nil {
+		t.Fatalf("restore subtree: %v", err)
+	}
+
+	children, err := s.Query(ctx, &store.QueryInput{
+		TableName:              store.DefaultConfig().RelationshipTable,
+		KeyConditionExpression: "pk = :pk",
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "parent#p1#00"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("query relationships: %v", err)
+	}
+	if len(children) != 2 {
+		t.Errorf("expected 2 restored relationships, got %d", len(children))
+	}
+	for _, id := range []string{"c1", "c2"} {
+		if _, err := s.Get(ctx, "children", Child{ID: id}.GetKey()); err != nil {
+			t.Errorf("expected %s to be restored, got %v", id, err)
+		}
+	}
+	if _, err := s.Get(ctx, "children", Child{ID: "gone"}.GetKey()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected separately deleted child to stay deleted, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// Scan scans a table with automatic TTL filtering and returns all active
+// items. Use ScanIter to process large tables without buffering them.
+func (s *Store) Scan(ctx context.Context, input *ScanInput) ([]*Item, error) {
+	var items []*Item
+	for item, err := range s.ScanIter(ctx, input) {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ScanIter returns an iterator over the active items in a table.
+//
+// When input.TotalSegments is greater than 1 the table is scanned as a
+// DynamoDB parallel scan, with at most input.Concurrency segments in flight.
+// Items from parallel segments are yielded in the order they arrive.
+// Outstanding segments are cancelled when the loop exits early or a segment
+// fails, and the iterator does not return until they have stopped.
+func (s *Store) ScanIter(ctx context.Context, input *ScanInput) iter.Seq2[*Item, error] {
+	return s.scanIter(ctx, input, ActiveFilterExpr())
+}
+
+// scanIter implements ScanIter, keeping only the items that match
+// baseFilter as well as input.FilterExpression.
+func (s *Store) scanIter(ctx context.Context, input *ScanInput, baseFilter string) iter.Seq2[*Item, error] {
+	totalSegments := input.TotalSegments
+	if totalSegments <= 1 {
+		return func(yield func(*Item, error) bool) {
+			err := s.scanSegment(ctx, input, baseFilter, -1, func(item *Item) bool {
+				return yield(item, nil)
+			})
+			if err != nil {
+				yield(nil, err)
+			}
+		}
+	}
+
+	workers := input.Concurrency
+	if workers < 1 || workers > int(totalSegments) {
+		workers = int(totalSegments)
+	}
+
+	return func(yield func(*Item, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+
+		type result struct {
+			item *Item
+			err  error
+		}
+		results := make(chan result)
+		segments := make(chan int32)
+		var wg sync.WaitGroup
+
+		send := func(r result) bool {
+			select {
+			case results <- r:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Bounded worker pool over segments
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for segment := range segments {
+					err := s.scanSegment(ctx, input, baseFilter, segment, func(item *Item) bool {
+						return send(result{item: item})
+					})
+					if err != nil {
+						send(result{err: fmt.Errorf("segment %d: %w", segment, err)})
+						return
+					}
+				}
+			}()
+		}
+
+		go func() {
+			defer close(segments)
+			for segment := int32(0); segment < totalSegments; segment++ {
+				select {
+				case segments <- segment:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+
+		// Stop outstanding segments and wait for them to exit
+		defer func() {
+			cancel()
+			for range results {
+			}
+		}()
+
+		for r := range results {
+			if r.err != nil {
+				yield(nil, r.err)
+				return
+			}
+			if !yield(r.item, nil) {
+				return
+			}
+		}
+	}
+}
+
+// scanSegment pages through one scan segment (or the whole table when
+// segment is negative), passing each item to fn until fn returns false.
+func (s *Store) scanSegment(ctx context.Context, input *ScanInput, baseFilter string, segment int32, fn func(*Item) bool) error {
+	scanInput := buildScanInput(input, baseFilter)
+	if segment >= 0 {
+		scanInput.Segment = aws.Int32(segment)
+		scanInput.TotalSegments = aws.Int32(input.TotalSegments)
+	}
+
+	paginator := dynamodb.NewScanPaginator(s.client, scanInput)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, raw := range page.Items {
+			if !fn(s.unmarshalItem(raw)) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// buildScanInput converts a ScanInput to a DynamoDB scan with baseFilter
+// merged into any caller-provided filter.
+func buildScanInput(input *ScanInput, baseFilter string) *dynamodb.ScanInput {
+	filterExpr, exprNames, exprValues := mergeFilter(baseFilter,
+		input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+
+	scanInput := &dynamodb.ScanInput{
+		TableName:                 aws.String(input.TableName),
+		FilterExpression:          aws.String(filterExpr),
+		ExpressionAttributeNames:  exprNames,
+		ExpressionAttributeValues: exprValues,
+	}
+	if input.IndexName != "" {
+		scanInput.IndexName = aws.String(input.IndexName)
+	}
+	if input.Limit > 0 {
+		scanInput.Limit = aws.Int32(input.Limit)
+	}
+	return scanInput
+}
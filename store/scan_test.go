@@ -0,0 +1,142 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+)
+
+// seedParents creates count parents and soft-deletes every third one.
+func seedParents(t *testing.T, s *store.Store, count int) (active int) {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; i < count; i++ {
+		p := Parent{ID: fmt.Sprintf("p%02d", i)}
+		if err := s.Create(ctx, p, makeTestItem(p.ID, p.ID)); err != nil {
+			t.Fatalf("create %s: %v", p.ID, err)
+		}
+		if i%3 == 0 {
+			if err := s.Delete(ctx, p, store.DeleteOptions{}); err != nil {
+				t.Fatalf("delete %s: %v", p.ID, err)
+			}
+			continue
+		}
+		active++
+	}
+	return active
+}
+
+func TestScan_FiltersDeleted(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	active := seedParents(t, s, 10)
+
+	items, err := s.Scan(context.Background(), &store.ScanInput{TableName: "parents", Limit: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != active {
+		t.Errorf("expected %d active items, got %d", active, len(items))
+	}
+	for _, item := range items {
+		if item.EntityRef == "" || item.Version == 0 {
+			t.Errorf("expected managed fields to be decoded, got %+v", item)
+		}
+	}
+}
+
+func TestScan_CustomFilter(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	seedParents(t, s, 6)
+
+	items, err := s.Scan(context.Background(), &store.ScanInput{
+		TableName:                "parents",
+		FilterExpression:         "#name = :name",
+		ExpressionAttributeNames: map[string]string{"#name": "name"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name": &types.AttributeValueMemberS{Value: "p01"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 1 || items[0].EntityRef != "parent#p01" {
+		t.Errorf("expected only parent#p01, got %d items", len(items))
+	}
+}
+
+func TestScan_ParallelSegments(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	active := seedParents(t, s, 30)
+
+	var inFlight, maxInFlight atomic.Int32
+	var segCount atomic.Int32
+	db.SetInterceptor(func(_ context.Context, op string, input any) error {
+		if op != "Scan" {
+			return nil
+		}
+		in := input.(*dynamodb.ScanInput)
+		if in.Segment == nil || *in.TotalSegments != 8 {
+			return errors.New("expected a segmented scan")
+		}
+		segCount.Add(1)
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		return nil
+	})
+
+	seen := make(map[string]bool)
+	for item, err := range s.ScanIter(context.Background(), &store.ScanInput{
+		TableName:     "parents",
+		TotalSegments: 8,
+		Concurrency:   3,
+	}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if seen[item.EntityRef] {
+			t.Errorf("item %s yielded twice", item.EntityRef)
+		}
+		seen[item.EntityRef] = true
+	}
+
+	if len(seen) != active {
+		t.Errorf("expected %d items, got %d", active, len(seen))
+	}
+	if n := segCount.Load(); n < 8 {
+		t.Errorf("expected every segment to be scanned, got %d scans", n)
+	}
+	if m := maxInFlight.Load(); m > 3 {
+		t.Errorf("expected at most 3 concurrent segments, got %d", m)
+	}
+}
+
+func TestScanIter_SegmentError(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	seedParents(t, s, 10)
+
+	boom := errors.New("boom")
+	db.SetInterceptor(func(_ context.Context, op string, input any) error {
+		if in, ok := input.(*dynamodb.ScanInput); ok && op == "Scan" && *in.Segment == 2 {
+			return boom
+		}
+		return nil
+	})
+
+	_, err := s.Scan(context.Background(), &store.ScanInput{TableName: "parents", TotalSegments: 4})
+	if !errors.Is(err, boom) {
+		t.Errorf("expected segment error, got %v", err)
+	}
+}
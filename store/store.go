@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,27 +19,31 @@ import (
 
 // Store provides DynamoDB operations with hierarchical entity support.
 type Store struct {
-	client   *dynamodb.Client
-	config   Config
-	registry *Registry
+	client    DynamoDBClient
+	config    Config
+	registry  *Registry
+	ancestors *refCache
 }
 
 // New creates a new Store instance.
-func New(client *dynamodb.Client, config Config) *Store {
+// The client is typically a *dynamodb.Client but may be any DynamoDBClient.
+func New(client DynamoDBClient, config Config) *Store {
 	config.validate()
 	return &Store{
-		client: client,
-		config: config,
+		client:    client,
+		config:    config,
+		ancestors: newRefCache(config.AncestorCacheTTL),
 	}
 }
 
 // NewWithRegistry creates a new Store instance with a relationship registry.
-func NewWithRegistry(client *dynamodb.Client, config Config, registry *Registry) *Store {
+func NewWithRegistry(client DynamoDBClient, config Config, registry *Registry) *Store {
 	config.validate()
 	return &Store{
-		client:   client,
-		config:   config,
-		registry: registry,
+		client:    client,
+		config:    config,
+		registry:  registry,
+		ancestors: newRefCache(config.AncestorCacheTTL),
 	}
 }
 
@@ -52,6 +57,11 @@ func (s *Store) Registry() *Registry {
 	return s.registry
 }
 
+// Config returns the store's configuration, with defaults applied.
+func (s *Store) Config() Config {
+	return s.config
+}
+
 // relationshipPK computes the sharded partition key for a relationship record.
 func (s *Store) relationshipPK(parentRef, childRef string) string {
 	return shard.RelationshipPK(parentRef, childRef, s.config.NumShards)
@@ -59,39 +69,58 @@ func (s *Store) relationshipPK(parentRef, childRef string) string {
 
 // Create creates a new entity with parent validation and unique constraints.
 func (s *Store) Create(ctx context.Context, entity Entity, item map[string]types.AttributeValue) error {
-	items := []types.TransactWriteItem{}
-	now := time.Now()
-	nowUnix := now.Unix()
+	if err := s.setPath(ctx, entity, item, nil); err != nil {
+		return err
+	}
+	plan, err := s.buildCreate(entity, item, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: plan.items,
+	})
+
+	return mapTransactionError(err, plan.items, plan.failures)
+}
+
+// createPlan holds the transaction items that create one entity.
+type createPlan struct {
+	items []types.TransactWriteItem
+
+	// targets identifies the table item each transaction item touches.
+	targets []string
+
+	// failures holds the error each item's failed condition maps to.
+	failures []error
+
+	// Track item indices for merging parent checks
+	parentCheckIndex int
+	entityPutIndex   int
+}
+
+// buildCreate builds the transaction items for creating an entity:
+// parent check, unique constraint puts, entity put and relationship put.
+// It sets the ORM-managed fields on item.
+func (s *Store) buildCreate(entity Entity, item map[string]types.AttributeValue, now time.Time) (*createPlan, error) {
+	plan := &createPlan{parentCheckIndex: -1}
 	nowISO := now.UTC().Format(time.RFC3339)
 
-	// Track item indices for error mapping
-	parentCheckIndex := -1
-	var entityPutIndex int
+	add := func(target string, txItem types.TransactWriteItem, failure error) int {
+		plan.items = append(plan.items, txItem)
+		plan.targets = append(plan.targets, target)
+		plan.failures = append(plan.failures, failure)
+		return len(plan.items) - 1
+	}
 
 	// 1. Add parent condition check if entity has a parent
 	if checker, ok := entity.(ParentChecker); ok {
 		if check := checker.ParentCheck(); check != nil {
-			parentCheckIndex = len(items)
-			// Use custom condition expression if provided, otherwise use default
-			condExpr := check.ConditionExpr
-			if condExpr == "" {
-				condExpr = ParentExistsCondition()
+			checkItem := parentCheckItem(check, now)
+			if s.config.TrackChildCount {
+				checkItem = withChildCount(checkItem, 1)
 			}
-			items = append(items, types.TransactWriteItem{
-				ConditionCheck: &types.ConditionCheck{
-					TableName:           aws.String(check.TableName),
-					Key:                 check.Key,
-					ConditionExpression: aws.String(condExpr),
-					ExpressionAttributeNames: map[string]string{
-						"#ttl": "ttl",
-					},
-					ExpressionAttributeValues: map[string]types.AttributeValue{
-						":now": &types.AttributeValueMemberN{
-							Value: strconv.FormatInt(nowUnix, 10),
-						},
-					},
-				},
-			})
+			plan.parentCheckIndex = add(tableKeyString(check.TableName, check.Key), checkItem, ErrParentNotFound)
 		}
 	}
 
@@ -100,6 +129,9 @@ func (s *Store) Create(ctx context.Context, entity Entity, item map[string]types
 	item["version"] = &types.AttributeValueMemberN{Value: "1"}
 	item["created_at"] = &types.AttributeValueMemberS{Value: nowISO}
 	item["updated_at"] = &types.AttributeValueMemberS{Value: nowISO}
+	if s.config.TrackChildCount {
+		item["child_count"] = &types.AttributeValueMemberN{Value: "0"}
+	}
 
 	// Set parent_ref if entity has parent
 	var parentRef string
@@ -115,24 +147,9 @@ func (s *Store) Create(ctx context.Context, entity Entity, item map[string]types
 	if uf, ok := entity.(UniqueFielder); ok && parentRef != "" {
 		entityType := entity.EntityType()
 		for field, value := range uf.UniqueFields() {
-			constraintPK := shard.UniqueConstraintPK(parentRef, entityType, field, value)
-			uniquePKs = append(uniquePKs, constraintPK)
-
-			items = append(items, types.TransactWriteItem{
-				Put: &types.Put{
-					TableName: aws.String(s.config.UniqueTable),
-					Item: map[string]types.AttributeValue{
-						"pk":          &types.AttributeValueMemberS{Value: constraintPK},
-						"sk":          &types.AttributeValueMemberS{Value: "CONSTRAINT"},
-						"parent_ref":  &types.AttributeValueMemberS{Value: parentRef},
-						"entity_type": &types.AttributeValueMemberS{Value: entityType},
-						"field_name":  &types.AttributeValueMemberS{Value: field},
-						"field_value": &types.AttributeValueMemberS{Value: value},
-						"entity_ref":  &types.AttributeValueMemberS{Value: entity.EntityRef()},
-					},
-					ConditionExpression: aws.String("attribute_not_exists(pk)"),
-				},
-			})
+			uniquePKs = append(uniquePKs, shard.UniqueConstraintPK(parentRef, entityType, field, value))
+			target, put := s.uniqueConstraintPut(parentRef, entityType, field, value, entity.EntityRef())
+			add(target, put, &DuplicateValueError{EntityType: entityType, Field: field, Value: value})
 		}
 	}
 
@@ -140,51 +157,102 @@ func (s *Store) Create(ctx context.Context, entity Entity, item map[string]types
 	if len(uniquePKs) > 0 {
 		uniquePKsAttr, err := attributevalue.MarshalList(uniquePKs)
 		if err != nil {
-			return fmt.Errorf("marshal unique PKs: %w", err)
+			return nil, fmt.Errorf("marshal unique PKs: %w", err)
 		}
 		item["_unique_pks"] = &types.AttributeValueMemberL{Value: uniquePKsAttr}
 	}
 
 	// 4. Add the entity put
-	entityPutIndex = len(items)
-	items = append(items, types.TransactWriteItem{
+	plan.entityPutIndex = add(tableKeyString(entity.TableName(), entity.GetKey()), types.TransactWriteItem{
 		Put: &types.Put{
 			TableName:           aws.String(entity.TableName()),
 			Item:                item,
 			ConditionExpression: aws.String("attribute_not_exists(id)"),
 		},
-	})
+	}, ErrAlreadyExists)
 
 	// 5. Add relationship record if entity has a parent
 	if parentRef != "" {
-		childRef := entity.EntityRef()
-		shardPK := s.relationshipPK(parentRef, childRef)
+		target, put := s.relationshipPut(entity.EntityRef(), parentRef, entity.TableName(), entity.GetKey())
+		add(target, put, nil)
+	}
 
-		keyAttr, err := attributevalue.MarshalMap(entity.GetKey())
-		if err != nil {
-			return fmt.Errorf("marshal key: %w", err)
-		}
-
-		items = append(items, types.TransactWriteItem{
-			Put: &types.Put{
-				TableName: aws.String(s.config.RelationshipTable),
-				Item: map[string]types.AttributeValue{
-					"pk":          &types.AttributeValueMemberS{Value: shardPK},
-					"child_ref":   &types.AttributeValueMemberS{Value: childRef},
-					"parent_ref":  &types.AttributeValueMemberS{Value: parentRef},
-					"child_table": &types.AttributeValueMemberS{Value: entity.TableName()},
-					"child_key":   &types.AttributeValueMemberM{Value: keyAttr},
+	return plan, nil
+}
+
+// parentCheckItem builds the condition check that a parent exists and is
+// active, using the check's custom condition if it has one.
+func parentCheckItem(check *ConditionCheck, now time.Time) types.TransactWriteItem {
+	condExpr := check.ConditionExpr
+	if condExpr == "" {
+		condExpr = ActiveParentCondition()
+	}
+	exprNames := map[string]string{
+		"#ttl": "ttl",
+	}
+	// Custom conditions predating deleted_at must not get an unused name
+	if strings.Contains(condExpr, "#deleted_at") {
+		exprNames["#deleted_at"] = "deleted_at"
+	}
+	return types.TransactWriteItem{
+		ConditionCheck: &types.ConditionCheck{
+			TableName:                aws.String(check.TableName),
+			Key:                      check.Key,
+			ConditionExpression:      aws.String(condExpr),
+			ExpressionAttributeNames: exprNames,
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": &types.AttributeValueMemberN{
+					Value: strconv.FormatInt(now.Unix(), 10),
 				},
 			},
-		})
+		},
 	}
+}
 
-	// 6. Execute transaction
-	_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
-		TransactItems: items,
-	})
+// relationshipPut builds the put of a child's (active) relationship record
+// and returns it with its target.
+func (s *Store) relationshipPut(childRef, parentRef, childTable string, childKey PK) (string, types.TransactWriteItem) {
+	key := PK{
+		"pk":        &types.AttributeValueMemberS{Value: s.relationshipPK(parentRef, childRef)},
+		"child_ref": &types.AttributeValueMemberS{Value: childRef},
+	}
+	return tableKeyString(s.config.RelationshipTable, key), types.TransactWriteItem{
+		Put: &types.Put{
+			TableName: aws.String(s.config.RelationshipTable),
+			Item: map[string]types.AttributeValue{
+				"pk":          key["pk"],
+				"child_ref":   key["child_ref"],
+				"parent_ref":  &types.AttributeValueMemberS{Value: parentRef},
+				"child_table": &types.AttributeValueMemberS{Value: childTable},
+				"child_key":   &types.AttributeValueMemberM{Value: childKey},
+			},
+		},
+	}
+}
 
-	return s.mapCreateTransactionError(err, parentCheckIndex, entityPutIndex)
+// uniqueConstraintPut builds the put of a unique constraint record owned by
+// entityRef and returns it with its target. The put fails if another entity
+// already has the value in the parent scope.
+func (s *Store) uniqueConstraintPut(parentRef, entityType, field, value, entityRef string) (string, types.TransactWriteItem) {
+	key := PK{
+		"pk": &types.AttributeValueMemberS{Value: shard.UniqueConstraintPK(parentRef, entityType, field, value)},
+		"sk": &types.AttributeValueMemberS{Value: "CONSTRAINT"},
+	}
+	return tableKeyString(s.config.UniqueTable, key), types.TransactWriteItem{
+		Put: &types.Put{
+			TableName: aws.String(s.config.UniqueTable),
+			Item: map[string]types.AttributeValue{
+				"pk":          key["pk"],
+				"sk":          key["sk"],
+				"parent_ref":  &types.AttributeValueMemberS{Value: parentRef},
+				"entity_type": &types.AttributeValueMemberS{Value: entityType},
+				"field_name":  &types.AttributeValueMemberS{Value: field},
+				"field_value": &types.AttributeValueMemberS{Value: value},
+				"entity_ref":  &types.AttributeValueMemberS{Value: entityRef},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(pk)"),
+		},
+	}
 }
 
 // Get retrieves an entity by key, returning ErrNotFound if deleted or missing.
@@ -209,29 +277,58 @@ func (s *Store) Get(ctx context.Context, table string, key PK) (*Item, error) {
 }
 
 // Query queries entities with automatic TTL filtering.
+// All pages are read; use QueryPage or QueryIter to avoid buffering every page.
 func (s *Store) Query(ctx context.Context, input *QueryInput) ([]*Item, error) {
-	// Merge TTL filter with any existing filter
-	filterExpr := TTLFilterExpr()
-	if input.FilterExpression != "" {
-		filterExpr = fmt.Sprintf("(%s) AND (%s)", input.FilterExpression, filterExpr)
+	var items []*Item
+	for item, err := range s.QueryIter(ctx, input) {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
 	}
+	return items, nil
+}
 
-	// Merge expression attribute names
-	exprNames := map[string]string{"#ttl": "ttl"}
-	for k, v := range input.ExpressionAttributeNames {
-		exprNames[k] = v
+// QueryPage reads a single page of a query with automatic TTL filtering.
+// Pass an empty cursor for the first page and Page.Cursor for subsequent
+// pages; an empty Page.Cursor means there are no more pages. A cursor only
+// resumes a query of the same table, index and key condition values, and
+// fails with ErrInvalidCursor otherwise.
+//
+// input.Limit bounds the items DynamoDB evaluates before filtering, so a
+// page may contain fewer items (even none) while a cursor is still returned.
+func (s *Store) QueryPage(ctx context.Context, input *QueryInput, cursor string) (*Page, error) {
+	scope := queryCursorScope(input)
+	startKey, err := decodeCursor(scope, cursor)
+	if err != nil {
+		return nil, err
 	}
 
-	// Merge expression attribute values
-	exprValues := map[string]types.AttributeValue{
-		":now": &types.AttributeValueMemberN{
-			Value: strconv.FormatInt(time.Now().Unix(), 10),
-		},
+	queryInput := s.buildQueryInput(input)
+	queryInput.ExclusiveStartKey = startKey
+
+	result, err := s.client.Query(ctx, queryInput)
+	if err != nil {
+		return nil, err
 	}
-	for k, v := range input.ExpressionAttributeValues {
-		exprValues[k] = v
+
+	page := &Page{Items: make([]*Item, 0, len(result.Items))}
+	for _, raw := range result.Items {
+		page.Items = append(page.Items, s.unmarshalItem(raw))
+	}
+	if page.Cursor, err = encodeCursor(scope, result.LastEvaluatedKey); err != nil {
+		return nil, err
 	}
 
+	return page, nil
+}
+
+// buildQueryInput converts a QueryInput to a DynamoDB query with the TTL
+// filter merged into any caller-provided filter.
+func (s *Store) buildQueryInput(input *QueryInput) *dynamodb.QueryInput {
+	filterExpr, exprNames, exprValues := mergeTTLFilter(
+		input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+
 	queryInput := &dynamodb.QueryInput{
 		TableName:                 aws.String(input.TableName),
 		KeyConditionExpression:    aws.String(input.KeyConditionExpression),
@@ -250,186 +347,160 @@ func (s *Store) Query(ctx context.Context, input *QueryInput) ([]*Item, error) {
 		queryInput.ScanIndexForward = input.ScanIndexForward
 	}
 
-	// Paginate through all results
-	var items []*Item
-	paginator := dynamodb.NewQueryPaginator(s.client, queryInput)
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			return nil, err
-		}
-		for _, raw := range page.Items {
-			items = append(items, s.unmarshalItem(raw))
-		}
-	}
-
-	return items, nil
+	return queryInput
 }
 
 // Update updates an entity with optimistic locking.
 // If the entity implements UniqueFielder and unique fields change,
 // old constraints are deleted and new ones created transactionally.
 func (s *Store) Update(ctx context.Context, entity Entity, item map[string]types.AttributeValue, expectedVersion int64) error {
-	// Check if entity has unique fields that might need updating
-	uf, hasUniqueFields := entity.(UniqueFielder)
-	pc, hasParent := entity.(ParentChecker)
+	defer s.ancestors.forget(entity.EntityRef())
 
-	// If entity has unique fields and a parent, check if any unique fields changed
-	if hasUniqueFields && hasParent && pc.ParentRef() != "" {
-		return s.updateWithUniqueConstraints(ctx, entity, item, expectedVersion, uf, pc)
+	plan, err := s.buildUpdate(ctx, entity, item, expectedVersion)
+	if err != nil {
+		return err
 	}
 
-	// Fast path: simple update without unique constraint handling
-	return s.updateSimple(ctx, entity, item, expectedVersion)
-}
-
-// updateSimple performs a basic update without unique constraint handling.
-func (s *Store) updateSimple(ctx context.Context, entity Entity, item map[string]types.AttributeValue, expectedVersion int64) error {
-	now := time.Now().UTC().Format(time.RFC3339)
-
-	// Build SET expression from item attributes
-	var setClauses []string
-	exprNames := map[string]string{
-		"#updated_at": "updated_at",
-		"#version":    "version",
-		"#ttl":        "ttl",
-	}
-	exprValues := map[string]types.AttributeValue{
-		":updated_at":       &types.AttributeValueMemberS{Value: now},
-		":one":              &types.AttributeValueMemberN{Value: "1"},
-		":expected_version": &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)},
-	}
+	// Fast path: simple update without unique constraint changes
+	if len(plan.items) == 1 {
+		update := plan.items[0].Update
+		_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 update.TableName,
+			Key:                       update.Key,
+			UpdateExpression:          update.UpdateExpression,
+			ConditionExpression:       update.ConditionExpression,
+			ExpressionAttributeNames:  update.ExpressionAttributeNames,
+			ExpressionAttributeValues: update.ExpressionAttributeValues,
+		})
 
-	// Add user-provided attributes
-	i := 0
-	for k, v := range item {
-		// Skip managed fields
-		if k == "id" || k == "entity_ref" || k == "parent_ref" || k == "version" ||
-			k == "created_at" || k == "updated_at" || k == "ttl" || k == "_unique_pks" {
-			continue
+		if err != nil {
+			var condErr *types.ConditionalCheckFailedException
+			if errors.As(err, &condErr) {
+				return ErrConcurrentModification
+			}
+			return err
 		}
-		nameKey := fmt.Sprintf("#attr%d", i)
-		valueKey := fmt.Sprintf(":val%d", i)
-		exprNames[nameKey] = k
-		exprValues[valueKey] = v
-		setClauses = append(setClauses, fmt.Sprintf("%s = %s", nameKey, valueKey))
-		i++
+		return nil
 	}
 
-	// Add managed field updates
-	setClauses = append(setClauses, "#updated_at = :updated_at", "#version = #version + :one")
+	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: plan.items,
+	})
 
-	updateExpr := "SET " + joinStrings(setClauses, ", ")
+	return mapTransactionError(err, plan.items, plan.failures)
+}
 
-	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(entity.TableName()),
-		Key:                       entity.GetKey(),
-		UpdateExpression:          aws.String(updateExpr),
-		ConditionExpression:       aws.String("#version = :expected_version AND attribute_not_exists(#ttl)"),
-		ExpressionAttributeNames:  exprNames,
-		ExpressionAttributeValues: exprValues,
-	})
+// updatePlan holds the transaction items that update one entity.
+type updatePlan struct {
+	items []types.TransactWriteItem
 
-	if err != nil {
-		var condErr *types.ConditionalCheckFailedException
-		if errors.As(err, &condErr) {
-			return ErrConcurrentModification
-		}
-		return err
-	}
-	return nil
+	// targets identifies the table item each transaction item touches.
+	targets []string
+
+	// failures holds the error each item's failed condition maps to.
+	failures []error
+
+	// entityUpdateIndex is the index of the entity update (always last).
+	entityUpdateIndex int
 }
 
-// updateWithUniqueConstraints handles updates where unique fields may have changed.
-func (s *Store) updateWithUniqueConstraints(ctx context.Context, entity Entity, item map[string]types.AttributeValue, expectedVersion int64, uf UniqueFielder, pc ParentChecker) error {
-	// Fetch current entity to get old unique field values
-	current, err := s.Get(ctx, entity.TableName(), entity.GetKey())
-	if err != nil {
-		return err
+// buildUpdate builds the transaction items for updating an entity.
+// If the entity has unique fields under a parent, the current item is read
+// to find changed fields, whose old constraints are deleted and new ones put.
+func (s *Store) buildUpdate(ctx context.Context, entity Entity, item map[string]types.AttributeValue, expectedVersion int64) (*updatePlan, error) {
+	plan := &updatePlan{}
+	add := func(target string, txItem types.TransactWriteItem, failure error) int {
+		plan.items = append(plan.items, txItem)
+		plan.targets = append(plan.targets, target)
+		plan.failures = append(plan.failures, failure)
+		return len(plan.items) - 1
 	}
 
-	parentRef := pc.ParentRef()
-	entityType := entity.EntityType()
-	newUniques := uf.UniqueFields()
+	// Check if entity has unique fields that might need updating
+	uf, hasUniqueFields := entity.(UniqueFielder)
+	pc, hasParent := entity.(ParentChecker)
 
-	// Extract old unique values from current item
-	oldUniques := make(map[string]string)
-	for field := range newUniques {
-		if v, ok := current.Raw[field].(*types.AttributeValueMemberS); ok {
-			oldUniques[field] = v.Value
+	var newUniquePKs []string
+	if hasUniqueFields && hasParent && pc.ParentRef() != "" {
+		// Fetch current entity to get old unique field values
+		current, err := s.Get(ctx, entity.TableName(), entity.GetKey())
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	// Check if any unique fields changed
-	var changedFields []string
-	for field, newValue := range newUniques {
-		if oldValue, ok := oldUniques[field]; !ok || oldValue != newValue {
-			changedFields = append(changedFields, field)
+		parentRef := pc.ParentRef()
+		entityType := entity.EntityType()
+		newUniques := uf.UniqueFields()
+
+		// Extract old unique values from current item
+		oldUniques := make(map[string]string)
+		for field := range newUniques {
+			if v, ok := current.Raw[field].(*types.AttributeValueMemberS); ok {
+				oldUniques[field] = v.Value
+			}
 		}
-	}
 
-	// If no unique fields changed, use simple update
-	if len(changedFields) == 0 {
-		return s.updateSimple(ctx, entity, item, expectedVersion)
-	}
+		// Check if any unique fields changed
+		var changedFields []string
+		for field, newValue := range newUniques {
+			if oldValue, ok := oldUniques[field]; !ok || oldValue != newValue {
+				changedFields = append(changedFields, field)
+			}
+		}
 
-	// Build transaction for changed unique fields
-	now := time.Now().UTC().Format(time.RFC3339)
-	items := []types.TransactWriteItem{}
+		if len(changedFields) > 0 {
+			// Compute all new unique PKs (including unchanged ones for _unique_pks update)
+			for field, newValue := range newUniques {
+				newPK := shard.UniqueConstraintPK(parentRef, entityType, field, newValue)
+				newUniquePKs = append(newUniquePKs, newPK)
+			}
+		}
 
-	// Compute all new unique PKs (including unchanged ones for _unique_pks update)
-	var newUniquePKs []string
-	for field, newValue := range newUniques {
-		newPK := shard.UniqueConstraintPK(parentRef, entityType, field, newValue)
-		newUniquePKs = append(newUniquePKs, newPK)
-	}
-
-	// For each changed field: delete old constraint, create new constraint
-	for _, field := range changedFields {
-		oldValue := oldUniques[field]
-		newValue := newUniques[field]
-
-		// Delete old uniqueness record
-		if oldValue != "" {
-			oldPK := shard.UniqueConstraintPK(parentRef, entityType, field, oldValue)
-			items = append(items, types.TransactWriteItem{
-				Delete: &types.Delete{
-					TableName: aws.String(s.config.UniqueTable),
-					Key: map[string]types.AttributeValue{
-						"pk": &types.AttributeValueMemberS{Value: oldPK},
-						"sk": &types.AttributeValueMemberS{Value: "CONSTRAINT"},
+		// For each changed field: delete old constraint, create new constraint
+		for _, field := range changedFields {
+			oldValue := oldUniques[field]
+			newValue := newUniques[field]
+
+			// Delete old uniqueness record
+			if oldValue != "" {
+				oldKey := PK{
+					"pk": &types.AttributeValueMemberS{Value: shard.UniqueConstraintPK(parentRef, entityType, field, oldValue)},
+					"sk": &types.AttributeValueMemberS{Value: "CONSTRAINT"},
+				}
+				add(tableKeyString(s.config.UniqueTable, oldKey), types.TransactWriteItem{
+					Delete: &types.Delete{
+						TableName: aws.String(s.config.UniqueTable),
+						Key:       oldKey,
 					},
-				},
-			})
+				}, nil)
+			}
+
+			// Create new uniqueness record
+			target, put := s.uniqueConstraintPut(parentRef, entityType, field, newValue, entity.EntityRef())
+			add(target, put, &DuplicateValueError{EntityType: entityType, Field: field, Value: newValue})
 		}
+	}
 
-		// Create new uniqueness record
-		newPK := shard.UniqueConstraintPK(parentRef, entityType, field, newValue)
-		items = append(items, types.TransactWriteItem{
-			Put: &types.Put{
-				TableName: aws.String(s.config.UniqueTable),
-				Item: map[string]types.AttributeValue{
-					"pk":          &types.AttributeValueMemberS{Value: newPK},
-					"sk":          &types.AttributeValueMemberS{Value: "CONSTRAINT"},
-					"parent_ref":  &types.AttributeValueMemberS{Value: parentRef},
-					"entity_type": &types.AttributeValueMemberS{Value: entityType},
-					"field_name":  &types.AttributeValueMemberS{Value: field},
-					"field_value": &types.AttributeValueMemberS{Value: newValue},
-					"entity_ref":  &types.AttributeValueMemberS{Value: entity.EntityRef()},
-				},
-				// Fails if another entity already has this unique value
-				ConditionExpression: aws.String("attribute_not_exists(pk)"),
-			},
-		})
+	update, err := s.buildEntityUpdate(entity, item, expectedVersion, newUniquePKs)
+	if err != nil {
+		return nil, err
 	}
+	plan.entityUpdateIndex = add(tableKeyString(entity.TableName(), entity.GetKey()), types.TransactWriteItem{Update: update}, ErrConcurrentModification)
 
-	// Build the entity update
+	return plan, nil
+}
+
+// buildEntityUpdate builds the version-checked update of an entity's
+// attributes. If uniquePKs is non-empty, _unique_pks is replaced with it.
+func (s *Store) buildEntityUpdate(entity Entity, item map[string]types.AttributeValue, expectedVersion int64, uniquePKs []string) (*types.Update, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	// Build SET expression from item attributes
 	var setClauses []string
 	exprNames := map[string]string{
 		"#updated_at": "updated_at",
 		"#version":    "version",
 		"#ttl":        "ttl",
-		"#unique_pks": "_unique_pks",
 	}
 	exprValues := map[string]types.AttributeValue{
 		":updated_at":       &types.AttributeValueMemberS{Value: now},
@@ -442,7 +513,8 @@ func (s *Store) updateWithUniqueConstraints(ctx context.Context, entity Entity,
 	for k, v := range item {
 		// Skip managed fields
 		if k == "id" || k == "entity_ref" || k == "parent_ref" || k == "version" ||
-			k == "created_at" || k == "updated_at" || k == "ttl" || k == "_unique_pks" {
+			k == "created_at" || k == "updated_at" || k == "ttl" || k == "_unique_pks" ||
+			k == "child_count" || k == "_path" || k == cascadeJobAttr {
 			continue
 		}
 		nameKey := fmt.Sprintf("#attr%d", i)
@@ -457,32 +529,26 @@ func (s *Store) updateWithUniqueConstraints(ctx context.Context, entity Entity,
 	setClauses = append(setClauses, "#updated_at = :updated_at", "#version = #version + :one")
 
 	// Update _unique_pks with new PKs
-	uniquePKsAttr, err := attributevalue.MarshalList(newUniquePKs)
-	if err != nil {
-		return fmt.Errorf("marshal unique PKs: %w", err)
+	if len(uniquePKs) > 0 {
+		uniquePKsAttr, err := attributevalue.MarshalList(uniquePKs)
+		if err != nil {
+			return nil, fmt.Errorf("marshal unique PKs: %w", err)
+		}
+		exprNames["#unique_pks"] = "_unique_pks"
+		exprValues[":unique_pks"] = &types.AttributeValueMemberL{Value: uniquePKsAttr}
+		setClauses = append(setClauses, "#unique_pks = :unique_pks")
 	}
-	exprValues[":unique_pks"] = &types.AttributeValueMemberL{Value: uniquePKsAttr}
-	setClauses = append(setClauses, "#unique_pks = :unique_pks")
-
-	updateExpr := "SET " + joinStrings(setClauses, ", ")
-
-	items = append(items, types.TransactWriteItem{
-		Update: &types.Update{
-			TableName:                 aws.String(entity.TableName()),
-			Key:                       entity.GetKey(),
-			UpdateExpression:          aws.String(updateExpr),
-			ConditionExpression:       aws.String("#version = :expected_version AND attribute_not_exists(#ttl)"),
-			ExpressionAttributeNames:  exprNames,
-			ExpressionAttributeValues: exprValues,
-		},
-	})
 
-	// Execute transaction
-	_, err = s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
-		TransactItems: items,
-	})
+	updateExpr := "SET " + strings.Join(setClauses, ", ")
 
-	return s.mapUpdateTransactionError(err)
+	return &types.Update{
+		TableName:                 aws.String(entity.TableName()),
+		Key:                       entity.GetKey(),
+		UpdateExpression:          aws.String(updateExpr),
+		ConditionExpression:       aws.String("#version = :expected_version AND attribute_not_exists(#ttl)"),
+		ExpressionAttributeNames:  exprNames,
+		ExpressionAttributeValues: exprValues,
+	}, nil
 }
 
 // DeleteOptions configures delete behavior.
@@ -492,11 +558,51 @@ type DeleteOptions struct {
 
 	// OrphanProtect fails the delete if active children exist.
 	OrphanProtect bool
+
+	// Retention delays physical deletion. The entity is hidden from reads
+	// immediately, but its TTL is set Retention in the future so it can be
+	// listed with ListTrash and restored until DynamoDB purges it.
+	Retention time.Duration
+
+	// Sync cascades the delete to all descendants before returning instead
+	// of relying on the stream handler, and implies Cascade. See CascadeDelete.
+	Sync bool
+
+	// MaxDepth limits how many levels below the entity a synchronous
+	// cascade may reach (0 = unlimited).
+	MaxDepth int
+
+	// MaxItems limits how many descendants a synchronous cascade may delete
+	// (0 = unlimited).
+	MaxItems int
+
+	// Concurrency bounds the requests a synchronous cascade runs in
+	// parallel (default 8).
+	Concurrency int
+
+	// ExpectedVersion, if non-zero, fails the delete with
+	// ErrConcurrentModification unless the entity is at this version.
+	ExpectedVersion int64
+
+	// Strict fails the delete with ErrNotFound if the entity does not exist
+	// and ErrAlreadyDeleted if it is already deleted, instead of succeeding.
+	Strict bool
 }
 
-// Delete deletes an entity by setting its TTL.
+// Delete deletes an entity by setting its TTL. Deleting an entity that does
+// not exist or is already deleted succeeds unless opts.Strict is set.
 func (s *Store) Delete(ctx context.Context, entity Entity, opts DeleteOptions) error {
-	if opts.OrphanProtect && !opts.Cascade {
+	defer s.ancestors.forget(entity.EntityRef())
+
+	if opts.Sync {
+		_, err := s.CascadeDelete(ctx, entity, opts)
+		if errors.Is(err, ErrNotFound) && !opts.Strict {
+			return nil
+		}
+		return err
+	}
+
+	if opts.OrphanProtect && !opts.Cascade && !s.config.TrackChildCount {
 		hasChildren, err := s.HasActiveChildren(ctx, entity.EntityRef())
 		if err != nil {
 			return err
@@ -506,37 +612,171 @@ func (s *Store) Delete(ctx context.Context, entity Entity, opts DeleteOptions) e
 		}
 	}
 
-	return s.SetTTL(ctx, entity)
+	return s.softDelete(ctx, entity, opts)
 }
 
 // SetTTL marks an entity for deletion by setting its TTL to now.
 // This also increments the version to fail concurrent updates.
 func (s *Store) SetTTL(ctx context.Context, entity Entity) error {
+	return s.softDelete(ctx, entity, DeleteOptions{})
+}
+
+// softDelete marks an entity as deleted now, with its TTL set
+// opts.Retention in the future. Unless opts.Strict is set, deleting an
+// entity that is already deleted or does not exist succeeds.
+func (s *Store) softDelete(ctx context.Context, entity Entity, opts DeleteOptions) error {
 	now := time.Now()
+	orphanCheck := s.countsOrphans(opts)
+	update := s.softDeleteUpdate(entity.TableName(), entity.GetKey(), now.Add(opts.Retention).Unix(), now, opts.ExpectedVersion, orphanCheck)
+	var job *types.Put
+	if s.config.TrackCascadeJobs && opts.Cascade {
+		job = s.startCascadeJob(update, entity.EntityRef(), now)
+	}
+	old, deleted, err := s.markDeleted(ctx, update, s.countedParent(entity), job)
+	if err != nil || deleted {
+		return err
+	}
+	if old == nil {
+		if opts.Strict {
+			return ErrNotFound
+		}
+		return nil
+	}
+	if _, ok := itemTTL(old); ok {
+		if opts.Strict {
+			return ErrAlreadyDeleted
+		}
+		return nil
+	}
+	if orphanCheck {
+		if count, _ := itemChildCount(old); count > 0 {
+			return ErrHasChildren
+		}
+		if isUncounted(old) {
+			// Created before child counts were tracked
+			hasChildren, err := s.HasActiveChildren(ctx, entity.EntityRef())
+			if err != nil {
+				return err
+			}
+			if hasChildren {
+				return ErrHasChildren
+			}
+			opts.OrphanProtect = false
+			return s.softDelete(ctx, entity, opts)
+		}
+	}
+	return ErrConcurrentModification
+}
 
-	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName:           aws.String(entity.TableName()),
-		Key:                 entity.GetKey(),
-		UpdateExpression:    aws.String("SET #ttl = :now, #version = #version + :one"),
-		ConditionExpression: aws.String("attribute_not_exists(#ttl)"),
-		ExpressionAttributeNames: map[string]string{
-			"#ttl":     "ttl",
-			"#version": "version",
+// softDeleteUpdate builds the update that marks an existing, active entity
+// as deleted now with ttl, and increments its version. If expectedVersion is
+// non-zero the entity must be at that version, and with orphanCheck its
+// child count must be zero. On failure the update returns the old item.
+func (s *Store) softDeleteUpdate(table string, key PK, ttl int64, now time.Time, expectedVersion int64, orphanCheck bool) *types.Update {
+	condExpr := "attribute_exists(#version) AND attribute_not_exists(#ttl)"
+	exprNames := map[string]string{
+		"#ttl":        "ttl",
+		"#deleted_at": "deleted_at",
+		"#version":    "version",
+	}
+	exprValues := map[string]types.AttributeValue{
+		":ttl": &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(ttl, 10),
 		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":now": &types.AttributeValueMemberN{
-				Value: strconv.FormatInt(now.Unix(), 10),
-			},
-			":one": &types.AttributeValueMemberN{Value: "1"},
+		":now": &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(now.Unix(), 10),
 		},
-	})
+		":one": &types.AttributeValueMemberN{Value: "1"},
+	}
+	if expectedVersion != 0 {
+		condExpr += " AND #version = :expected_version"
+		exprValues[":expected_version"] = &types.AttributeValueMemberN{
+			Value: strconv.FormatInt(expectedVersion, 10),
+		}
+	}
+	if orphanCheck {
+		condExpr += " AND " + orphanCondition
+		exprNames["#child_count"] = "child_count"
+		exprValues[":zero"] = &types.AttributeValueMemberN{Value: "0"}
+	}
 
-	// Ignore condition failure - already has TTL (already deleted)
-	var condErr *types.ConditionalCheckFailedException
-	if errors.As(err, &condErr) {
+	return &types.Update{
+		TableName:                           aws.String(table),
+		Key:                                 key,
+		UpdateExpression:                    aws.String("SET #ttl = :ttl, #deleted_at = :now, #version = #version + :one"),
+		ConditionExpression:                 aws.String(condExpr),
+		ExpressionAttributeNames:            exprNames,
+		ExpressionAttributeValues:           exprValues,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+}
+
+// countedParent returns the parent whose child count an entity's create or
+// delete maintains, or nil if child counts are not tracked or the entity has
+// no parent check.
+func (s *Store) countedParent(entity Entity) *ConditionCheck {
+	if !s.config.TrackChildCount {
 		return nil
 	}
-	return err
+	if checker, ok := entity.(ParentChecker); ok {
+		return checker.ParentCheck()
+	}
+	return nil
+}
+
+// markDeleted applies an update built by softDeleteUpdate. If parent is
+// non-nil, the parent's child count is decremented in the same transaction,
+// unless the parent is missing or has no count. If job is non-nil, it is
+// written in the same transaction.
+//
+// It returns the entity's item and whether this call deleted it. The item is
+// as it was if the condition failed, and nil if the entity does not exist or
+// was deleted in a transaction.
+func (s *Store) markDeleted(ctx context.Context, update *types.Update, parent *ConditionCheck, job *types.Put) (map[string]types.AttributeValue, bool, error) {
+	if parent != nil || job != nil {
+		items := []types.TransactWriteItem{{Update: update}}
+		if parent != nil {
+			items = append(items, withChildCount(childCountCheck(parent), -1))
+		}
+		if job != nil {
+			items = append(items, types.TransactWriteItem{Put: job})
+		}
+		_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+		if err == nil {
+			return nil, true, nil
+		}
+		var txErr *types.TransactionCanceledException
+		if errors.As(err, &txErr) && len(txErr.CancellationReasons) == len(items) {
+			if reason := txErr.CancellationReasons[0]; aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return reason.Item, false, nil
+			}
+			if reason := txErr.CancellationReasons[1]; parent != nil && aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return s.markDeleted(ctx, update, nil, job)
+			}
+		}
+		return nil, false, err
+	}
+
+	result, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           update.TableName,
+		Key:                                 update.Key,
+		UpdateExpression:                    update.UpdateExpression,
+		ConditionExpression:                 update.ConditionExpression,
+		ExpressionAttributeNames:            update.ExpressionAttributeNames,
+		ExpressionAttributeValues:           update.ExpressionAttributeValues,
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return condErr.Item, false, nil
+		}
+		return nil, false, err
+	}
+	return result.Attributes, true, nil
 }
 
 // HasActiveChildren checks if an entity has any active (non-deleted) children.
@@ -573,12 +813,10 @@ func (s *Store) HasActiveChildren(ctx context.Context, entityRef string) (bool,
 
 			shardPK := fmt.Sprintf("%s#%02x", entityRef, shardNum)
 			result, err := s.client.Query(ctx, &dynamodb.QueryInput{
-				TableName:              aws.String(s.config.RelationshipTable),
-				KeyConditionExpression: aws.String("pk = :pk"),
-				FilterExpression:       aws.String(TTLFilterExpr()),
-				ExpressionAttributeNames: map[string]string{
-					"#ttl": "ttl",
-				},
+				TableName:                aws.String(s.config.RelationshipTable),
+				KeyConditionExpression:   aws.String("pk = :pk"),
+				FilterExpression:         aws.String(ActiveFilterExpr()),
+				ExpressionAttributeNames: ActiveFilterNames(),
 				ExpressionAttributeValues: map[string]types.AttributeValue{
 					":pk": &types.AttributeValueMemberS{Value: shardPK},
 					":now": &types.AttributeValueMemberN{
@@ -630,8 +868,8 @@ func (s *Store) hasActiveChildrenSingleShard(ctx context.Context, entityRef stri
 	result, err := s.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:                aws.String(s.config.RelationshipTable),
 		KeyConditionExpression:   aws.String("pk = :pk"),
-		FilterExpression:         aws.String(TTLFilterExpr()),
-		ExpressionAttributeNames: map[string]string{"#ttl": "ttl"},
+		FilterExpression:         aws.String(ActiveFilterExpr()),
+		ExpressionAttributeNames: ActiveFilterNames(),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":pk":  &types.AttributeValueMemberS{Value: shardPK},
 			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
@@ -647,91 +885,13 @@ func (s *Store) hasActiveChildrenSingleShard(ctx context.Context, entityRef stri
 // QueryAllChildren returns all children of an entity (including deleted ones).
 // This is used by cascade delete to propagate TTL to all children.
 func (s *Store) QueryAllChildren(ctx context.Context, parentRef string) ([]ChildRef, error) {
-	numShards := s.config.NumShards
-	if numShards < 1 {
-		numShards = 1
-	}
-
-	// Fast path for single shard (default)
-	if numShards == 1 {
-		return s.queryChildrenSingleShard(ctx, parentRef)
-	}
-
-	// Multi-shard fan-out
-	var mu sync.Mutex
-	var allChildren []ChildRef
-	var wg sync.WaitGroup
-	errs := make(chan error, numShards)
-
-	for shardNum := 0; shardNum < numShards; shardNum++ {
-		wg.Add(1)
-		go func(shardNum int) {
-			defer wg.Done()
-
-			shardPK := fmt.Sprintf("%s#%02x", parentRef, shardNum)
-			var shardChildren []ChildRef
-
-			paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
-				TableName:              aws.String(s.config.RelationshipTable),
-				KeyConditionExpression: aws.String("pk = :pk"),
-				ExpressionAttributeValues: map[string]types.AttributeValue{
-					":pk": &types.AttributeValueMemberS{Value: shardPK},
-				},
-			})
-
-			for paginator.HasMorePages() {
-				page, err := paginator.NextPage(ctx)
-				if err != nil {
-					errs <- fmt.Errorf("shard %02x: %w", shardNum, err)
-					return
-				}
-				for _, item := range page.Items {
-					shardChildren = append(shardChildren, s.unmarshalChildRef(item, shardPK))
-				}
-			}
-
-			mu.Lock()
-			allChildren = append(allChildren, shardChildren...)
-			mu.Unlock()
-		}(shardNum)
-	}
-
-	go func() {
-		wg.Wait()
-		close(errs)
-	}()
-
-	for err := range errs {
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return allChildren, nil
-}
-
-func (s *Store) queryChildrenSingleShard(ctx context.Context, parentRef string) ([]ChildRef, error) {
 	var children []ChildRef
-	shardPK := fmt.Sprintf("%s#00", parentRef)
-
-	paginator := dynamodb.NewQueryPaginator(s.client, &dynamodb.QueryInput{
-		TableName:              aws.String(s.config.RelationshipTable),
-		KeyConditionExpression: aws.String("pk = :pk"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk": &types.AttributeValueMemberS{Value: shardPK},
-		},
-	})
-
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+	for child, err := range s.QueryAllChildrenIter(ctx, parentRef) {
 		if err != nil {
 			return nil, err
 		}
-		for _, item := range page.Items {
-			children = append(children, s.unmarshalChildRef(item, shardPK))
-		}
+		children = append(children, child)
 	}
-
 	return children, nil
 }
 
@@ -741,16 +901,20 @@ func (s *Store) SetTTLByKey(ctx context.Context, table string, key PK, ttl int64
 	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName:           aws.String(table),
 		Key:                 key,
-		UpdateExpression:    aws.String("SET #ttl = :ttl, #version = #version + :one"),
+		UpdateExpression:    aws.String("SET #ttl = :ttl, #deleted_at = :now, #version = #version + :one"),
 		ConditionExpression: aws.String("attribute_not_exists(#ttl)"),
 		ExpressionAttributeNames: map[string]string{
-			"#ttl":     "ttl",
-			"#version": "version",
+			"#ttl":        "ttl",
+			"#deleted_at": "deleted_at",
+			"#version":    "version",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":ttl": &types.AttributeValueMemberN{
 				Value: strconv.FormatInt(ttl, 10),
 			},
+			":now": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(time.Now().Unix(), 10),
+			},
 			":one": &types.AttributeValueMemberN{Value: "1"},
 		},
 	})
@@ -765,6 +929,13 @@ func (s *Store) SetTTLByKey(ctx context.Context, table string, key PK, ttl int64
 
 // SetRelationshipTTL sets TTL on a relationship record.
 func (s *Store) SetRelationshipTTL(ctx context.Context, childRef, parentRef string, ttl int64) error {
+	_, err := s.setRelationshipTTL(ctx, childRef, parentRef, ttl)
+	return err
+}
+
+// setRelationshipTTL sets TTL on a relationship record and reports whether
+// it was changed (false if it already had a TTL).
+func (s *Store) setRelationshipTTL(ctx context.Context, childRef, parentRef string, ttl int64) (bool, error) {
 	shardPK := s.relationshipPK(parentRef, childRef)
 
 	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
@@ -773,58 +944,73 @@ func (s *Store) SetRelationshipTTL(ctx context.Context, childRef, parentRef stri
 			"pk":        &types.AttributeValueMemberS{Value: shardPK},
 			"child_ref": &types.AttributeValueMemberS{Value: childRef},
 		},
-		UpdateExpression:    aws.String("SET #ttl = :ttl"),
+		UpdateExpression:    aws.String("SET #ttl = :ttl, #deleted_at = :now"),
 		ConditionExpression: aws.String("attribute_not_exists(#ttl)"),
 		ExpressionAttributeNames: map[string]string{
-			"#ttl": "ttl",
+			"#ttl":        "ttl",
+			"#deleted_at": "deleted_at",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":ttl": &types.AttributeValueMemberN{
 				Value: strconv.FormatInt(ttl, 10),
 			},
+			":now": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(time.Now().Unix(), 10),
+			},
 		},
 	})
 
 	// Ignore condition failure - already has TTL
 	var condErr *types.ConditionalCheckFailedException
 	if errors.As(err, &condErr) {
-		return nil
+		return false, nil
 	}
-	return err
+	return err == nil, err
 }
 
 // SetUniqueConstraintTTL sets TTL on a unique constraint record.
 func (s *Store) SetUniqueConstraintTTL(ctx context.Context, pk string, ttl int64) error {
+	_, err := s.setUniqueConstraintTTL(ctx, pk, ttl)
+	return err
+}
+
+// setUniqueConstraintTTL sets TTL on a unique constraint record and reports
+// whether it was changed (false if it already had a TTL).
+func (s *Store) setUniqueConstraintTTL(ctx context.Context, pk string, ttl int64) (bool, error) {
 	_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(s.config.UniqueTable),
 		Key: map[string]types.AttributeValue{
 			"pk": &types.AttributeValueMemberS{Value: pk},
 			"sk": &types.AttributeValueMemberS{Value: "CONSTRAINT"},
 		},
-		UpdateExpression:    aws.String("SET #ttl = :ttl"),
+		UpdateExpression:    aws.String("SET #ttl = :ttl, #deleted_at = :now"),
 		ConditionExpression: aws.String("attribute_not_exists(#ttl)"),
 		ExpressionAttributeNames: map[string]string{
-			"#ttl": "ttl",
+			"#ttl":        "ttl",
+			"#deleted_at": "deleted_at",
 		},
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":ttl": &types.AttributeValueMemberN{
 				Value: strconv.FormatInt(ttl, 10),
 			},
+			":now": &types.AttributeValueMemberN{
+				Value: strconv.FormatInt(time.Now().Unix(), 10),
+			},
 		},
 	})
 
 	// Ignore condition failure - already has TTL
 	var condErr *types.ConditionalCheckFailedException
 	if errors.As(err, &condErr) {
-		return nil
+		return false, nil
 	}
-	return err
+	return err == nil, err
 }
 
-// mapCreateTransactionError maps DynamoDB transaction errors for Create operations.
-// parentCheckIndex is the index of the parent check item (-1 if none).
-// entityPutIndex is the index of the entity put item.
-func (s *Store) mapCreateTransactionError(err error, parentCheckIndex, entityPutIndex int) error {
+// mapTransactionError maps a cancelled transaction to a ConditionFailedError
+// for the first item whose condition failed. failures holds the error each
+// item's condition maps to, aligned with items.
+func mapTransactionError(err error, items []types.TransactWriteItem, failures []error) error {
 	if err == nil {
 		return nil
 	}
@@ -832,15 +1018,11 @@ func (s *Store) mapCreateTransactionError(err error, parentCheckIndex, entityPut
 	var txErr *types.TransactionCanceledException
 	if errors.As(err, &txErr) {
 		for i, reason := range txErr.CancellationReasons {
-			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
-				if i == parentCheckIndex {
-					return ErrParentNotFound
-				}
-				if i == entityPutIndex {
-					return ErrAlreadyExists
-				}
-				// Must be a unique constraint
-				return ErrDuplicateValue
+			if reason.Code == nil || *reason.Code != "ConditionalCheckFailed" {
+				continue
+			}
+			if i < len(failures) && failures[i] != nil {
+				return newConditionFailedError(i, items[i], failures[i])
 			}
 		}
 	}
@@ -848,23 +1030,20 @@ func (s *Store) mapCreateTransactionError(err error, parentCheckIndex, entityPut
 	return err
 }
 
-// mapUpdateTransactionError maps DynamoDB transaction errors for Update operations.
-func (s *Store) mapUpdateTransactionError(err error) error {
-	if err == nil {
-		return nil
-	}
-
-	var txErr *types.TransactionCanceledException
-	if errors.As(err, &txErr) {
-		for _, reason := range txErr.CancellationReasons {
-			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
-				// For updates, this is always a unique constraint violation
-				return ErrDuplicateValue
-			}
-		}
-	}
-
-	return err
+// newConditionFailedError describes the failed transaction item at index.
+func newConditionFailedError(index int, item types.TransactWriteItem, failure error) *ConditionFailedError {
+	e := &ConditionFailedError{Index: index, Err: failure}
+	switch {
+	case item.ConditionCheck != nil:
+		e.Op, e.TableName = "ConditionCheck", aws.ToString(item.ConditionCheck.TableName)
+	case item.Put != nil:
+		e.Op, e.TableName = "Put", aws.ToString(item.Put.TableName)
+	case item.Update != nil:
+		e.Op, e.TableName = "Update", aws.ToString(item.Update.TableName)
+	case item.Delete != nil:
+		e.Op, e.TableName = "Delete", aws.ToString(item.Delete.TableName)
+	}
+	return e
 }
 
 // unmarshalItem converts a DynamoDB item to an Item struct.
@@ -889,6 +1068,21 @@ func (s *Store) unmarshalItem(raw map[string]types.AttributeValue) *Item {
 	if v, ok := raw["parent_ref"].(*types.AttributeValueMemberS); ok {
 		item.ParentRef = v.Value
 	}
+	if v, ok := raw["deleted_at"].(*types.AttributeValueMemberN); ok {
+		parsed, err := strconv.ParseInt(v.Value, 10, 64)
+		if err == nil {
+			item.DeletedAt = parsed
+		}
+	}
+	if ttl, ok := itemTTL(raw); ok {
+		item.TTL = ttl
+	}
+	if count, ok := itemChildCount(raw); ok {
+		item.ChildCount = count
+	}
+	if path, ok := itemPath(raw); ok {
+		item.Path = path
+	}
 
 	return item
 }
@@ -909,15 +1103,3 @@ func (s *Store) unmarshalChildRef(item map[string]types.AttributeValue, shardPK
 
 	return ref
 }
-
-// joinStrings joins strings with a separator (avoiding strings package import).
-func joinStrings(strs []string, sep string) string {
-	if len(strs) == 0 {
-		return ""
-	}
-	result := strs[0]
-	for _, s := range strs[1:] {
-		result += sep + s
-	}
-	return result
-}
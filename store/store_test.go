@@ -106,6 +106,9 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.UniqueTable != "trellis_unique_constraints" {
 		t.Errorf("expected UniqueTable 'trellis_unique_constraints', got %q", cfg.UniqueTable)
 	}
+	if cfg.CascadeJobTable != "trellis_cascade_jobs" {
+		t.Errorf("expected CascadeJobTable 'trellis_cascade_jobs', got %q", cfg.CascadeJobTable)
+	}
 	if cfg.NumShards != 1 {
 		t.Errorf("expected NumShards 1, got %d", cfg.NumShards)
 	}
@@ -392,6 +395,13 @@ func TestErrors(t *testing.T) {
 		store.ErrConcurrentModification,
 		store.ErrDuplicateValue,
 		store.ErrAlreadyDeleted,
+		store.ErrNotDeleted,
+		store.ErrInvalidCursor,
+		store.ErrCascadeLimit,
+		store.ErrUnprocessedKeys,
+		store.ErrInvalidTransaction,
+		store.ErrInvalidMove,
+		store.ErrUnknownEntityType,
 	}
 
 	for _, err := range errors {
@@ -501,6 +511,9 @@ func TestTTLFilterExpr(t *testing.T) {
 	if !contains(expr, "#ttl") {
 		t.Error("expected TTL filter to reference #ttl")
 	}
+	if contains(expr, "#deleted_at") {
+		t.Error("expected TTL filter not to reference #deleted_at")
+	}
 }
 
 func TestTTLFilterNames(t *testing.T) {
@@ -508,6 +521,29 @@ func TestTTLFilterNames(t *testing.T) {
 	if names["#ttl"] != "ttl" {
 		t.Errorf("expected #ttl -> ttl, got %q", names["#ttl"])
 	}
+	if len(names) != 1 {
+		t.Errorf("expected only #ttl, got %v", names)
+	}
+}
+
+func TestActiveFilterExpr(t *testing.T) {
+	expr := store.ActiveFilterExpr()
+	if !contains(expr, "attribute_not_exists(#deleted_at)") {
+		t.Error("expected active filter to exclude items with #deleted_at")
+	}
+	if !contains(expr, store.TTLFilterExpr()) {
+		t.Error("expected active filter to include the TTL filter")
+	}
+}
+
+func TestActiveFilterNames(t *testing.T) {
+	names := store.ActiveFilterNames()
+	if names["#ttl"] != "ttl" {
+		t.Errorf("expected #ttl -> ttl, got %q", names["#ttl"])
+	}
+	if names["#deleted_at"] != "deleted_at" {
+		t.Errorf("expected #deleted_at -> deleted_at, got %q", names["#deleted_at"])
+	}
 }
 
 func TestTTLFilterValues(t *testing.T) {
@@ -529,6 +565,22 @@ func TestParentExistsCondition(t *testing.T) {
 	if !contains(cond, "#ttl") {
 		t.Error("expected condition to reference #ttl")
 	}
+	if contains(cond, "#deleted_at") {
+		t.Error("expected condition not to reference #deleted_at")
+	}
+}
+
+func TestActiveParentCondition(t *testing.T) {
+	cond := store.ActiveParentCondition()
+	if !contains(cond, "attribute_exists(id)") {
+		t.Error("expected condition to check attribute_exists")
+	}
+	if !contains(cond, "attribute_not_exists(#deleted_at)") {
+		t.Error("expected condition to exclude parents with #deleted_at")
+	}
+	if !contains(cond, "#ttl > :now") {
+		t.Error("expected condition to check #ttl")
+	}
 }
 
 func contains(s, substr string) bool {
@@ -682,6 +734,9 @@ func TestErrors_Uniqueness(t *testing.T) {
 		store.ErrConcurrentModification,
 		store.ErrDuplicateValue,
 		store.ErrAlreadyDeleted,
+		store.ErrNotDeleted,
+		store.ErrInvalidMove,
+		store.ErrUnknownEntityType,
 	}
 
 	seen := make(map[string]error)
@@ -729,6 +784,7 @@ func TestErrors_NotNil(t *testing.T) {
 		store.ErrConcurrentModification,
 		store.ErrDuplicateValue,
 		store.ErrAlreadyDeleted,
+		store.ErrNotDeleted,
 	}
 
 	for _, err := range errors {
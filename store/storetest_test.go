@@ -0,0 +1,328 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+	"github.com/jacentio/trellis/storetest"
+)
+
+var _ store.DynamoDBClient = (*storetest.DB)(nil)
+
+// newMemStore returns a Store backed by an in-memory DynamoDB with the
+// tables used by the test entities.
+func newMemStore(t *testing.T, cfg store.Config) (*store.Store, *storetest.DB) {
+	t.Helper()
+	db := storetest.New()
+	for _, name := range []string{"parents", "children", "unique_children", "widgets", "nodes"} {
+		db.MustCreateTable(storetest.EntityTable(name))
+	}
+	db.MustCreateTable(storetest.RelationshipTable(cfg.RelationshipTable))
+	db.MustCreateTable(storetest.UniqueTable(cfg.UniqueTable))
+	db.MustCreateTable(storetest.CascadeJobTable(cfg.CascadeJobTable))
+	return store.New(db, cfg), db
+}
+
+func TestMemStore_CreateAndGet(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	parent := Parent{ID: "p1"}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	child := Child{ID: "c1", ParentID: "p1"}
+	if err := s.Create(ctx, child, makeTestItem("c1", "Child")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+
+	item, err := s.Get(ctx, "children", child.GetKey())
+	if err != nil {
+		t.Fatalf("get child: %v", err)
+	}
+	if item.Version != 1 || item.ParentRef != "parent#p1" {
+		t.Errorf("expected version 1 and parent ref, got %d and %q", item.Version, item.ParentRef)
+	}
+	if rels := db.Items(store.DefaultConfig().RelationshipTable); len(rels) != 1 {
+		t.Errorf("expected 1 relationship record, got %d", len(rels))
+	}
+
+	children, err := s.QueryAllChildren(ctx, "parent#p1")
+	if err != nil || len(children) != 1 {
+		t.Fatalf("expected 1 child, got %d (err %v)", len(children), err)
+	}
+	if v, ok := children[0].Key["id"].(*types.AttributeValueMemberS); !ok || v.Value != "c1" {
+		t.Errorf("expected child key id=c1, got %v", children[0].Key)
+	}
+}
+
+func TestMemStore_CreateErrors(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	err := s.Create(ctx, Child{ID: "c1", ParentID: "missing"}, makeTestItem("c1", "Child"))
+	if !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	err = s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent"))
+	if !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+
+	first := UniqueChild{ID: "u1", ParentID: "p1", Name: "same", Slug: "one"}
+	if err := s.Create(ctx, first, makeTestItem("u1", "same")); err != nil {
+		t.Fatalf("create unique child: %v", err)
+	}
+	second := UniqueChild{ID: "u2", ParentID: "p1", Name: "same", Slug: "two"}
+	err = s.Create(ctx, second, makeTestItem("u2", "same"))
+	if !errors.Is(err, store.ErrDuplicateValue) {
+		t.Errorf("expected ErrDuplicateValue, got %v", err)
+	}
+}
+
+func TestMemStore_DeletedParentRejectsChildren(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	parent := Parent{ID: "p1"}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	if err := s.Delete(ctx, parent, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete parent: %v", err)
+	}
+
+	err := s.Create(ctx, Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "Child"))
+	if !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+	if _, err := s.Get(ctx, "parents", parent.GetKey()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMemStore_UpdateVersionConflict(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	parent := Parent{ID: "p1"}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	update := map[string]types.AttributeValue{"name": &types.AttributeValueMemberS{Value: "Renamed"}}
+	if err := s.Update(ctx, parent, update, 1); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := s.Update(ctx, parent, update, 1); !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification, got %v", err)
+	}
+}
+
+func TestMemStore_DeleteVersionConflict(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	parent := Parent{ID: "p1"}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	update := map[string]types.AttributeValue{"name": &types.AttributeValueMemberS{Value: "Renamed"}}
+	if err := s.Update(ctx, parent, update, 1); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	err := s.Delete(ctx, parent, store.DeleteOptions{ExpectedVersion: 1})
+	if !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification, got %v", err)
+	}
+	if store.IsDeleted(db.Item("parents", parent.GetKey())) {
+		t.Fatal("expected parent to remain active after version conflict")
+	}
+
+	if err := s.Delete(ctx, parent, store.DeleteOptions{ExpectedVersion: 2}); err != nil {
+		t.Fatalf("delete at current version: %v", err)
+	}
+	if !store.IsDeleted(db.Item("parents", parent.GetKey())) {
+		t.Error("expected parent to be deleted")
+	}
+}
+
+func TestMemStore_DeleteStrict(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	strict := store.DeleteOptions{Strict: true}
+
+	parent := Parent{ID: "p1"}
+	if err := s.Delete(ctx, parent, strict); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(ctx, parent, store.DeleteOptions{Strict: true, Sync: true}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from sync delete, got %v", err)
+	}
+	if err := s.Delete(ctx, parent, store.DeleteOptions{Sync: true}); err != nil {
+		t.Errorf("expected non-strict sync delete of a missing entity to succeed, got %v", err)
+	}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	if err := s.Delete(ctx, parent, strict); err != nil {
+		t.Fatalf("strict delete: %v", err)
+	}
+	if err := s.Delete(ctx, parent, strict); !errors.Is(err, store.ErrAlreadyDeleted) {
+		t.Errorf("expected ErrAlreadyDeleted, got %v", err)
+	}
+	if err := s.Delete(ctx, parent, store.DeleteOptions{Strict: true, Sync: true}); !errors.Is(err, store.ErrAlreadyDeleted) {
+		t.Errorf("expected ErrAlreadyDeleted from sync delete, got %v", err)
+	}
+
+	// Without Strict, repeated deletes succeed
+	if err := s.Delete(ctx, parent, store.DeleteOptions{}); err != nil {
+		t.Errorf("non-strict delete: %v", err)
+	}
+}
+
+func TestMemStore_StructuredErrors(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	first := UniqueChild{ID: "u1", ParentID: "p1", Name: "same", Slug: "one"}
+	if err := s.Create(ctx, first, makeTestItem("u1", "same")); err != nil {
+		t.Fatalf("create unique child: %v", err)
+	}
+
+	err := s.Create(ctx, UniqueChild{ID: "u2", ParentID: "p1", Name: "other", Slug: "one"}, makeTestItem("u2", "other"))
+	var dupErr *store.DuplicateValueError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("expected DuplicateValueError, got %v", err)
+	}
+	if dupErr.EntityType != "unique_child" || dupErr.Field != "slug" || dupErr.Value != "one" {
+		t.Errorf("unexpected DuplicateValueError %+v", dupErr)
+	}
+	var condErr *store.ConditionFailedError
+	if !errors.As(err, &condErr) || condErr.Op != "Put" {
+		t.Errorf("expected ConditionFailedError for a Put, got %v", err)
+	}
+
+	// A stale version on an update that changes unique fields is not a duplicate
+	first.Slug = "changed"
+	update := map[string]types.AttributeValue{"slug": &types.AttributeValueMemberS{Value: "changed"}}
+	err = s.Update(ctx, first, update, 7)
+	if !errors.Is(err, store.ErrConcurrentModification) || errors.Is(err, store.ErrDuplicateValue) {
+		t.Errorf("expected ErrConcurrentModification, got %v", err)
+	}
+}
+
+func TestMemStore_QueryAllChildrenAcrossShards(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 8
+	s, _ := newMemStore(t, cfg)
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, id)); err != nil {
+			t.Fatalf("create child %s: %v", id, err)
+		}
+	}
+
+	children, err := s.QueryAllChildren(ctx, "parent#p1")
+	if err != nil {
+		t.Fatalf("query children: %v", err)
+	}
+	if len(children) != 5 {
+		t.Errorf("expected 5 children, got %d", len(children))
+	}
+	has, err := s.HasActiveChildren(ctx, "parent#p1")
+	if err != nil || !has {
+		t.Errorf("expected active children, got %v (err %v)", has, err)
+	}
+}
+
+func TestMemStore_QueryPage(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, id)); err != nil {
+			t.Fatalf("create child %s: %v", id, err)
+		}
+	}
+	if err := s.SetRelationshipTTL(ctx, "child#b", "parent#p1", 1000); err != nil {
+		t.Fatalf("set relationship ttl: %v", err)
+	}
+
+	input := &store.QueryInput{
+		TableName:              store.DefaultConfig().RelationshipTable,
+		KeyConditionExpression: "pk = :pk",
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "parent#p1#00"},
+		},
+		Limit: 2,
+	}
+
+	var refs []string
+	var pages int
+	cursor := ""
+	for {
+		page, err := s.QueryPage(ctx, input, cursor)
+		if err != nil {
+			t.Fatalf("query page: %v", err)
+		}
+		pages++
+		for _, item := range page.Items {
+			refs = append(refs, item.Raw["child_ref"].(*types.AttributeValueMemberS).Value)
+		}
+		if page.Cursor == "" {
+			break
+		}
+		cursor = page.Cursor
+	}
+
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+	if len(refs) != 4 {
+		t.Errorf("expected 4 active children, got %v", refs)
+	}
+	for _, ref := range refs {
+		if ref == "child#b" {
+			t.Error("expected deleted child to be filtered")
+		}
+	}
+
+	if _, err := s.QueryPage(ctx, input, "garbage"); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+
+	// A cursor does not resume a query of another partition or table
+	first, err := s.QueryPage(ctx, input, "")
+	if err != nil || first.Cursor == "" {
+		t.Fatalf("expected a cursor, got %v (err %v)", first, err)
+	}
+	other := *input
+	other.ExpressionAttributeValues = map[string]types.AttributeValue{
+		":pk": &types.AttributeValueMemberS{Value: "parent#p2#00"},
+	}
+	if _, err := s.QueryPage(ctx, &other, first.Cursor); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for another partition, got %v", err)
+	}
+	if _, err := s.ListTrashPage(ctx, &store.ScanInput{TableName: input.TableName}, first.Cursor); !errors.Is(err, store.ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor for a scan, got %v", err)
+	}
+}
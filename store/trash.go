@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"iter"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ListTrash returns an iterator over the deleted entities in
+// input.TableName that DynamoDB has not purged yet, such as those deleted
+// with DeleteOptions.Retention. Items carry DeletedAt and TTL so callers can
+// show when they will be purged. input.FilterExpression further narrows the
+// items, and input.TotalSegments scans in parallel, as with ScanIter.
+func (s *Store) ListTrash(ctx context.Context, input *ScanInput) iter.Seq2[*Item, error] {
+	return s.scanIter(ctx, input, trashFilterExpr)
+}
+
+// ListTrashPage reads a single page of ListTrash. Pass an empty cursor for
+// the first page and Page.Cursor for subsequent pages; an empty Page.Cursor
+// means there are no more pages. input.TotalSegments is ignored.
+//
+// input.Limit bounds the items DynamoDB evaluates before filtering, so a
+// page may contain fewer items (even none) while a cursor is still returned.
+func (s *Store) ListTrashPage(ctx context.Context, input *ScanInput, cursor string) (*Page, error) {
+	scope := cursorScope{table: input.TableName, partition: input.IndexName}
+	startKey, err := decodeCursor(scope, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	scanInput := buildScanInput(input, trashFilterExpr)
+	scanInput.ExclusiveStartKey = startKey
+
+	result, err := s.client.Scan(ctx, scanInput)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{Items: make([]*Item, 0, len(result.Items))}
+	for _, raw := range result.Items {
+		page.Items = append(page.Items, s.unmarshalItem(raw))
+	}
+	if page.Cursor, err = encodeCursor(scope, result.LastEvaluatedKey); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// ListChildTrash reads a page of the deleted children of a parent that
+// DynamoDB has not purged yet, in ListChildren order. opts selects and pages
+// through the children as with ListChildren; IncludeDeleted is implied.
+// Active children count towards opts.Limit, so pages may be short.
+func (s *Store) ListChildTrash(ctx context.Context, parentRef string, opts ListChildrenOptions) (*Page, error) {
+	opts.IncludeDeleted = true
+	children, err := s.ListChildren(ctx, parentRef, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]TableKey, len(children.Children))
+	positions := make(map[string]int, len(keys))
+	for i, child := range children.Children {
+		keys[i] = TableKey{TableName: child.TableName, Key: child.Key}
+		positions[tableKeyString(child.TableName, child.Key)] = i
+	}
+
+	found := make([]*Item, len(keys))
+	for start := 0; start < len(keys); start += maxBatchGetKeys {
+		end := min(start+maxBatchGetKeys, len(keys))
+		err := s.batchGetChunk(ctx, keys[start:end], func(table string, raw map[string]types.AttributeValue, keyNames []string) {
+			if !IsDeleted(raw) {
+				return
+			}
+			key := make(PK, len(keyNames))
+			for _, name := range keyNames {
+				key[name] = raw[name]
+			}
+			found[positions[tableKeyString(table, key)]] = s.unmarshalItem(raw)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page := &Page{Items: make([]*Item, 0, len(found)), Cursor: children.Cursor}
+	for _, item := range found {
+		if item != nil {
+			page.Items = append(page.Items, item)
+		}
+	}
+	return page, nil
+}
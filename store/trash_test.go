@@ -0,0 +1,141 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jacentio/trellis/store"
+)
+
+func TestDelete_RetentionHidesButKeeps(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	parent := Parent{ID: "p1"}
+	if err := s.Create(ctx, parent, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	for _, id := range []string{"c1", "c2"} {
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, id)); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+
+	if err := s.Delete(ctx, Child{ID: "c1", ParentID: "p1"}, store.DeleteOptions{Retention: time.Hour}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "children", Child{ID: "c1"}.GetKey()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	items, err := s.Scan(ctx, &store.ScanInput{TableName: "children"})
+	if err != nil || len(items) != 1 {
+		t.Errorf("expected 1 active child from scan, got %d (err %v)", len(items), err)
+	}
+
+	// Not purged until the retention period has passed
+	if n := db.SweepTTL(time.Now()); n != 0 {
+		t.Errorf("expected nothing purged yet, got %d", n)
+	}
+	if n := db.SweepTTL(time.Now().Add(2 * time.Hour)); n != 1 {
+		t.Errorf("expected 1 item purged after retention, got %d", n)
+	}
+
+	// A parent in the trash cannot get new children
+	if err := s.Delete(ctx, parent, store.DeleteOptions{Retention: time.Hour}); err != nil {
+		t.Fatalf("delete parent: %v", err)
+	}
+	err = s.Create(ctx, Child{ID: "c3", ParentID: "p1"}, makeTestItem("c3", "c3"))
+	if !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+}
+
+func TestListTrash(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+	for _, id := range []string{"c1", "c2", "c3"} {
+		if err := s.Create(ctx, Child{ID: id, ParentID: "p1"}, makeTestItem(id, id)); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	before := time.Now().Unix()
+	if err := s.Delete(ctx, Child{ID: "c1", ParentID: "p1"}, store.DeleteOptions{Retention: 24 * time.Hour}); err != nil {
+		t.Fatalf("delete c1: %v", err)
+	}
+	if err := s.Delete(ctx, Child{ID: "c3", ParentID: "p1"}, store.DeleteOptions{}); err != nil {
+		t.Fatalf("delete c3: %v", err)
+	}
+
+	if trash := listTrash(t, s, "children"); len(trash) != 2 {
+		t.Fatalf("expected 2 items in trash, got %d", len(trash))
+	}
+
+	// Pages carry a cursor until the table is exhausted
+	var paged []*store.Item
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("too many trash pages")
+		}
+		page, err := s.ListTrashPage(ctx, &store.ScanInput{TableName: "children", Limit: 1}, cursor)
+		if err != nil {
+			t.Fatalf("list trash page: %v", err)
+		}
+		paged = append(paged, page.Items...)
+		if cursor = page.Cursor; cursor == "" {
+			break
+		}
+	}
+	if len(paged) != 2 {
+		t.Errorf("expected 2 items in trash pages, got %d", len(paged))
+	}
+
+	page, err := s.ListChildTrash(ctx, "parent#p1", store.ListChildrenOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("list child trash: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].EntityRef != "child#c1" || page.Cursor == "" {
+		t.Fatalf("expected c1 and a cursor in the first child trash page, got %v", page)
+	}
+	c1 := page.Items[0]
+	if c1.DeletedAt < before || c1.TTL < before+24*3600 || c1.Version != 2 {
+		t.Errorf("unexpected trash metadata: deleted_at %d, ttl %d, version %d", c1.DeletedAt, c1.TTL, c1.Version)
+	}
+	page, err = s.ListChildTrash(ctx, "parent#p1", store.ListChildrenOptions{Limit: 2, Cursor: page.Cursor})
+	if err != nil {
+		t.Fatalf("list child trash: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].EntityRef != "child#c3" {
+		t.Fatalf("expected c3 in the second child trash page, got %v", page)
+	}
+
+	// Restoring takes an item out of the trash
+	if err := s.Restore(ctx, Child{ID: "c1", ParentID: "p1"}, c1.Version, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if trash := listTrash(t, s, "children"); len(trash) != 1 {
+		t.Errorf("expected 1 item in trash after restore, got %d", len(trash))
+	}
+	if _, err := s.Get(ctx, "children", Child{ID: "c1"}.GetKey()); err != nil {
+		t.Errorf("expected restored child, got %v", err)
+	}
+}
+
+// listTrash collects the trash of a table.
+func listTrash(t *testing.T, s *store.Store, table string) []*store.Item {
+	t.Helper()
+	var items []*store.Item
+	for item, err := range s.ListTrash(context.Background(), &store.ScanInput{TableName: table}) {
+		if err != nil {
+			t.Fatalf("list trash: %v", err)
+		}
+		items = append(items, item)
+	}
+	return items
+}
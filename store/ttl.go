@@ -1,27 +1,37 @@
 package store
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-// IsDeleted checks if an item has an expired TTL (is marked for deletion).
+// IsDeleted checks if an item is marked as deleted: it has a deleted_at
+// marker (set when deleting with a retention period) or an expired TTL.
 func IsDeleted(item map[string]types.AttributeValue) bool {
-	ttlAttr, exists := item["ttl"]
-	if !exists {
+	if _, ok := item["deleted_at"]; ok {
+		return true
+	}
+	ttl, ok := itemTTL(item)
+	if !ok {
 		return false // No TTL = active
 	}
-	ttlNum, ok := ttlAttr.(*types.AttributeValueMemberN)
+	return ttl <= time.Now().Unix()
+}
+
+// itemTTL returns an item's TTL and whether it has a valid one.
+func itemTTL(item map[string]types.AttributeValue) (int64, bool) {
+	ttlNum, ok := item["ttl"].(*types.AttributeValueMemberN)
 	if !ok {
-		return false
+		return 0, false
 	}
 	ttl, err := strconv.ParseInt(ttlNum.Value, 10, 64)
 	if err != nil {
-		return false
+		return 0, false
 	}
-	return ttl <= time.Now().Unix()
+	return ttl, true
 }
 
 // TTLFilterExpr returns the filter expression to exclude deleted items.
@@ -36,8 +46,25 @@ func TTLFilterNames() map[string]string {
 	return map[string]string{"#ttl": "ttl"}
 }
 
+// ActiveFilterExpr returns the filter expression to exclude deleted items,
+// including those deleted with a retention period, whose TTL is still in the
+// future. Use it instead of TTLFilterExpr to hide them from custom queries.
+func ActiveFilterExpr() string {
+	return "attribute_not_exists(#deleted_at) AND (" + TTLFilterExpr() + ")"
+}
+
+// ActiveFilterNames returns expression attribute names for the active
+// filter. Use with ActiveFilterExpr() or ActiveParentCondition().
+func ActiveFilterNames() map[string]string {
+	return map[string]string{"#ttl": "ttl", "#deleted_at": "deleted_at"}
+}
+
+// trashFilterExpr selects deleted items that have not been purged yet.
+// It uses the same placeholders as ActiveFilterExpr.
+const trashFilterExpr = "attribute_exists(#deleted_at) OR #ttl <= :now"
+
 // TTLFilterValues returns expression attribute values for TTL filter.
-// Use with TTLFilterExpr() when building custom queries.
+// Use with TTLFilterExpr() or ActiveFilterExpr() when building custom queries.
 func TTLFilterValues() map[string]types.AttributeValue {
 	return map[string]types.AttributeValue{
 		":now": &types.AttributeValueMemberN{
@@ -46,8 +73,45 @@ func TTLFilterValues() map[string]types.AttributeValue {
 	}
 }
 
+// mergeTTLFilter combines a caller-provided filter and its placeholders
+// with the active filter.
+func mergeTTLFilter(filter string, names map[string]string, values map[string]types.AttributeValue) (string, map[string]string, map[string]types.AttributeValue) {
+	return mergeFilter(ActiveFilterExpr(), filter, names, values)
+}
+
+// mergeFilter combines a caller-provided filter and its placeholders with
+// base, which uses the placeholders of ActiveFilterExpr.
+func mergeFilter(base, filter string, names map[string]string, values map[string]types.AttributeValue) (string, map[string]string, map[string]types.AttributeValue) {
+	// Merge base filter with any existing filter
+	filterExpr := base
+	if filter != "" {
+		filterExpr = fmt.Sprintf("(%s) AND (%s)", filter, filterExpr)
+	}
+
+	// Merge expression attribute names
+	exprNames := ActiveFilterNames()
+	for k, v := range names {
+		exprNames[k] = v
+	}
+
+	// Merge expression attribute values
+	exprValues := TTLFilterValues()
+	for k, v := range values {
+		exprValues[k] = v
+	}
+
+	return filterExpr, exprNames, exprValues
+}
+
 // ParentExistsCondition returns the condition expression for parent validation.
 // Ensures parent exists AND is not deleted (no TTL or TTL in future).
 func ParentExistsCondition() string {
 	return "attribute_exists(id) AND (attribute_not_exists(#ttl) OR #ttl > :now)"
 }
+
+// ActiveParentCondition returns the condition expression for parent
+// validation that also rejects parents deleted with a retention period.
+// It is the default for ConditionCheck.
+func ActiveParentCondition() string {
+	return "attribute_exists(id) AND " + ActiveFilterExpr()
+}
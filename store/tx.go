@@ -0,0 +1,388 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Tx operation names reported in TxError.
+const (
+	TxOpCreate = "create"
+	TxOpUpdate = "update"
+	TxOpDelete = "delete"
+)
+
+// TxError reports which staged operation caused a transaction to fail.
+type TxError struct {
+	// Index is the position of the operation in staging order.
+	Index int
+
+	// Op is TxOpCreate, TxOpUpdate or TxOpDelete.
+	Op string
+
+	// EntityRef is the entity the operation targeted.
+	EntityRef string
+
+	// Err is the underlying error, e.g. ErrParentNotFound.
+	Err error
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("trellis: tx %s %s (operation %d): %v", e.Op, e.EntityRef, e.Index, e.Err)
+}
+
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// Tx stages creates, updates and deletes across entities and commits them
+// in a single DynamoDB transaction. A Tx is not safe for concurrent use.
+type Tx struct {
+	store *Store
+	ops   []txOp
+}
+
+type txOp struct {
+	op              string
+	entity          Entity
+	item            map[string]types.AttributeValue
+	expectedVersion int64
+	deleteOpts      DeleteOptions
+}
+
+// Tx starts a new unit of work. Nothing is written until Commit.
+func (s *Store) Tx() *Tx {
+	return &Tx{store: s}
+}
+
+// Create stages an entity creation. See Store.Create.
+func (tx *Tx) Create(entity Entity, item map[string]types.AttributeValue) *Tx {
+	tx.ops = append(tx.ops, txOp{op: TxOpCreate, entity: entity, item: item})
+	return tx
+}
+
+// Update stages a version-checked update. See Store.Update.
+func (tx *Tx) Update(entity Entity, item map[string]types.AttributeValue, expectedVersion int64) *Tx {
+	tx.ops = append(tx.ops, txOp{op: TxOpUpdate, entity: entity, item: item, expectedVersion: expectedVersion})
+	return tx
+}
+
+// Delete stages a soft delete. Unlike Store.Delete, deleting a missing or
+// already-deleted entity fails the transaction with ErrNotFound or
+// ErrAlreadyDeleted, and a version mismatch with opts.ExpectedVersion fails
+// it with ErrConcurrentModification. OrphanProtect is checked before
+// committing.
+//
+// A cascade is always left to the stream handler: Commit rejects
+// opts.Sync, MaxDepth and MaxItems with ErrInvalidTransaction, and records
+// no cascade job even with Config.TrackCascadeJobs.
+func (tx *Tx) Delete(entity Entity, opts DeleteOptions) *Tx {
+	tx.ops = append(tx.ops, txOp{op: TxOpDelete, entity: entity, deleteOpts: opts})
+	return tx
+}
+
+// Len returns the number of staged operations.
+func (tx *Tx) Len() int {
+	return len(tx.ops)
+}
+
+// txItemInfo describes one transaction item: the staged operations that
+// depend on it and the error a failed condition maps to.
+type txItemInfo struct {
+	owners []int
+	kind   error
+}
+
+// Commit writes all staged operations atomically.
+//
+// Parent checks are merged: children of the same parent share one check,
+// and no check is made for a parent that is created or updated in the same
+// transaction. With Config.TrackChildCount, each parent's child count is
+// changed once by the net number of children created and deleted. If the
+// transaction is cancelled, Commit returns a *TxError for the first staged
+// operation whose condition failed.
+func (tx *Tx) Commit(ctx context.Context) error {
+	s := tx.store
+	if len(tx.ops) == 0 {
+		return nil
+	}
+	defer func() {
+		for _, op := range tx.ops {
+			s.ancestors.forget(op.entity.EntityRef())
+		}
+	}()
+	now := time.Now()
+
+	var (
+		items   []types.TransactWriteItem
+		infos   []txItemInfo
+		targets = make(map[string]int)
+	)
+	add := func(target string, item types.TransactWriteItem, owner int, kind error) error {
+		if _, dup := targets[target]; dup {
+			return &TxError{Index: owner, Op: tx.ops[owner].op, EntityRef: tx.ops[owner].entity.EntityRef(),
+				Err: fmt.Errorf("%w: item written by more than one operation", ErrInvalidTransaction)}
+		}
+		targets[target] = len(items)
+		items = append(items, item)
+		infos = append(infos, txItemInfo{owners: []int{owner}, kind: kind})
+		return nil
+	}
+
+	// Entities written by this transaction, for parent check merging, and
+	// those created, for paths of their children
+	written := make(map[string]string, len(tx.ops))
+	staged := make(map[string]CreateRequest)
+	for _, op := range tx.ops {
+		target := tableKeyString(op.entity.TableName(), op.entity.GetKey())
+		written[target] = op.op
+		if op.op == TxOpCreate {
+			staged[target] = CreateRequest{Entity: op.entity, Item: op.item}
+		}
+	}
+
+	// Parent checks, merged per parent item. With child counts tracked, a
+	// check also carries the net change to the parent's count.
+	type parentCheck struct {
+		item   types.TransactWriteItem
+		kind   error
+		delta  int64
+		owners []int
+	}
+	checks := make(map[string]*parentCheck)
+	var checkOrder []string
+	addCheck := func(target string, item types.TransactWriteItem, kind error, delta int64, owner int) {
+		pc, ok := checks[target]
+		switch {
+		case !ok:
+			checks[target] = &parentCheck{item: item, kind: kind, delta: delta, owners: []int{owner}}
+			checkOrder = append(checkOrder, target)
+			return
+		case kind == ErrParentNotFound && pc.kind != ErrParentNotFound:
+			// A create needs the parent to be active, not just counted
+			pc.item, pc.kind = withChildCount(item, pc.delta), kind
+		case delta != 0:
+			pc.item = withChildCount(pc.item, delta)
+		}
+		pc.delta += delta
+		pc.owners = append(pc.owners, owner)
+	}
+
+	// Child count changes for parents written in this transaction
+	counts := make(map[string]int64)
+
+	for i, op := range tx.ops {
+		opErr := func(err error) error {
+			return &TxError{Index: i, Op: op.op, EntityRef: op.entity.EntityRef(), Err: err}
+		}
+
+		switch op.op {
+		case TxOpCreate:
+			if err := s.setPath(ctx, op.entity, op.item, staged); err != nil {
+				return opErr(err)
+			}
+			plan, err := s.buildCreate(op.entity, op.item, now)
+			if err != nil {
+				return opErr(err)
+			}
+			for idx, item := range plan.items {
+				target := plan.targets[idx]
+				if idx == plan.parentCheckIndex {
+					switch written[target] {
+					case TxOpCreate, TxOpUpdate:
+						// Parent is written (and therefore active) in this transaction
+						counts[target]++
+					case TxOpDelete:
+						return opErr(ErrParentNotFound)
+					default:
+						delta := int64(0)
+						if s.config.TrackChildCount {
+							delta = 1
+						}
+						addCheck(target, item, ErrParentNotFound, delta, i)
+					}
+					continue
+				}
+				if err := add(target, item, i, plan.failures[idx]); err != nil {
+					return err
+				}
+			}
+
+		case TxOpUpdate:
+			plan, err := s.buildUpdate(ctx, op.entity, op.item, op.expectedVersion)
+			if err != nil {
+				return opErr(err)
+			}
+			for idx, item := range plan.items {
+				if err := add(plan.targets[idx], item, i, plan.failures[idx]); err != nil {
+					return err
+				}
+			}
+
+		case TxOpDelete:
+			opts := op.deleteOpts
+			if opts.Sync || opts.MaxDepth != 0 || opts.MaxItems != 0 {
+				return opErr(fmt.Errorf("%w: synchronous cascade deletes cannot be staged", ErrInvalidTransaction))
+			}
+			if opts.OrphanProtect && !opts.Cascade && !s.config.TrackChildCount {
+				hasChildren, err := s.HasActiveChildren(ctx, op.entity.EntityRef())
+				if err != nil {
+					return opErr(err)
+				}
+				if hasChildren {
+					return opErr(ErrHasChildren)
+				}
+			}
+			update := s.softDeleteUpdate(op.entity.TableName(), op.entity.GetKey(), now.Add(opts.Retention).Unix(), now, opts.ExpectedVersion, s.countsOrphans(opts))
+			target := tableKeyString(op.entity.TableName(), op.entity.GetKey())
+			if err := add(target, types.TransactWriteItem{Update: update}, i, ErrNotFound); err != nil {
+				return err
+			}
+
+			if parent := s.countedParent(op.entity); parent != nil {
+				parentTarget := tableKeyString(parent.TableName, parent.Key)
+				switch written[parentTarget] {
+				case TxOpCreate, TxOpUpdate:
+					counts[parentTarget]--
+				case TxOpDelete:
+					// Stays counted on its deleted parent, as in a cascade
+				default:
+					addCheck(parentTarget, withChildCount(childCountCheck(parent), -1), errNoChildCount, -1, i)
+				}
+			}
+		}
+	}
+
+	if s.config.TrackChildCount {
+		for target, delta := range counts {
+			if idx, ok := targets[target]; ok && delta != 0 {
+				items[idx] = withChildCount(items[idx], delta)
+			}
+		}
+	}
+
+	for _, target := range checkOrder {
+		pc := checks[target]
+		if _, dup := targets[target]; dup {
+			owner := pc.owners[0]
+			return &TxError{Index: owner, Op: tx.ops[owner].op, EntityRef: tx.ops[owner].entity.EntityRef(),
+				Err: fmt.Errorf("%w: parent is written by another operation", ErrInvalidTransaction)}
+		}
+		targets[target] = len(items)
+		items = append(items, pc.item)
+		infos = append(infos, txItemInfo{owners: pc.owners, kind: pc.kind})
+	}
+
+	if len(items) > maxTransactItems {
+		return fmt.Errorf("%w: %d items exceeds the limit of %d", ErrInvalidTransaction, len(items), maxTransactItems)
+	}
+
+	// Deletes already rewritten to skip the child count, by op index
+	rewritten := make(map[int]bool)
+	for {
+		_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: items,
+		})
+
+		idx, old := failedItem(err, infos)
+		if idx < 0 {
+			return tx.mapError(err, items, infos)
+		}
+
+		// A parent without a child count is left uncounted
+		if infos[idx].kind == errNoChildCount {
+			items = slices.Delete(items, idx, idx+1)
+			infos = slices.Delete(infos, idx, idx+1)
+			continue
+		}
+
+		// An entity created before child counts were tracked is checked
+		// for children by query instead, once
+		owner := infos[idx].owners[0]
+		op := tx.ops[owner]
+		if op.op == TxOpDelete && s.countsOrphans(op.deleteOpts) && !rewritten[owner] && isUncounted(old) {
+			hasChildren, err := s.HasActiveChildren(ctx, op.entity.EntityRef())
+			if err == nil && hasChildren {
+				err = ErrHasChildren
+			}
+			if err != nil {
+				return &TxError{Index: owner, Op: op.op, EntityRef: op.entity.EntityRef(), Err: err}
+			}
+			opts := op.deleteOpts
+			rewritten[owner] = true
+			items[idx] = types.TransactWriteItem{
+				Update: s.softDeleteUpdate(op.entity.TableName(), op.entity.GetKey(), now.Add(opts.Retention).Unix(), now, opts.ExpectedVersion, false),
+			}
+			continue
+		}
+		return tx.mapError(err, items, infos)
+	}
+}
+
+// failedItem returns the index of the first item whose condition failed and
+// maps to an error, with the old item if it was returned, or -1.
+func failedItem(err error, infos []txItemInfo) (int, map[string]types.AttributeValue) {
+	var txErr *types.TransactionCanceledException
+	if !errors.As(err, &txErr) {
+		return -1, nil
+	}
+	for idx, reason := range txErr.CancellationReasons {
+		if idx < len(infos) && infos[idx].kind != nil && aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+			return idx, reason.Item
+		}
+	}
+	return -1, nil
+}
+
+// mapError maps a cancelled transaction to a TxError for the first staged
+// operation whose condition failed.
+func (tx *Tx) mapError(err error, items []types.TransactWriteItem, infos []txItemInfo) error {
+	if err == nil {
+		return nil
+	}
+
+	var txErr *types.TransactionCanceledException
+	if !errors.As(err, &txErr) {
+		return err
+	}
+
+	var first *TxError
+	for idx, reason := range txErr.CancellationReasons {
+		if idx >= len(infos) || infos[idx].kind == nil || reason.Code == nil || *reason.Code != "ConditionalCheckFailed" {
+			continue
+		}
+		kind := infos[idx].kind
+		if kind == ErrNotFound && len(reason.Item) > 0 {
+			// The entity exists: it is already deleted, still has children
+			// or is not at the expected version.
+			opts := tx.ops[infos[idx].owners[0]].deleteOpts
+			count, _ := itemChildCount(reason.Item)
+			switch {
+			case IsDeleted(reason.Item):
+				kind = ErrAlreadyDeleted
+			case count > 0 && tx.store.countsOrphans(opts):
+				kind = ErrHasChildren
+			default:
+				kind = ErrConcurrentModification
+			}
+		}
+		for _, owner := range infos[idx].owners {
+			if first == nil || owner < first.Index {
+				op := tx.ops[owner]
+				first = &TxError{Index: owner, Op: op.op, EntityRef: op.entity.EntityRef(),
+					Err: newConditionFailedError(idx, items[idx], kind)}
+			}
+		}
+	}
+	if first == nil {
+		return err
+	}
+	return first
+}
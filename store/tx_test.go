@@ -0,0 +1,215 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+)
+
+func TestTx_CreateParentAndChildren(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+	transactions := countTransactions(db)
+
+	err := s.Tx().
+		Create(Parent{ID: "p1"}, makeTestItem("p1", "Parent")).
+		Create(Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "One")).
+		Create(UniqueChild{ID: "u1", ParentID: "p1", Name: "n", Slug: "s"}, makeTestItem("u1", "n")).
+		Commit(ctx)
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if *transactions != 1 {
+		t.Errorf("expected 1 transaction, got %d", *transactions)
+	}
+
+	children, err := s.QueryAllChildren(ctx, "parent#p1")
+	if err != nil || len(children) != 2 {
+		t.Errorf("expected 2 children, got %d (err %v)", len(children), err)
+	}
+	if uniques := db.Items(store.DefaultConfig().UniqueTable); len(uniques) != 2 {
+		t.Errorf("expected 2 unique constraints, got %d", len(uniques))
+	}
+}
+
+func TestTx_RollbackOnFailure(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "Parent")); err != nil {
+		t.Fatalf("create parent: %v", err)
+	}
+
+	err := s.Tx().
+		Create(Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "One")).
+		Create(Child{ID: "c2", ParentID: "p1"}, makeTestItem("c2", "Two")).
+		Create(Parent{ID: "p1"}, makeTestItem("p1", "Again")).
+		Commit(ctx)
+
+	var txErr *store.TxError
+	if !errors.As(err, &txErr) {
+		t.Fatalf("expected TxError, got %v", err)
+	}
+	if txErr.Index != 2 || txErr.Op != store.TxOpCreate || txErr.EntityRef != "parent#p1" {
+		t.Errorf("unexpected TxError %+v", txErr)
+	}
+	if !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("expected ErrAlreadyExists, got %v", err)
+	}
+	var condErr *store.ConditionFailedError
+	if !errors.As(err, &condErr) || condErr.TableName != "parents" {
+		t.Errorf("expected ConditionFailedError on parents, got %v", err)
+	}
+	if items := db.Items("children"); len(items) != 0 {
+		t.Errorf("expected no children after rollback, got %d", len(items))
+	}
+}
+
+func TestTx_SharedParentCheck(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	var checks int
+	db.SetInterceptor(func(_ context.Context, op string, input any) error {
+		if op == "TransactWriteItems" {
+			for _, item := range input.(*dynamodb.TransactWriteItemsInput).TransactItems {
+				if item.ConditionCheck != nil {
+					checks++
+				}
+			}
+		}
+		return nil
+	})
+
+	err := s.Tx().
+		Create(Child{ID: "c1", ParentID: "missing"}, makeTestItem("c1", "One")).
+		Create(Child{ID: "c2", ParentID: "missing"}, makeTestItem("c2", "Two")).
+		Commit(ctx)
+	if checks != 1 {
+		t.Errorf("expected 1 shared parent check, got %d", checks)
+	}
+
+	var txErr *store.TxError
+	if !errors.As(err, &txErr) || txErr.Index != 0 {
+		t.Fatalf("expected TxError for operation 0, got %v", err)
+	}
+	if !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound, got %v", err)
+	}
+}
+
+func TestTx_UpdateAndDelete(t *testing.T) {
+	s, db := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	for _, id := range []string{"p1", "p2"} {
+		if err := s.Create(ctx, Parent{ID: id}, makeTestItem(id, id)); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	if err := s.Create(ctx, Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "Child")); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+
+	update := map[string]types.AttributeValue{"name": &types.AttributeValueMemberS{Value: "Renamed"}}
+	err := s.Tx().
+		Update(Parent{ID: "p1"}, update, 1).
+		Delete(Parent{ID: "p2"}, store.DeleteOptions{}).
+		Create(Child{ID: "c2", ParentID: "p1"}, makeTestItem("c2", "Child")).
+		Commit(ctx)
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	p1, err := s.Get(ctx, "parents", Parent{ID: "p1"}.GetKey())
+	if err != nil || p1.Version != 2 {
+		t.Errorf("expected p1 at version 2, got %v (err %v)", p1, err)
+	}
+	if !store.IsDeleted(db.Item("parents", Parent{ID: "p2"}.GetKey())) {
+		t.Error("expected p2 to be deleted")
+	}
+
+	// Stale version
+	err = s.Tx().Update(Parent{ID: "p1"}, update, 1).Commit(ctx)
+	if !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification, got %v", err)
+	}
+
+	err = s.Tx().Delete(Parent{ID: "p1"}, store.DeleteOptions{ExpectedVersion: 1}).Commit(ctx)
+	if !errors.Is(err, store.ErrConcurrentModification) {
+		t.Errorf("expected ErrConcurrentModification for stale delete, got %v", err)
+	}
+
+	// Already deleted, missing, and orphan protection
+	err = s.Tx().Delete(Parent{ID: "p2"}, store.DeleteOptions{}).Commit(ctx)
+	if !errors.Is(err, store.ErrAlreadyDeleted) {
+		t.Errorf("expected ErrAlreadyDeleted, got %v", err)
+	}
+	err = s.Tx().Delete(Parent{ID: "nope"}, store.DeleteOptions{}).Commit(ctx)
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	err = s.Tx().Delete(Parent{ID: "p1"}, store.DeleteOptions{OrphanProtect: true}).Commit(ctx)
+	if !errors.Is(err, store.ErrHasChildren) {
+		t.Errorf("expected ErrHasChildren, got %v", err)
+	}
+}
+
+func TestTx_InvalidTransactions(t *testing.T) {
+	s, _ := newMemStore(t, store.DefaultConfig())
+	ctx := context.Background()
+
+	err := s.Tx().
+		Create(Parent{ID: "p1"}, makeTestItem("p1", "One")).
+		Create(Parent{ID: "p1"}, makeTestItem("p1", "Two")).
+		Commit(ctx)
+	if !errors.Is(err, store.ErrInvalidTransaction) {
+		t.Errorf("expected ErrInvalidTransaction for duplicate target, got %v", err)
+	}
+
+	err = s.Tx().
+		Delete(Parent{ID: "p1"}, store.DeleteOptions{}).
+		Create(Child{ID: "c1", ParentID: "p1"}, makeTestItem("c1", "Child")).
+		Commit(ctx)
+	if !errors.Is(err, store.ErrParentNotFound) {
+		t.Errorf("expected ErrParentNotFound for parent deleted in tx, got %v", err)
+	}
+
+	tx := s.Tx()
+	for i := range 101 {
+		id := fmt.Sprintf("p%03d", i)
+		tx.Create(Parent{ID: id}, makeTestItem(id, id))
+	}
+	if err := tx.Commit(ctx); !errors.Is(err, store.ErrInvalidTransaction) {
+		t.Errorf("expected ErrInvalidTransaction for oversized tx, got %v", err)
+	}
+
+	if err := s.Tx().Commit(ctx); err != nil {
+		t.Errorf("expected empty tx to succeed, got %v", err)
+	}
+
+	// Synchronous cascades cannot be staged
+	if err := s.Create(ctx, Parent{ID: "p1"}, makeTestItem("p1", "One")); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for _, opts := range []store.DeleteOptions{
+		{Sync: true},
+		{Cascade: true, MaxDepth: 1},
+		{Cascade: true, MaxItems: 10},
+	} {
+		err := s.Tx().Delete(Parent{ID: "p1"}, opts).Commit(ctx)
+		var txErr *store.TxError
+		if !errors.Is(err, store.ErrInvalidTransaction) || !errors.As(err, &txErr) || txErr.Index != 0 {
+			t.Errorf("expected a TxError with ErrInvalidTransaction for %+v, got %v", opts, err)
+		}
+	}
+	if _, err := s.Get(ctx, "parents", Parent{ID: "p1"}.GetKey()); err != nil {
+		t.Errorf("expected p1 not to be deleted, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxBatchGetKeys is DynamoDB's limit on keys per BatchGetItem call.
+const maxBatchGetKeys = 100
+
+// BatchGetItem implements the DynamoDB BatchGetItem operation.
+//
+// Missing items are omitted from Responses. When a batch get limit is set
+// with SetBatchGetLimit, keys beyond the limit are returned in
+// UnprocessedKeys.
+func (db *DB) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	if err := db.begin(ctx, "BatchGetItem", params); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	total := 0
+	for _, ka := range params.RequestItems {
+		total += len(ka.Keys)
+	}
+	if total == 0 || total > maxBatchGetKeys {
+		return nil, validationError("Too many items requested for the BatchGetItem call")
+	}
+
+	// Process tables in a stable order so UnprocessedKeys is deterministic.
+	names := make([]string, 0, len(params.RequestItems))
+	for name := range params.RequestItems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]types.AttributeValue)}
+	processed := 0
+	for _, name := range names {
+		ka := params.RequestItems[name]
+		t, err := db.table(&name)
+		if err != nil {
+			return nil, err
+		}
+		ph := newPlaceholders(ka.ExpressionAttributeNames, nil)
+		projection, err := parseProjection(ka.ProjectionExpression, ph)
+		if err != nil {
+			return nil, err
+		}
+		if err := ph.checkUnused(); err != nil {
+			return nil, err
+		}
+
+		seen := make(map[string]bool, len(ka.Keys))
+		for _, k := range ka.Keys {
+			key, err := t.keyFromKey(k)
+			if err != nil {
+				return nil, err
+			}
+			if seen[key] {
+				return nil, validationError("Provided list of item keys contains duplicates")
+			}
+			seen[key] = true
+
+			if db.batchLimit > 0 && processed >= db.batchLimit {
+				if out.UnprocessedKeys == nil {
+					out.UnprocessedKeys = make(map[string]types.KeysAndAttributes)
+				}
+				pending := out.UnprocessedKeys[name]
+				pending.Keys = append(pending.Keys, t.keyAttrs(k))
+				pending.ProjectionExpression = ka.ProjectionExpression
+				pending.ExpressionAttributeNames = ka.ExpressionAttributeNames
+				out.UnprocessedKeys[name] = pending
+				continue
+			}
+			processed++
+			if item, ok := t.items[key]; ok {
+				out.Responses[name] = append(out.Responses[name], project(item, projection))
+			}
+		}
+	}
+	return out, nil
+}
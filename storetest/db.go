@@ -0,0 +1,638 @@
+// Package storetest provides an in-memory, DynamoDB-compatible backend for
+// testing code built on trellis without AWS.
+//
+// A [DB] implements the DynamoDB operations used by store.Store, including
+// conditional writes, TransactWriteItems with per-item cancellation reasons,
+// Query with key conditions, filters and pagination, and parallel Scan. Tables with streams
+// enabled record change events that can be fed to stream.Handler:
+//
+//	db := storetest.New()
+//	db.MustCreateTable(storetest.Table{Name: "organizations", PartitionKey: "id", Stream: true})
+//	db.MustCreateTable(storetest.RelationshipTable("trellis_relationships"))
+//	db.MustCreateTable(storetest.UniqueTable("trellis_unique_constraints"))
+//
+//	s := store.New(db, store.DefaultConfig())
+//	// ... exercise s ...
+//	err := handler.HandleCascadeDelete(ctx, db.DrainStream())
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxTransactItems is DynamoDB's limit on items per TransactWriteItems call.
+const maxTransactItems = 100
+
+// Table describes an in-memory table.
+type Table struct {
+	// Name is the table name.
+	Name string
+
+	// PartitionKey is the partition (hash) key attribute name.
+	PartitionKey string
+
+	// SortKey is the optional sort (range) key attribute name.
+	SortKey string
+
+	// Indexes are secondary indexes that can be targeted by Query.
+	Indexes []Index
+
+	// Stream records INSERT, MODIFY and REMOVE events with new and old images.
+	Stream bool
+
+	// TTLAttribute is the attribute SweepTTL uses to expire items.
+	TTLAttribute string
+}
+
+// Index describes a secondary index. All attributes are projected.
+type Index struct {
+	Name         string
+	PartitionKey string
+	SortKey      string
+}
+
+// RelationshipTable returns the schema trellis expects for its relationship table.
+func RelationshipTable(name string) Table {
+	return Table{Name: name, PartitionKey: "pk", SortKey: "child_ref", TTLAttribute: "ttl"}
+}
+
+// UniqueTable returns the schema trellis expects for its unique constraints table.
+func UniqueTable(name string) Table {
+	return Table{Name: name, PartitionKey: "pk", SortKey: "sk", TTLAttribute: "ttl"}
+}
+
+// CascadeJobTable returns the schema trellis expects for its cascade job
+// table, stream-enabled for cascade checkpoints.
+func CascadeJobTable(name string) Table {
+	return Table{Name: name, PartitionKey: "pk", Stream: true, TTLAttribute: "ttl"}
+}
+
+// EntityTable returns a stream-enabled entity table keyed by "id".
+func EntityTable(name string) Table {
+	return Table{Name: name, PartitionKey: "id", Stream: true, TTLAttribute: "ttl"}
+}
+
+// Interceptor is called before every operation with the operation name
+// (e.g. "UpdateItem") and its input. A non-nil error is returned to the
+// caller instead of executing the operation, which makes it possible to
+// inject throttling or other faults.
+type Interceptor func(ctx context.Context, op string, input any) error
+
+// DB is an in-memory DynamoDB backend. It is safe for concurrent use.
+type DB struct {
+	mu          sync.Mutex
+	tables      map[string]*table
+	interceptor Interceptor
+	pageSize    int
+	batchLimit  int
+	pending     []events.DynamoDBEventRecord
+	seq         int64
+	now         func() time.Time
+}
+
+type table struct {
+	schema Table
+	items  map[string]map[string]types.AttributeValue
+}
+
+// New creates an empty DB.
+func New() *DB {
+	return &DB{
+		tables: make(map[string]*table),
+		now:    time.Now,
+	}
+}
+
+// CreateTable adds a table to the DB.
+func (db *DB) CreateTable(t Table) error {
+	if t.Name == "" || t.PartitionKey == "" {
+		return fmt.Errorf("storetest: table name and partition key are required")
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, exists := db.tables[t.Name]; exists {
+		return &types.ResourceInUseException{Message: aws.String("Table already exists: " + t.Name)}
+	}
+	db.tables[t.Name] = &table{schema: t, items: make(map[string]map[string]types.AttributeValue)}
+	return nil
+}
+
+// MustCreateTable is like CreateTable but panics on error.
+func (db *DB) MustCreateTable(t Table) {
+	if err := db.CreateTable(t); err != nil {
+		panic(err)
+	}
+}
+
+// SetInterceptor installs fn to run before every operation. Pass nil to remove it.
+func (db *DB) SetInterceptor(fn Interceptor) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.interceptor = fn
+}
+
+// SetPageSize caps the number of items evaluated per Query page, simulating
+// DynamoDB's 1 MB page limit so that pagination paths are exercised.
+// Zero means unlimited.
+func (db *DB) SetPageSize(n int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.pageSize = n
+}
+
+// SetBatchGetLimit caps the number of items BatchGetItem returns per call;
+// the remaining keys come back as UnprocessedKeys, as they do when DynamoDB
+// hits its response size limit or throttles. Zero means unlimited.
+func (db *DB) SetBatchGetLimit(n int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.batchLimit = n
+}
+
+// Items returns copies of all items in a table ordered by primary key.
+func (db *DB) Items(tableName string) []map[string]types.AttributeValue {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, ok := db.tables[tableName]
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(t.items))
+	for k := range t.items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]map[string]types.AttributeValue, len(keys))
+	for i, k := range keys {
+		out[i] = copyItem(t.items[k])
+	}
+	return out
+}
+
+// Item returns a copy of the item stored under key, or nil if absent.
+func (db *DB) Item(tableName string, key map[string]types.AttributeValue) map[string]types.AttributeValue {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t, ok := db.tables[tableName]
+	if !ok {
+		return nil
+	}
+	k, err := t.keyOf(key)
+	if err != nil {
+		return nil
+	}
+	return copyItem(t.items[k])
+}
+
+// DrainStream returns and clears all pending stream records from
+// stream-enabled tables, in commit order.
+func (db *DB) DrainStream() events.DynamoDBEvent {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	records := db.pending
+	db.pending = nil
+	return events.DynamoDBEvent{Records: records}
+}
+
+// SweepTTL physically deletes items whose TTL attribute is at or before now,
+// as DynamoDB's TTL process eventually does. It returns the number of items
+// removed. REMOVE stream records carry the TTL service identity.
+func (db *DB) SweepTTL(now time.Time) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	removed := 0
+	for _, name := range db.tableNames() {
+		t := db.tables[name]
+		if t.schema.TTLAttribute == "" {
+			continue
+		}
+		for _, k := range sortedItemKeys(t.items) {
+			item := t.items[k]
+			n, ok := item[t.schema.TTLAttribute].(*types.AttributeValueMemberN)
+			if !ok {
+				continue
+			}
+			ttl, ok := parseNumber(n.Value)
+			if !ok || !ttl.IsInt() || ttl.Num().Int64() > now.Unix() {
+				continue
+			}
+			db.write(t, k, item, nil, true)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (db *DB) tableNames() []string {
+	names := make([]string, 0, len(db.tables))
+	for name := range db.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedItemKeys(items map[string]map[string]types.AttributeValue) []string {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// begin runs the interceptor and context checks shared by every operation.
+func (db *DB) begin(ctx context.Context, op string, input any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	fn := db.interceptor
+	db.mu.Unlock()
+	if fn != nil {
+		return fn(ctx, op, input)
+	}
+	return nil
+}
+
+func (db *DB) table(name *string) (*table, error) {
+	if name == nil {
+		return nil, validationError("TableName is required")
+	}
+	t, ok := db.tables[*name]
+	if !ok {
+		return nil, resourceNotFound(*name)
+	}
+	return t, nil
+}
+
+// keyAttrNames returns the table's primary key attribute names.
+func (t *table) keyAttrNames() []string {
+	if t.schema.SortKey == "" {
+		return []string{t.schema.PartitionKey}
+	}
+	return []string{t.schema.PartitionKey, t.schema.SortKey}
+}
+
+// keyOf validates that item carries the table's key and returns its canonical form.
+func (t *table) keyOf(item map[string]types.AttributeValue) (string, error) {
+	parts := make([]string, 0, 2)
+	for _, name := range t.keyAttrNames() {
+		v, ok := item[name]
+		if !ok {
+			return "", validationError("One or more parameter values were invalid: Missing the key " + name + " in the item")
+		}
+		part, ok := keyPart(v)
+		if !ok {
+			return "", validationError("One or more parameter values were invalid: Type mismatch for key " + name)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "|"), nil
+}
+
+// keyFromKey validates a Key parameter: exactly the key attributes, nothing else.
+func (t *table) keyFromKey(key map[string]types.AttributeValue) (string, error) {
+	if len(key) != len(t.keyAttrNames()) {
+		return "", validationError("The provided key element does not match the schema")
+	}
+	return t.keyOf(key)
+}
+
+// keyAttrs extracts the primary key attributes from an item.
+func (t *table) keyAttrs(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	out := make(map[string]types.AttributeValue, 2)
+	for _, name := range t.keyAttrNames() {
+		if v, ok := item[name]; ok {
+			out[name] = copyValue(v)
+		}
+	}
+	return out
+}
+
+// write stores newItem (nil deletes) and emits a stream record.
+// Callers must hold db.mu.
+func (db *DB) write(t *table, key string, oldItem, newItem map[string]types.AttributeValue, byTTL bool) {
+	if newItem == nil {
+		delete(t.items, key)
+	} else {
+		t.items[key] = copyItem(newItem)
+	}
+	if !t.schema.Stream {
+		return
+	}
+
+	db.seq++
+	record := events.DynamoDBEventRecord{
+		AWSRegion:      "local",
+		EventID:        fmt.Sprintf("storetest-%d", db.seq),
+		EventSource:    "aws:dynamodb",
+		EventVersion:   "1.1",
+		EventSourceArn: fmt.Sprintf("arn:aws:dynamodb:local:000000000000:table/%s/stream/storetest", t.schema.Name),
+		Change: events.DynamoDBStreamRecord{
+			ApproximateCreationDateTime: events.SecondsEpochTime{Time: db.now()},
+			SequenceNumber:              fmt.Sprintf("%021d", db.seq),
+			StreamViewType:              "NEW_AND_OLD_IMAGES",
+		},
+	}
+	switch {
+	case oldItem == nil:
+		record.EventName = "INSERT"
+		record.Change.Keys = toStreamImage(t.keyAttrs(newItem))
+		record.Change.NewImage = toStreamImage(newItem)
+	case newItem == nil:
+		record.EventName = "REMOVE"
+		record.Change.Keys = toStreamImage(t.keyAttrs(oldItem))
+		record.Change.OldImage = toStreamImage(oldItem)
+	default:
+		record.EventName = "MODIFY"
+		record.Change.Keys = toStreamImage(t.keyAttrs(newItem))
+		record.Change.OldImage = toStreamImage(oldItem)
+		record.Change.NewImage = toStreamImage(newItem)
+	}
+	if byTTL {
+		record.UserIdentity = &events.DynamoDBUserIdentity{Type: "Service", PrincipalID: "dynamodb.amazonaws.com"}
+	}
+	db.pending = append(db.pending, record)
+}
+
+// --- Single-item operations ---
+
+// GetItem implements the DynamoDB GetItem operation.
+func (db *DB) GetItem(ctx context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := db.begin(ctx, "GetItem", params); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.keyFromKey(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	ph := newPlaceholders(params.ExpressionAttributeNames, nil)
+	projection, err := parseProjection(params.ProjectionExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	if err := ph.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	item, ok := t.items[key]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+	return &dynamodb.GetItemOutput{Item: project(item, projection)}, nil
+}
+
+// PutItem implements the DynamoDB PutItem operation.
+func (db *DB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := db.begin(ctx, "PutItem", params); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.keyOf(params.Item)
+	if err != nil {
+		return nil, err
+	}
+	ph := newPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	cond, err := parseOptionalCondition(params.ConditionExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	if err := ph.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	if ok, err := testCondition(cond, old); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, conditionalCheckFailed(old, params.ReturnValuesOnConditionCheckFailure == types.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+
+	db.write(t, key, old, params.Item, false)
+	out := &dynamodb.PutItemOutput{}
+	if params.ReturnValues == types.ReturnValueAllOld {
+		out.Attributes = copyItem(old)
+	}
+	return out, nil
+}
+
+// DeleteItem implements the DynamoDB DeleteItem operation.
+func (db *DB) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := db.begin(ctx, "DeleteItem", params); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.keyFromKey(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	ph := newPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	cond, err := parseOptionalCondition(params.ConditionExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	if err := ph.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	if ok, err := testCondition(cond, old); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, conditionalCheckFailed(old, params.ReturnValuesOnConditionCheckFailure == types.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+
+	out := &dynamodb.DeleteItemOutput{}
+	if old != nil {
+		db.write(t, key, old, nil, false)
+		if params.ReturnValues == types.ReturnValueAllOld {
+			out.Attributes = copyItem(old)
+		}
+	}
+	return out, nil
+}
+
+// UpdateItem implements the DynamoDB UpdateItem operation.
+func (db *DB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := db.begin(ctx, "UpdateItem", params); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	key, err := t.keyFromKey(params.Key)
+	if err != nil {
+		return nil, err
+	}
+	if params.UpdateExpression == nil {
+		return nil, validationError("UpdateExpression is required")
+	}
+	ph := newPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	actions, err := parseUpdate(*params.UpdateExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	cond, err := parseOptionalCondition(params.ConditionExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	if err := ph.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	old := t.items[key]
+	if ok, err := testCondition(cond, old); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, conditionalCheckFailed(old, params.ReturnValuesOnConditionCheckFailure == types.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+
+	updated, err := t.applyUpdate(old, params.Key, actions)
+	if err != nil {
+		return nil, err
+	}
+	db.write(t, key, old, updated, false)
+
+	out := &dynamodb.UpdateItemOutput{}
+	switch params.ReturnValues {
+	case types.ReturnValueAllNew:
+		out.Attributes = copyItem(updated)
+	case types.ReturnValueAllOld:
+		out.Attributes = copyItem(old)
+	case types.ReturnValueUpdatedNew:
+		out.Attributes = changedAttributes(updated, old)
+	case types.ReturnValueUpdatedOld:
+		out.Attributes = changedAttributes(old, updated)
+	}
+	return out, nil
+}
+
+// applyUpdate applies update actions to an existing item (or to a new item
+// holding only the key) and rejects changes to key attributes.
+func (t *table) applyUpdate(old, key map[string]types.AttributeValue, actions []updateAction) (map[string]types.AttributeValue, error) {
+	base := old
+	if base == nil {
+		base = copyItem(key)
+	}
+	updated, err := applyUpdate(base, actions)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range t.keyAttrNames() {
+		if v, ok := updated[name]; !ok || !equalValues(v, key[name]) {
+			return nil, validationError("One or more parameter values were invalid: Cannot update attribute " + name + ". This attribute is part of the key")
+		}
+	}
+	return updated, nil
+}
+
+// changedAttributes returns the attributes of a that differ from b.
+func changedAttributes(a, b map[string]types.AttributeValue) map[string]types.AttributeValue {
+	out := map[string]types.AttributeValue{}
+	for k, v := range a {
+		if other, ok := b[k]; !ok || !equalValues(v, other) {
+			out[k] = copyValue(v)
+		}
+	}
+	return out
+}
+
+func parseOptionalCondition(expr *string, ph *placeholders) (condition, error) {
+	if expr == nil || *expr == "" {
+		return nil, nil
+	}
+	return parseCondition(*expr, ph)
+}
+
+func testCondition(cond condition, item map[string]types.AttributeValue) (bool, error) {
+	if cond == nil {
+		return true, nil
+	}
+	return cond.test(item)
+}
+
+// parseProjection parses a comma separated ProjectionExpression.
+func parseProjection(expr *string, ph *placeholders) ([]pathOperand, error) {
+	if expr == nil || *expr == "" {
+		return nil, nil
+	}
+	p, err := newParser(*expr, ph)
+	if err != nil {
+		return nil, err
+	}
+	var paths []pathOperand
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return paths, nil
+}
+
+// project copies the projected attributes of item (all when paths is empty).
+func project(item map[string]types.AttributeValue, paths []pathOperand) map[string]types.AttributeValue {
+	if len(paths) == 0 {
+		return copyItem(item)
+	}
+	out := map[string]types.AttributeValue{}
+	for _, path := range paths {
+		v, ok := resolvePath(item, path.segments)
+		if !ok {
+			continue
+		}
+		m := out
+		for _, seg := range path.segments[:len(path.segments)-1] {
+			child, ok := m[seg].(*types.AttributeValueMemberM)
+			if !ok {
+				child = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}}
+				m[seg] = child
+			}
+			m = child.Value
+		}
+		m[path.segments[len(path.segments)-1]] = copyValue(v)
+	}
+	return out
+}
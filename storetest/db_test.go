@@ -0,0 +1,621 @@
+package storetest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/jacentio/trellis/storetest"
+)
+
+func newDB(t *testing.T) *storetest.DB {
+	t.Helper()
+	db := storetest.New()
+	db.MustCreateTable(storetest.EntityTable("items"))
+	db.MustCreateTable(storetest.RelationshipTable("rels"))
+	return db
+}
+
+func s(v string) *types.AttributeValueMemberS { return &types.AttributeValueMemberS{Value: v} }
+func n(v string) *types.AttributeValueMemberN { return &types.AttributeValueMemberN{Value: v} }
+
+func isValidation(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException"
+}
+
+func put(t *testing.T, db *storetest.DB, table string, item map[string]types.AttributeValue) {
+	t.Helper()
+	if _, err := db.PutItem(context.Background(), &dynamodb.PutItemInput{TableName: aws.String(table), Item: item}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+}
+
+// --- Table Tests ---
+
+func TestCreateTable_Duplicate(t *testing.T) {
+	db := newDB(t)
+	var inUse *types.ResourceInUseException
+	if err := db.CreateTable(storetest.EntityTable("items")); !errors.As(err, &inUse) {
+		t.Errorf("expected ResourceInUseException, got %v", err)
+	}
+}
+
+func TestGetItem_UnknownTable(t *testing.T) {
+	db := storetest.New()
+	_, err := db.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String("missing"),
+		Key:       map[string]types.AttributeValue{"id": s("x")},
+	})
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected ResourceNotFoundException, got %v", err)
+	}
+}
+
+// --- Single-item Tests ---
+
+func TestPutItem_ConditionalCheckFailed(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("a"), "name": s("one")})
+
+	_, err := db.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                           aws.String("items"),
+		Item:                                map[string]types.AttributeValue{"id": s("a")},
+		ConditionExpression:                 aws.String("attribute_not_exists(id)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	var condErr *types.ConditionalCheckFailedException
+	if !errors.As(err, &condErr) {
+		t.Fatalf("expected ConditionalCheckFailedException, got %v", err)
+	}
+	if v, ok := condErr.Item["name"].(*types.AttributeValueMemberS); !ok || v.Value != "one" {
+		t.Errorf("expected old item to be returned, got %v", condErr.Item)
+	}
+}
+
+func TestPutItem_MissingKey(t *testing.T) {
+	db := newDB(t)
+	_, err := db.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String("items"),
+		Item:      map[string]types.AttributeValue{"name": s("x")},
+	})
+	if !isValidation(err) {
+		t.Errorf("expected ValidationException, got %v", err)
+	}
+}
+
+func TestUpdateItem_Arithmetic(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("a"), "version": n("1")})
+
+	out, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String("items"),
+		Key:                       map[string]types.AttributeValue{"id": s("a")},
+		UpdateExpression:          aws.String("SET #version = #version + :one, #name = :name ADD #count :one"),
+		ConditionExpression:       aws.String("#version = :expected"),
+		ExpressionAttributeNames:  map[string]string{"#version": "version", "#name": "name", "#count": "count"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":one": n("1"), ":name": s("x"), ":expected": n("1")},
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := out.Attributes["version"].(*types.AttributeValueMemberN).Value; v != "2" {
+		t.Errorf("expected version 2, got %s", v)
+	}
+	if v := out.Attributes["count"].(*types.AttributeValueMemberN).Value; v != "1" {
+		t.Errorf("expected count 1, got %s", v)
+	}
+}
+
+func TestUpdateItem_MissingOperandIsValidationError(t *testing.T) {
+	db := newDB(t)
+	_, err := db.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName:                 aws.String("items"),
+		Key:                       map[string]types.AttributeValue{"id": s("missing")},
+		UpdateExpression:          aws.String("SET #version = #version + :one"),
+		ExpressionAttributeNames:  map[string]string{"#version": "version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":one": n("1")},
+	})
+	if !isValidation(err) {
+		t.Errorf("expected ValidationException, got %v", err)
+	}
+}
+
+func TestUpdateItem_RemoveAndKeyProtection(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("a"), "ttl": n("5")})
+
+	_, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String("items"),
+		Key:                      map[string]types.AttributeValue{"id": s("a")},
+		UpdateExpression:         aws.String("REMOVE #ttl"),
+		ExpressionAttributeNames: map[string]string{"#ttl": "ttl"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := db.Item("items", map[string]types.AttributeValue{"id": s("a")})["ttl"]; ok {
+		t.Error("expected ttl to be removed")
+	}
+
+	_, err = db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String("items"),
+		Key:                       map[string]types.AttributeValue{"id": s("a")},
+		UpdateExpression:          aws.String("SET id = :id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":id": s("b")},
+	})
+	if !isValidation(err) {
+		t.Errorf("expected ValidationException for key update, got %v", err)
+	}
+}
+
+func TestUnusedPlaceholdersRejected(t *testing.T) {
+	db := newDB(t)
+	_, err := db.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName:                 aws.String("items"),
+		Key:                       map[string]types.AttributeValue{"id": s("a")},
+		UpdateExpression:          aws.String("SET #name = :name"),
+		ExpressionAttributeNames:  map[string]string{"#name": "name", "#unused": "x"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":name": s("x")},
+	})
+	if !isValidation(err) {
+		t.Errorf("expected ValidationException for unused name, got %v", err)
+	}
+}
+
+func TestConditionExpressions(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	put(t, db, "items", map[string]types.AttributeValue{
+		"id":   s("a"),
+		"name": s("alpha"),
+		"n":    n("10"),
+		"tags": &types.AttributeValueMemberSS{Value: []string{"x", "y"}},
+	})
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"attribute_exists(id)", true},
+		{"attribute_not_exists(missing)", true},
+		{"NOT attribute_exists(id)", false},
+		{"n > :five AND n <= :ten", true},
+		{"n BETWEEN :five AND :ten", true},
+		{"n IN (:five, :ten)", true},
+		{"begins_with(#name, :al)", true},
+		{"contains(tags, :x)", true},
+		{"size(#name) = :five", true},
+		{"n < :five OR (attribute_exists(id) AND #name <> :al)", true},
+		{"attribute_type(n, :typeN)", true},
+		{"missing = :five", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			names := map[string]string{}
+			if strings.Contains(tt.expr, "#name") {
+				names["#name"] = "name"
+			}
+			values := map[string]types.AttributeValue{}
+			for _, v := range []string{":five", ":ten", ":al", ":x", ":typeN"} {
+				if strings.Contains(tt.expr, v) {
+					values[v] = map[string]types.AttributeValue{
+						":five": n("5"), ":ten": n("10"), ":al": s("al"), ":x": s("x"), ":typeN": s("N"),
+					}[v]
+				}
+			}
+			_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+				TransactItems: []types.TransactWriteItem{{
+					ConditionCheck: &types.ConditionCheck{
+						TableName:                 aws.String("items"),
+						Key:                       map[string]types.AttributeValue{"id": s("a")},
+						ConditionExpression:       aws.String(tt.expr),
+						ExpressionAttributeNames:  nilIfEmpty(names),
+						ExpressionAttributeValues: values,
+					},
+				}},
+			})
+			var txErr *types.TransactionCanceledException
+			got := err == nil
+			if err != nil && !errors.As(err, &txErr) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func nilIfEmpty(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
+
+// --- Transaction Tests ---
+
+func TestTransactWriteItems_CancellationReasonsAlignWithItems(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("existing")})
+
+	_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{ConditionCheck: &types.ConditionCheck{
+				TableName:           aws.String("items"),
+				Key:                 map[string]types.AttributeValue{"id": s("existing")},
+				ConditionExpression: aws.String("attribute_exists(id)"),
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String("items"),
+				Item:                map[string]types.AttributeValue{"id": s("new")},
+				ConditionExpression: aws.String("attribute_not_exists(id)"),
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String("rels"),
+				Item:                map[string]types.AttributeValue{"pk": s("p"), "child_ref": s("c")},
+				ConditionExpression: aws.String("attribute_exists(pk)"),
+			}},
+		},
+	})
+	var txErr *types.TransactionCanceledException
+	if !errors.As(err, &txErr) {
+		t.Fatalf("expected TransactionCanceledException, got %v", err)
+	}
+	codes := make([]string, len(txErr.CancellationReasons))
+	for i, r := range txErr.CancellationReasons {
+		codes[i] = aws.ToString(r.Code)
+	}
+	want := []string{"None", "None", "ConditionalCheckFailed"}
+	if fmt.Sprint(codes) != fmt.Sprint(want) {
+		t.Errorf("expected reasons %v, got %v", want, codes)
+	}
+	if db.Item("items", map[string]types.AttributeValue{"id": s("new")}) != nil {
+		t.Error("expected no writes from a cancelled transaction")
+	}
+}
+
+func TestTransactWriteItems_MultipleOperationsOnOneItem(t *testing.T) {
+	db := newDB(t)
+	_, err := db.TransactWriteItems(context.Background(), &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{TableName: aws.String("items"), Item: map[string]types.AttributeValue{"id": s("a")}}},
+			{Delete: &types.Delete{TableName: aws.String("items"), Key: map[string]types.AttributeValue{"id": s("a")}}},
+		},
+	})
+	if !isValidation(err) {
+		t.Errorf("expected ValidationException, got %v", err)
+	}
+}
+
+func TestTransactWriteItems_Commit(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("gone")})
+
+	_, err := db.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{TableName: aws.String("items"), Item: map[string]types.AttributeValue{"id": s("a")}}},
+			{Delete: &types.Delete{TableName: aws.String("items"), Key: map[string]types.AttributeValue{"id": s("gone")}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if items := db.Items("items"); len(items) != 1 {
+		t.Errorf("expected 1 item after commit, got %d", len(items))
+	}
+}
+
+// --- Query Tests ---
+
+func seedRels(t *testing.T, db *storetest.DB, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		item := map[string]types.AttributeValue{"pk": s("parent#p1#00"), "child_ref": s(fmt.Sprintf("child#%02d", i))}
+		if i%2 == 1 {
+			item["ttl"] = n("1000")
+		}
+		put(t, db, "rels", item)
+	}
+	put(t, db, "rels", map[string]types.AttributeValue{"pk": s("parent#p2#00"), "child_ref": s("child#x")})
+}
+
+func TestQuery_PaginatesAndFilters(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	seedRels(t, db, 10)
+
+	var got []string
+	var pages int
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := db.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String("rels"),
+			KeyConditionExpression:    aws.String("pk = :pk"),
+			FilterExpression:          aws.String("attribute_not_exists(#ttl)"),
+			ExpressionAttributeNames:  map[string]string{"#ttl": "ttl"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("parent#p1#00")},
+			Limit:                     aws.Int32(3),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pages++
+		if out.ScannedCount > 3 {
+			t.Errorf("expected at most 3 evaluated items per page, got %d", out.ScannedCount)
+		}
+		for _, item := range out.Items {
+			got = append(got, item["child_ref"].(*types.AttributeValueMemberS).Value)
+		}
+		if out.LastEvaluatedKey == nil {
+			break
+		}
+		startKey = out.LastEvaluatedKey
+	}
+
+	if pages != 4 {
+		t.Errorf("expected 4 pages, got %d", pages)
+	}
+	want := "[child#00 child#02 child#04 child#06 child#08]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("expected %s, got %v", want, got)
+	}
+}
+
+func TestQuery_DescendingAndSortKeyCondition(t *testing.T) {
+	db := newDB(t)
+	seedRels(t, db, 5)
+
+	out, err := db.Query(context.Background(), &dynamodb.QueryInput{
+		TableName:              aws.String("rels"),
+		KeyConditionExpression: aws.String("pk = :pk AND begins_with(child_ref, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":     s("parent#p1#00"),
+			":prefix": s("child#0"),
+		},
+		ScanIndexForward: aws.Bool(false),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out.Items) != 5 {
+		t.Fatalf("expected 5 items, got %d", len(out.Items))
+	}
+	if v := out.Items[0]["child_ref"].(*types.AttributeValueMemberS).Value; v != "child#04" {
+		t.Errorf("expected descending order starting at child#04, got %s", v)
+	}
+}
+
+func TestQuery_Index(t *testing.T) {
+	db := storetest.New()
+	db.MustCreateTable(storetest.Table{
+		Name:         "studios",
+		PartitionKey: "id",
+		Indexes:      []storetest.Index{{Name: "by_org", PartitionKey: "organization_id", SortKey: "name"}},
+	})
+	for i, name := range []string{"b", "a", "c"} {
+		put(t, db, "studios", map[string]types.AttributeValue{
+			"id": s(fmt.Sprint(i)), "organization_id": s("org-1"), "name": s(name),
+		})
+	}
+
+	out, err := db.Query(context.Background(), &dynamodb.QueryInput{
+		TableName:                 aws.String("studios"),
+		IndexName:                 aws.String("by_org"),
+		KeyConditionExpression:    aws.String("organization_id = :org"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":org": s("org-1")},
+		Limit:                     aws.Int32(2),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out.Items) != 2 || out.Items[0]["name"].(*types.AttributeValueMemberS).Value != "a" {
+		t.Errorf("expected first two studios ordered by name, got %v", out.Items)
+	}
+	if _, ok := out.LastEvaluatedKey["organization_id"]; !ok {
+		t.Error("expected LastEvaluatedKey to include index keys")
+	}
+}
+
+func TestQuery_SelectCount(t *testing.T) {
+	db := newDB(t)
+	seedRels(t, db, 4)
+
+	out, err := db.Query(context.Background(), &dynamodb.QueryInput{
+		TableName:                 aws.String("rels"),
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("parent#p1#00")},
+		Select:                    types.SelectCount,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Count != 4 || out.Items != nil {
+		t.Errorf("expected count 4 without items, got %d and %v", out.Count, out.Items)
+	}
+}
+
+func TestSetPageSize(t *testing.T) {
+	db := newDB(t)
+	seedRels(t, db, 5)
+	db.SetPageSize(2)
+
+	out, err := db.Query(context.Background(), &dynamodb.QueryInput{
+		TableName:                 aws.String("rels"),
+		KeyConditionExpression:    aws.String("pk = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("parent#p1#00")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out.Items) != 2 || out.LastEvaluatedKey == nil {
+		t.Errorf("expected a 2-item page with LastEvaluatedKey, got %d items", len(out.Items))
+	}
+}
+
+// --- Stream Tests ---
+
+func TestDrainStream_RecordsChanges(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("a")})
+	put(t, db, "rels", map[string]types.AttributeValue{"pk": s("p"), "child_ref": s("c")}) // no stream
+	_, err := db.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String("items"),
+		Key:                       map[string]types.AttributeValue{"id": s("a")},
+		UpdateExpression:          aws.String("SET #ttl = :ttl"),
+		ExpressionAttributeNames:  map[string]string{"#ttl": "ttl"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":ttl": n("1000")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	event := db.DrainStream()
+	if len(event.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(event.Records))
+	}
+	if event.Records[0].EventName != "INSERT" || event.Records[1].EventName != "MODIFY" {
+		t.Errorf("expected INSERT then MODIFY, got %s then %s", event.Records[0].EventName, event.Records[1].EventName)
+	}
+	modify := event.Records[1].Change
+	if _, ok := modify.OldImage["ttl"]; ok {
+		t.Error("expected old image without ttl")
+	}
+	if modify.NewImage["ttl"].Number() != "1000" {
+		t.Errorf("expected new image ttl 1000, got %v", modify.NewImage["ttl"])
+	}
+	if len(db.DrainStream().Records) != 0 {
+		t.Error("expected stream to be empty after drain")
+	}
+}
+
+func TestSweepTTL(t *testing.T) {
+	db := newDB(t)
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("expired"), "ttl": n("1000")})
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("live")})
+	db.DrainStream()
+
+	if removed := db.SweepTTL(time.Now()); removed != 1 {
+		t.Errorf("expected 1 item removed, got %d", removed)
+	}
+	event := db.DrainStream()
+	if len(event.Records) != 1 || event.Records[0].EventName != "REMOVE" || event.Records[0].UserIdentity == nil {
+		t.Errorf("expected one REMOVE record from the TTL service, got %+v", event.Records)
+	}
+}
+
+// --- Interceptor Tests ---
+
+func TestSetInterceptor(t *testing.T) {
+	db := newDB(t)
+	throttled := &types.ProvisionedThroughputExceededException{Message: aws.String("slow down")}
+	db.SetInterceptor(func(_ context.Context, op string, _ any) error {
+		if op == "PutItem" {
+			return throttled
+		}
+		return nil
+	})
+
+	_, err := db.PutItem(context.Background(), &dynamodb.PutItemInput{
+		TableName: aws.String("items"),
+		Item:      map[string]types.AttributeValue{"id": s("a")},
+	})
+	if !errors.Is(err, throttled) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+
+	db.SetInterceptor(nil)
+	put(t, db, "items", map[string]types.AttributeValue{"id": s("a")})
+}
+
+// --- Scan Tests ---
+
+func TestScan_SegmentsPartitionItems(t *testing.T) {
+	db := newDB(t)
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		put(t, db, "items", map[string]types.AttributeValue{"id": s(fmt.Sprint(i))})
+	}
+
+	seen := make(map[string]int)
+	for segment := int32(0); segment < 4; segment++ {
+		out, err := db.Scan(ctx, &dynamodb.ScanInput{
+			TableName:     aws.String("items"),
+			Segment:       aws.Int32(segment),
+			TotalSegments: aws.Int32(4),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, item := range out.Items {
+			seen[item["id"].(*types.AttributeValueMemberS).Value]++
+		}
+	}
+	if len(seen) != 20 {
+		t.Errorf("expected all 20 items across segments, got %d", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("item %s returned by %d segments", id, n)
+		}
+	}
+
+	_, err := db.Scan(ctx, &dynamodb.ScanInput{TableName: aws.String("items"), Segment: aws.Int32(4), TotalSegments: aws.Int32(4)})
+	if !isValidation(err) {
+		t.Errorf("expected ValidationException for out-of-range segment, got %v", err)
+	}
+}
+
+// --- BatchGetItem Tests ---
+
+func TestBatchGetItem_UnprocessedKeys(t *testing.T) {
+	db := newDB(t)
+	for i := 0; i < 5; i++ {
+		put(t, db, "items", map[string]types.AttributeValue{"id": s(fmt.Sprint(i))})
+	}
+	db.SetBatchGetLimit(3)
+
+	keys := make([]map[string]types.AttributeValue, 6)
+	for i := range keys {
+		keys[i] = map[string]types.AttributeValue{"id": s(fmt.Sprint(i))}
+	}
+	out, err := db.BatchGetItem(context.Background(), &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{"items": {Keys: keys}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := len(out.Responses["items"]); n != 3 {
+		t.Errorf("expected 3 items, got %d", n)
+	}
+	if n := len(out.UnprocessedKeys["items"].Keys); n != 3 {
+		t.Errorf("expected 3 unprocessed keys, got %d", n)
+	}
+
+	keys[1] = keys[0]
+	_, err = db.BatchGetItem(context.Background(), &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{"items": {Keys: keys}},
+	})
+	if !isValidation(err) {
+		t.Errorf("expected ValidationException for duplicate keys, got %v", err)
+	}
+}
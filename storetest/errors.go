@@ -0,0 +1,27 @@
+package storetest
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+)
+
+// validationError mirrors the ValidationException DynamoDB returns for
+// malformed requests.
+func validationError(msg string) error {
+	return &smithy.GenericAPIError{Code: "ValidationException", Message: msg, Fault: smithy.FaultClient}
+}
+
+func resourceNotFound(table string) error {
+	return &types.ResourceNotFoundException{
+		Message: aws.String("Requested resource not found: Table: " + table + " not found"),
+	}
+}
+
+func conditionalCheckFailed(old map[string]types.AttributeValue, returnOld bool) error {
+	err := &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	if returnOld {
+		err.Item = copyItem(old)
+	}
+	return err
+}
@@ -0,0 +1,947 @@
+package storetest
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// --- Lexer ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokName  // #placeholder
+	tokValue // :placeholder
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(expr string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#' || c == ':':
+			j := i + 1
+			for j < len(expr) && isIdentChar(rune(expr[j])) {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("invalid placeholder at position %d", i)
+			}
+			kind := tokName
+			if c == ':' {
+				kind = tokValue
+			}
+			toks = append(toks, token{kind: kind, text: expr[i:j]})
+			i = j
+		case isIdentChar(c):
+			j := i
+			for j < len(expr) && isIdentChar(rune(expr[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: expr[i:j]})
+			i = j
+		case c == '<' || c == '>':
+			if i+1 < len(expr) && (expr[i+1] == '=' || (c == '<' && expr[i+1] == '>')) {
+				toks = append(toks, token{kind: tokPunct, text: expr[i : i+2]})
+				i += 2
+				continue
+			}
+			toks = append(toks, token{kind: tokPunct, text: string(c)})
+			i++
+		case strings.ContainsRune("()=,.+-[]", c):
+			toks = append(toks, token{kind: tokPunct, text: string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+func isIdentChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// --- AST ---
+
+// operand is a value-producing node in an expression.
+type operand interface {
+	eval(item map[string]types.AttributeValue) (types.AttributeValue, bool, error)
+}
+
+// condition is a boolean node in an expression.
+type condition interface {
+	test(item map[string]types.AttributeValue) (bool, error)
+}
+
+type pathOperand struct{ segments []string }
+
+func (p pathOperand) eval(item map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+	v, ok := resolvePath(item, p.segments)
+	return v, ok, nil
+}
+
+func (p pathOperand) String() string { return strings.Join(p.segments, ".") }
+
+type valueOperand struct{ value types.AttributeValue }
+
+func (v valueOperand) eval(map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+	return v.value, true, nil
+}
+
+type sizeOperand struct{ path pathOperand }
+
+func (s sizeOperand) eval(item map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+	v, ok := resolvePath(item, s.path.segments)
+	if !ok {
+		return nil, false, nil
+	}
+	var n int
+	switch tv := v.(type) {
+	case *types.AttributeValueMemberS:
+		n = len(tv.Value)
+	case *types.AttributeValueMemberB:
+		n = len(tv.Value)
+	case *types.AttributeValueMemberSS:
+		n = len(tv.Value)
+	case *types.AttributeValueMemberNS:
+		n = len(tv.Value)
+	case *types.AttributeValueMemberBS:
+		n = len(tv.Value)
+	case *types.AttributeValueMemberL:
+		n = len(tv.Value)
+	case *types.AttributeValueMemberM:
+		n = len(tv.Value)
+	default:
+		return nil, false, nil
+	}
+	return &types.AttributeValueMemberN{Value: fmt.Sprint(n)}, true, nil
+}
+
+type ifNotExistsOperand struct {
+	path     pathOperand
+	fallback operand
+}
+
+func (o ifNotExistsOperand) eval(item map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+	if v, ok := resolvePath(item, o.path.segments); ok {
+		return v, true, nil
+	}
+	return o.fallback.eval(item)
+}
+
+type listAppendOperand struct{ left, right operand }
+
+func (o listAppendOperand) eval(item map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+	l, err := mustEval(o.left, item)
+	if err != nil {
+		return nil, false, err
+	}
+	r, err := mustEval(o.right, item)
+	if err != nil {
+		return nil, false, err
+	}
+	ll, lok := l.(*types.AttributeValueMemberL)
+	rl, rok := r.(*types.AttributeValueMemberL)
+	if !lok || !rok {
+		return nil, false, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator or function: list_append")
+	}
+	out := append(append([]types.AttributeValue{}, ll.Value...), rl.Value...)
+	return &types.AttributeValueMemberL{Value: out}, true, nil
+}
+
+type arithOperand struct {
+	op          string
+	left, right operand
+}
+
+func (o arithOperand) eval(item map[string]types.AttributeValue) (types.AttributeValue, bool, error) {
+	l, err := mustEval(o.left, item)
+	if err != nil {
+		return nil, false, err
+	}
+	r, err := mustEval(o.right, item)
+	if err != nil {
+		return nil, false, err
+	}
+	ln, lok := l.(*types.AttributeValueMemberN)
+	rn, rok := r.(*types.AttributeValueMemberN)
+	if !lok || !rok {
+		return nil, false, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: " + o.op)
+	}
+	a, aok := parseNumber(ln.Value)
+	b, bok := parseNumber(rn.Value)
+	if !aok || !bok {
+		return nil, false, validationError("Invalid number in arithmetic expression")
+	}
+	if o.op == "+" {
+		a.Add(a, b)
+	} else {
+		a.Sub(a, b)
+	}
+	return &types.AttributeValueMemberN{Value: formatNumber(a)}, true, nil
+}
+
+// mustEval evaluates an operand that has to resolve, as in update expressions.
+func mustEval(o operand, item map[string]types.AttributeValue) (types.AttributeValue, error) {
+	v, ok, err := o.eval(item)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, validationError("The provided expression refers to an attribute that does not exist in the item")
+	}
+	return v, nil
+}
+
+type andCond struct{ left, right condition }
+
+func (c andCond) test(item map[string]types.AttributeValue) (bool, error) {
+	l, err := c.left.test(item)
+	if err != nil || !l {
+		return false, err
+	}
+	return c.right.test(item)
+}
+
+type orCond struct{ left, right condition }
+
+func (c orCond) test(item map[string]types.AttributeValue) (bool, error) {
+	l, err := c.left.test(item)
+	if err != nil || l {
+		return l, err
+	}
+	return c.right.test(item)
+}
+
+type notCond struct{ inner condition }
+
+func (c notCond) test(item map[string]types.AttributeValue) (bool, error) {
+	v, err := c.inner.test(item)
+	return !v, err
+}
+
+type compareCond struct {
+	op          string
+	left, right operand
+}
+
+func (c compareCond) test(item map[string]types.AttributeValue) (bool, error) {
+	l, lok, err := c.left.eval(item)
+	if err != nil {
+		return false, err
+	}
+	r, rok, err := c.right.eval(item)
+	if err != nil {
+		return false, err
+	}
+	if !lok || !rok {
+		return false, nil
+	}
+	switch c.op {
+	case "=":
+		return equalValues(l, r), nil
+	case "<>":
+		return !equalValues(l, r), nil
+	}
+	cmp, ok := compareScalars(l, r)
+	if !ok {
+		return false, nil
+	}
+	switch c.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	case ">=":
+		return cmp >= 0, nil
+	}
+	return false, fmt.Errorf("unknown comparator %q", c.op)
+}
+
+type betweenCond struct{ value, low, high operand }
+
+func (c betweenCond) test(item map[string]types.AttributeValue) (bool, error) {
+	ge, err := compareCond{op: ">=", left: c.value, right: c.low}.test(item)
+	if err != nil || !ge {
+		return false, err
+	}
+	return compareCond{op: "<=", left: c.value, right: c.high}.test(item)
+}
+
+type inCond struct {
+	value   operand
+	choices []operand
+}
+
+func (c inCond) test(item map[string]types.AttributeValue) (bool, error) {
+	for _, choice := range c.choices {
+		eq, err := compareCond{op: "=", left: c.value, right: choice}.test(item)
+		if err != nil || eq {
+			return eq, err
+		}
+	}
+	return false, nil
+}
+
+type funcCond struct {
+	name string
+	args []operand
+}
+
+func (c funcCond) test(item map[string]types.AttributeValue) (bool, error) {
+	switch c.name {
+	case "attribute_exists", "attribute_not_exists":
+		_, ok, err := c.args[0].eval(item)
+		if err != nil {
+			return false, err
+		}
+		return ok == (c.name == "attribute_exists"), nil
+	case "attribute_type":
+		v, ok, err := c.args[0].eval(item)
+		if err != nil || !ok {
+			return false, err
+		}
+		want, _, err := c.args[1].eval(item)
+		if err != nil {
+			return false, err
+		}
+		ws, isS := want.(*types.AttributeValueMemberS)
+		return isS && ws.Value == typeName(v), nil
+	case "begins_with":
+		v, ok, err := c.args[0].eval(item)
+		if err != nil || !ok {
+			return false, err
+		}
+		prefix, pok, err := c.args[1].eval(item)
+		if err != nil || !pok {
+			return false, err
+		}
+		switch tv := v.(type) {
+		case *types.AttributeValueMemberS:
+			ps, isS := prefix.(*types.AttributeValueMemberS)
+			return isS && strings.HasPrefix(tv.Value, ps.Value), nil
+		case *types.AttributeValueMemberB:
+			pb, isB := prefix.(*types.AttributeValueMemberB)
+			return isB && strings.HasPrefix(string(tv.Value), string(pb.Value)), nil
+		}
+		return false, nil
+	case "contains":
+		return containsValue(c, item)
+	}
+	return false, fmt.Errorf("unknown function %q", c.name)
+}
+
+func containsValue(c funcCond, item map[string]types.AttributeValue) (bool, error) {
+	v, ok, err := c.args[0].eval(item)
+	if err != nil || !ok {
+		return false, err
+	}
+	needle, nok, err := c.args[1].eval(item)
+	if err != nil || !nok {
+		return false, err
+	}
+	switch tv := v.(type) {
+	case *types.AttributeValueMemberS:
+		ns, isS := needle.(*types.AttributeValueMemberS)
+		return isS && strings.Contains(tv.Value, ns.Value), nil
+	case *types.AttributeValueMemberSS:
+		for _, s := range tv.Value {
+			if equalValues(&types.AttributeValueMemberS{Value: s}, needle) {
+				return true, nil
+			}
+		}
+	case *types.AttributeValueMemberNS:
+		for _, n := range tv.Value {
+			if equalValues(&types.AttributeValueMemberN{Value: n}, needle) {
+				return true, nil
+			}
+		}
+	case *types.AttributeValueMemberL:
+		for _, e := range tv.Value {
+			if equalValues(e, needle) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// resolvePath walks a (possibly nested) map path in an item.
+func resolvePath(item map[string]types.AttributeValue, segments []string) (types.AttributeValue, bool) {
+	var cur types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	for _, seg := range segments {
+		m, ok := cur.(*types.AttributeValueMemberM)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = m.Value[seg]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// --- Parser ---
+
+// placeholders resolves expression attribute names and values and records
+// which ones were referenced, so unused placeholders can be rejected the way
+// DynamoDB does.
+type placeholders struct {
+	names      map[string]string
+	values     map[string]types.AttributeValue
+	usedNames  map[string]bool
+	usedValues map[string]bool
+}
+
+func newPlaceholders(names map[string]string, values map[string]types.AttributeValue) *placeholders {
+	return &placeholders{
+		names:      names,
+		values:     values,
+		usedNames:  map[string]bool{},
+		usedValues: map[string]bool{},
+	}
+}
+
+// checkUnused returns a validation error for placeholders never referenced.
+func (p *placeholders) checkUnused() error {
+	for name := range p.names {
+		if !p.usedNames[name] {
+			return validationError("Value provided in ExpressionAttributeNames unused in expressions: keys: {" + name + "}")
+		}
+	}
+	for value := range p.values {
+		if !p.usedValues[value] {
+			return validationError("Value provided in ExpressionAttributeValues unused in expressions: keys: {" + value + "}")
+		}
+	}
+	return nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+	ph   *placeholders
+}
+
+func newParser(expr string, ph *placeholders) (*parser, error) {
+	toks, err := tokenize(expr)
+	if err != nil {
+		return nil, validationError("Invalid expression: " + err.Error())
+	}
+	return &parser{toks: toks, ph: ph}, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isKeyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *parser) isPunct(s string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.errorf("expected %q", s)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	t := p.peek()
+	near := t.text
+	if t.kind == tokEOF {
+		near = "<EOF>"
+	}
+	return validationError(fmt.Sprintf("Invalid expression: %s near %q", fmt.Sprintf(format, args...), near))
+}
+
+// parseCondition parses a complete condition/filter/key-condition expression.
+func parseCondition(expr string, ph *placeholders) (condition, error) {
+	p, err := newParser(expr, ph)
+	if err != nil {
+		return nil, err
+	}
+	c, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.errorf("unexpected token")
+	}
+	return c, nil
+}
+
+func (p *parser) parseOr() (condition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCond{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (condition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCond{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (condition, error) {
+	if p.isKeyword("NOT") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCond{inner: inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+var conditionFuncs = map[string]int{
+	"attribute_exists":     1,
+	"attribute_not_exists": 1,
+	"attribute_type":       2,
+	"begins_with":          2,
+	"contains":             2,
+}
+
+func (p *parser) parsePrimary() (condition, error) {
+	if p.isPunct("(") {
+		p.next()
+		c, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return c, p.expectPunct(")")
+	}
+
+	if t := p.peek(); t.kind == tokIdent {
+		if arity, ok := conditionFuncs[strings.ToLower(t.text)]; ok && p.toks[p.pos+1].text == "(" {
+			p.next()
+			p.next()
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			if len(args) != arity {
+				return nil, p.errorf("function %s expects %d arguments", t.text, arity)
+			}
+			if _, isPath := args[0].(pathOperand); !isPath {
+				return nil, p.errorf("function %s requires an attribute path", t.text)
+			}
+			return funcCond{name: strings.ToLower(t.text), args: args}, nil
+		}
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.isKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword("AND") {
+			return nil, p.errorf("expected AND in BETWEEN")
+		}
+		p.next()
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return betweenCond{value: left, low: low, high: high}, nil
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expectPunct("("); err != nil {
+			return nil, err
+		}
+		choices, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		return inCond{value: left, choices: choices}, nil
+	}
+
+	t := p.peek()
+	if t.kind == tokPunct {
+		switch t.text {
+		case "=", "<>", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return compareCond{op: t.text, left: left, right: right}, nil
+		}
+	}
+	return nil, p.errorf("expected comparator")
+}
+
+// parseArgs parses a comma separated list of operands up to and including ")".
+func (p *parser) parseArgs() ([]operand, error) {
+	var args []operand
+	for {
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		return args, p.expectPunct(")")
+	}
+}
+
+// parseOperand parses a path, a value placeholder, or an operand function.
+func (p *parser) parseOperand() (operand, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokValue:
+		p.next()
+		v, ok := p.ph.values[t.text]
+		if !ok {
+			return nil, validationError("An expression attribute value used in expression is not defined; attribute value: " + t.text)
+		}
+		p.ph.usedValues[t.text] = true
+		return valueOperand{value: v}, nil
+	case tokIdent:
+		if p.toks[p.pos+1].text == "(" {
+			return p.parseOperandFunc()
+		}
+		return p.parsePath()
+	case tokName:
+		return p.parsePath()
+	}
+	return nil, p.errorf("expected operand")
+}
+
+func (p *parser) parseOperandFunc() (operand, error) {
+	name := strings.ToLower(p.next().text)
+	p.next() // "("
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "size":
+		path, ok := args[0].(pathOperand)
+		if len(args) != 1 || !ok {
+			return nil, p.errorf("size expects one attribute path")
+		}
+		return sizeOperand{path: path}, nil
+	case "if_not_exists":
+		path, ok := args[0].(pathOperand)
+		if len(args) != 2 || !ok {
+			return nil, p.errorf("if_not_exists expects an attribute path and a value")
+		}
+		return ifNotExistsOperand{path: path, fallback: args[1]}, nil
+	case "list_append":
+		if len(args) != 2 {
+			return nil, p.errorf("list_append expects two operands")
+		}
+		return listAppendOperand{left: args[0], right: args[1]}, nil
+	}
+	return nil, p.errorf("unknown function %s", name)
+}
+
+func (p *parser) parsePath() (pathOperand, error) {
+	var segments []string
+	for {
+		t := p.next()
+		switch t.kind {
+		case tokName:
+			name, ok := p.ph.names[t.text]
+			if !ok {
+				return pathOperand{}, validationError("An expression attribute name used in the document path is not defined; attribute name: " + t.text)
+			}
+			p.ph.usedNames[t.text] = true
+			segments = append(segments, name)
+		case tokIdent:
+			segments = append(segments, t.text)
+		default:
+			p.pos--
+			return pathOperand{}, p.errorf("expected attribute name")
+		}
+		if !p.isPunct(".") {
+			return pathOperand{segments: segments}, nil
+		}
+		p.next()
+	}
+}
+
+// --- Update expressions ---
+
+type updateAction struct {
+	kind  string // SET, REMOVE, ADD, DELETE
+	path  pathOperand
+	value operand
+}
+
+// parseUpdate parses an update expression into its actions.
+func parseUpdate(expr string, ph *placeholders) ([]updateAction, error) {
+	p, err := newParser(expr, ph)
+	if err != nil {
+		return nil, err
+	}
+	var actions []updateAction
+	for p.peek().kind != tokEOF {
+		t := p.next()
+		kind := strings.ToUpper(t.text)
+		if t.kind != tokIdent || (kind != "SET" && kind != "REMOVE" && kind != "ADD" && kind != "DELETE") {
+			p.pos--
+			return nil, p.errorf("expected SET, REMOVE, ADD or DELETE")
+		}
+		for {
+			path, err := p.parsePath()
+			if err != nil {
+				return nil, err
+			}
+			action := updateAction{kind: kind, path: path}
+			switch kind {
+			case "SET":
+				if err := p.expectPunct("="); err != nil {
+					return nil, err
+				}
+				if action.value, err = p.parseSetValue(); err != nil {
+					return nil, err
+				}
+			case "ADD", "DELETE":
+				if action.value, err = p.parseOperand(); err != nil {
+					return nil, err
+				}
+			}
+			actions = append(actions, action)
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+	}
+	if len(actions) == 0 {
+		return nil, validationError("Invalid UpdateExpression: The expression can not be empty")
+	}
+	return actions, nil
+}
+
+func (p *parser) parseSetValue() (operand, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.isPunct("+") || p.isPunct("-") {
+		op := p.next().text
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return arithOperand{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+// applyUpdate applies parsed actions to a copy of item and returns it.
+// Operands are evaluated against the original item, as DynamoDB does.
+func applyUpdate(item map[string]types.AttributeValue, actions []updateAction) (map[string]types.AttributeValue, error) {
+	out := copyItem(item)
+	if out == nil {
+		out = map[string]types.AttributeValue{}
+	}
+	for _, a := range actions {
+		switch a.kind {
+		case "SET":
+			v, err := mustEval(a.value, item)
+			if err != nil {
+				return nil, err
+			}
+			if err := setPath(out, a.path.segments, copyValue(v)); err != nil {
+				return nil, err
+			}
+		case "REMOVE":
+			removePath(out, a.path.segments)
+		case "ADD":
+			v, err := mustEval(a.value, item)
+			if err != nil {
+				return nil, err
+			}
+			cur, exists := resolvePath(item, a.path.segments)
+			next, err := addValues(cur, exists, v)
+			if err != nil {
+				return nil, err
+			}
+			if err := setPath(out, a.path.segments, next); err != nil {
+				return nil, err
+			}
+		case "DELETE":
+			v, err := mustEval(a.value, item)
+			if err != nil {
+				return nil, err
+			}
+			cur, exists := resolvePath(item, a.path.segments)
+			if !exists {
+				continue
+			}
+			next, empty, err := deleteFromSet(cur, v)
+			if err != nil {
+				return nil, err
+			}
+			if empty {
+				removePath(out, a.path.segments)
+			} else if err := setPath(out, a.path.segments, next); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out, nil
+}
+
+func setPath(item map[string]types.AttributeValue, segments []string, v types.AttributeValue) error {
+	m := item
+	for _, seg := range segments[:len(segments)-1] {
+		child, ok := m[seg].(*types.AttributeValueMemberM)
+		if !ok {
+			return validationError("The document path provided in the update expression is invalid for update")
+		}
+		m = child.Value
+	}
+	m[segments[len(segments)-1]] = v
+	return nil
+}
+
+func removePath(item map[string]types.AttributeValue, segments []string) {
+	m := item
+	for _, seg := range segments[:len(segments)-1] {
+		child, ok := m[seg].(*types.AttributeValueMemberM)
+		if !ok {
+			return
+		}
+		m = child.Value
+	}
+	delete(m, segments[len(segments)-1])
+}
+
+func addValues(cur types.AttributeValue, exists bool, v types.AttributeValue) (types.AttributeValue, error) {
+	if !exists {
+		switch v.(type) {
+		case *types.AttributeValueMemberN, *types.AttributeValueMemberSS,
+			*types.AttributeValueMemberNS, *types.AttributeValueMemberBS:
+			return copyValue(v), nil
+		}
+		return nil, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD")
+	}
+	switch cv := cur.(type) {
+	case *types.AttributeValueMemberN:
+		return arithOperand{op: "+", left: valueOperand{cv}, right: valueOperand{v}}.evalValue()
+	case *types.AttributeValueMemberSS:
+		if add, ok := v.(*types.AttributeValueMemberSS); ok {
+			return &types.AttributeValueMemberSS{Value: unionStrings(cv.Value, add.Value)}, nil
+		}
+	case *types.AttributeValueMemberNS:
+		if add, ok := v.(*types.AttributeValueMemberNS); ok {
+			return &types.AttributeValueMemberNS{Value: unionStrings(cv.Value, add.Value)}, nil
+		}
+	}
+	return nil, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD")
+}
+
+func (o arithOperand) evalValue() (types.AttributeValue, error) {
+	v, _, err := o.eval(nil)
+	return v, err
+}
+
+func unionStrings(a, b []string) []string {
+	out := append([]string{}, a...)
+	seen := make(map[string]bool, len(a))
+	for _, s := range a {
+		seen[s] = true
+	}
+	for _, s := range b {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func deleteFromSet(cur, v types.AttributeValue) (next types.AttributeValue, empty bool, err error) {
+	subtract := func(a, b []string) []string {
+		drop := make(map[string]bool, len(b))
+		for _, s := range b {
+			drop[s] = true
+		}
+		var out []string
+		for _, s := range a {
+			if !drop[s] {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	switch cv := cur.(type) {
+	case *types.AttributeValueMemberSS:
+		if del, ok := v.(*types.AttributeValueMemberSS); ok {
+			rest := subtract(cv.Value, del.Value)
+			return &types.AttributeValueMemberSS{Value: rest}, len(rest) == 0, nil
+		}
+	case *types.AttributeValueMemberNS:
+		if del, ok := v.(*types.AttributeValueMemberNS); ok {
+			rest := subtract(cv.Value, del.Value)
+			return &types.AttributeValueMemberNS{Value: rest}, len(rest) == 0, nil
+		}
+	}
+	return nil, false, validationError("Invalid UpdateExpression: Incorrect operand type for operator or function; operator: DELETE")
+}
@@ -0,0 +1,165 @@
+package storetest
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Query implements the DynamoDB Query operation.
+//
+// As in DynamoDB, Limit bounds the number of items evaluated before the
+// filter is applied, so a page may contain fewer items than Limit while
+// LastEvaluatedKey indicates that more items remain.
+func (db *DB) Query(ctx context.Context, params *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := db.begin(ctx, "Query", params); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	pkName, skName, err := t.queryKeys(params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+	if params.KeyConditionExpression == nil || *params.KeyConditionExpression == "" {
+		return nil, validationError("Either the KeyConditions or KeyConditionExpression parameter must be specified in the request")
+	}
+
+	ph := newPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	keyCond, err := parseCondition(*params.KeyConditionExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := parseOptionalCondition(params.FilterExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := parseProjection(params.ProjectionExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	if err := ph.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	var candidates []map[string]types.AttributeValue
+	for _, item := range t.items {
+		if _, ok := item[pkName]; !ok {
+			continue
+		}
+		if _, ok := item[skName]; skName != "" && !ok {
+			continue
+		}
+		match, err := keyCond.test(item)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			candidates = append(candidates, item)
+		}
+	}
+
+	forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+	order := t.itemOrder(skName, forward)
+	sort.Slice(candidates, func(i, j int) bool { return order(candidates[i], candidates[j]) < 0 })
+
+	page, lastKey, err := db.paginate(t, candidates, order, params.ExclusiveStartKey, params.Limit, pkName, skName)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.QueryOutput{LastEvaluatedKey: lastKey, ScannedCount: int32(len(page))}
+	for _, item := range page {
+		ok, err := testCondition(filter, item)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		out.Count++
+		if params.Select != types.SelectCount {
+			out.Items = append(out.Items, project(item, projection))
+		}
+	}
+	return out, nil
+}
+
+// queryKeys returns the partition and sort key names for the table or index.
+func (t *table) queryKeys(indexName *string) (pk, sk string, err error) {
+	if indexName == nil || *indexName == "" {
+		return t.schema.PartitionKey, t.schema.SortKey, nil
+	}
+	for _, idx := range t.schema.Indexes {
+		if idx.Name == *indexName {
+			return idx.PartitionKey, idx.SortKey, nil
+		}
+	}
+	return "", "", validationError("The table does not have the specified index: " + *indexName)
+}
+
+// itemOrder returns a comparison function ordering items by the given sort
+// key and then by primary key, reversed when forward is false.
+func (t *table) itemOrder(skName string, forward bool) func(a, b map[string]types.AttributeValue) int {
+	return func(a, b map[string]types.AttributeValue) int {
+		cmp := 0
+		if skName != "" {
+			cmp, _ = compareScalars(a[skName], b[skName])
+		}
+		if cmp == 0 {
+			ak, _ := t.keyOf(a)
+			bk, _ := t.keyOf(b)
+			cmp = strings.Compare(ak, bk)
+		}
+		if !forward {
+			cmp = -cmp
+		}
+		return cmp
+	}
+}
+
+// paginate applies ExclusiveStartKey and Limit (or the DB page size) to
+// ordered items, returning the page and its LastEvaluatedKey, if any.
+func (db *DB) paginate(t *table, items []map[string]types.AttributeValue, order func(a, b map[string]types.AttributeValue) int, startKey map[string]types.AttributeValue, limit *int32, indexKeys ...string) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	if startKey != nil {
+		if _, err := t.keyOf(startKey); err != nil {
+			return nil, nil, validationError("The provided starting key is invalid: " + err.Error())
+		}
+		start := sort.Search(len(items), func(i int) bool { return order(items[i], startKey) > 0 })
+		items = items[start:]
+	}
+
+	// As in DynamoDB, reaching Limit returns a LastEvaluatedKey even when no
+	// items remain, so the following page may be empty.
+	pageLimit, limitReached := db.pageSize, false
+	if limit != nil {
+		if *limit < 1 {
+			return nil, nil, validationError("Limit must be greater than or equal to 1")
+		}
+		if pageLimit == 0 || int(*limit) < pageLimit {
+			pageLimit = int(*limit)
+		}
+		limitReached = len(items) >= int(*limit)
+	}
+	if pageLimit == 0 || (len(items) <= pageLimit && !limitReached) {
+		return items, nil, nil
+	}
+
+	page := items[:pageLimit]
+	last := page[len(page)-1]
+	lastKey := t.keyAttrs(last)
+	for _, name := range indexKeys {
+		if v, ok := last[name]; ok && name != "" {
+			lastKey[name] = copyValue(v)
+		}
+	}
+	return page, lastKey, nil
+}
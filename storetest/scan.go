@@ -0,0 +1,101 @@
+package storetest
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Scan implements the DynamoDB Scan operation, including parallel scans.
+//
+// Items are assigned to segments by a hash of their primary key, so each
+// item belongs to exactly one segment for a given TotalSegments. Limit
+// bounds the items evaluated before the filter, as in Query.
+func (db *DB) Scan(ctx context.Context, params *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := db.begin(ctx, "Scan", params); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(params.TableName)
+	if err != nil {
+		return nil, err
+	}
+	pkName, skName, err := t.queryKeys(params.IndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	segment, total := int32(0), int32(1)
+	if params.Segment != nil || params.TotalSegments != nil {
+		if params.Segment == nil || params.TotalSegments == nil {
+			return nil, validationError("The TotalSegments parameter is required but was not present in the request when Segment parameter is present")
+		}
+		segment, total = *params.Segment, *params.TotalSegments
+		if total < 1 || total > 1000000 || segment < 0 || segment >= total {
+			return nil, validationError("The Segment parameter is zero-based and must be less than parameter TotalSegments")
+		}
+	}
+
+	ph := newPlaceholders(params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	filter, err := parseOptionalCondition(params.FilterExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	projection, err := parseProjection(params.ProjectionExpression, ph)
+	if err != nil {
+		return nil, err
+	}
+	if err := ph.checkUnused(); err != nil {
+		return nil, err
+	}
+
+	var candidates []map[string]types.AttributeValue
+	for key, item := range t.items {
+		if _, ok := item[pkName]; !ok {
+			continue
+		}
+		if _, ok := item[skName]; skName != "" && !ok {
+			continue
+		}
+		if total > 1 && segmentOf(key, total) != segment {
+			continue
+		}
+		candidates = append(candidates, item)
+	}
+
+	order := t.itemOrder("", true)
+	sort.Slice(candidates, func(i, j int) bool { return order(candidates[i], candidates[j]) < 0 })
+
+	page, lastKey, err := db.paginate(t, candidates, order, params.ExclusiveStartKey, params.Limit, pkName, skName)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &dynamodb.ScanOutput{LastEvaluatedKey: lastKey, ScannedCount: int32(len(page))}
+	for _, item := range page {
+		ok, err := testCondition(filter, item)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		out.Count++
+		if params.Select != types.SelectCount {
+			out.Items = append(out.Items, project(item, projection))
+		}
+	}
+	return out, nil
+}
+
+// segmentOf returns the parallel scan segment for a canonical item key.
+func segmentOf(key string, total int32) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int32(h.Sum32() % uint32(total))
+}
@@ -0,0 +1,47 @@
+package storetest
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// toStreamImage converts an item to the attribute representation used in
+// Lambda DynamoDB stream events.
+func toStreamImage(item map[string]types.AttributeValue) map[string]events.DynamoDBAttributeValue {
+	if item == nil {
+		return nil
+	}
+	out := make(map[string]events.DynamoDBAttributeValue, len(item))
+	for k, v := range item {
+		out[k] = toStreamValue(v)
+	}
+	return out
+}
+
+func toStreamValue(v types.AttributeValue) events.DynamoDBAttributeValue {
+	switch tv := v.(type) {
+	case *types.AttributeValueMemberS:
+		return events.NewStringAttribute(tv.Value)
+	case *types.AttributeValueMemberN:
+		return events.NewNumberAttribute(tv.Value)
+	case *types.AttributeValueMemberB:
+		return events.NewBinaryAttribute(tv.Value)
+	case *types.AttributeValueMemberBOOL:
+		return events.NewBooleanAttribute(tv.Value)
+	case *types.AttributeValueMemberSS:
+		return events.NewStringSetAttribute(tv.Value)
+	case *types.AttributeValueMemberNS:
+		return events.NewNumberSetAttribute(tv.Value)
+	case *types.AttributeValueMemberBS:
+		return events.NewBinarySetAttribute(tv.Value)
+	case *types.AttributeValueMemberL:
+		list := make([]events.DynamoDBAttributeValue, len(tv.Value))
+		for i, e := range tv.Value {
+			list[i] = toStreamValue(e)
+		}
+		return events.NewListAttribute(list)
+	case *types.AttributeValueMemberM:
+		return events.NewMapAttribute(toStreamImage(tv.Value))
+	}
+	return events.NewNullAttribute()
+}
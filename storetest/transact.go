@@ -0,0 +1,184 @@
+package storetest
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// txOp is one parsed TransactWriteItem.
+type txOp struct {
+	t         *table
+	key       string
+	keyAttrs  map[string]types.AttributeValue
+	cond      condition
+	returnOld bool
+
+	// Exactly one of the following describes the write, if any.
+	put     map[string]types.AttributeValue
+	update  []updateAction
+	remove  bool
+	newItem map[string]types.AttributeValue
+}
+
+// TransactWriteItems implements the DynamoDB TransactWriteItems operation.
+//
+// All conditions are evaluated against the state before the transaction.
+// If any fail, nothing is written and a TransactionCanceledException is
+// returned whose CancellationReasons line up index-for-index with the
+// request: "ConditionalCheckFailed" for failed items and "None" otherwise.
+func (db *DB) TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := db.begin(ctx, "TransactWriteItems", params); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if len(params.TransactItems) == 0 || len(params.TransactItems) > maxTransactItems {
+		return nil, validationError("Member must have length less than or equal to 100 and greater than or equal to 1: TransactItems")
+	}
+
+	ops := make([]*txOp, len(params.TransactItems))
+	seen := make(map[string]bool, len(params.TransactItems))
+	for i := range params.TransactItems {
+		op, err := db.parseTxItem(&params.TransactItems[i])
+		if err != nil {
+			return nil, err
+		}
+		id := op.t.schema.Name + "/" + op.key
+		if seen[id] {
+			return nil, validationError("Transaction request cannot include multiple operations on one item")
+		}
+		seen[id] = true
+		ops[i] = op
+	}
+
+	reasons := make([]types.CancellationReason, len(ops))
+	failed := false
+	for i, op := range ops {
+		old := op.t.items[op.key]
+		ok, err := testCondition(op.cond, old)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			failed = true
+			reasons[i] = types.CancellationReason{
+				Code:    aws.String("ConditionalCheckFailed"),
+				Message: aws.String("The conditional request failed"),
+			}
+			if op.returnOld {
+				reasons[i].Item = copyItem(old)
+			}
+			continue
+		}
+		reasons[i] = types.CancellationReason{Code: aws.String("None")}
+
+		switch {
+		case op.put != nil:
+			op.newItem = op.put
+		case op.update != nil:
+			updated, err := op.t.applyUpdate(old, op.keyAttrs, op.update)
+			if err != nil {
+				return nil, err
+			}
+			op.newItem = updated
+		}
+	}
+
+	if failed {
+		codes := make([]string, len(reasons))
+		for i, r := range reasons {
+			codes[i] = *r.Code
+		}
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(codes, ", ") + "]"),
+			CancellationReasons: reasons,
+		}
+	}
+
+	for _, op := range ops {
+		old := op.t.items[op.key]
+		switch {
+		case op.newItem != nil:
+			db.write(op.t, op.key, old, op.newItem, false)
+		case op.remove && old != nil:
+			db.write(op.t, op.key, old, nil, false)
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// parseTxItem validates one TransactWriteItem and parses its expressions.
+func (db *DB) parseTxItem(item *types.TransactWriteItem) (*txOp, error) {
+	var (
+		tableName *string
+		key       map[string]types.AttributeValue
+		condExpr  *string
+		names     map[string]string
+		values    map[string]types.AttributeValue
+		rv        types.ReturnValuesOnConditionCheckFailure
+		op        = &txOp{}
+		count     int
+	)
+	if c := item.ConditionCheck; c != nil {
+		count++
+		tableName, key, condExpr, names, values, rv = c.TableName, c.Key, c.ConditionExpression, c.ExpressionAttributeNames, c.ExpressionAttributeValues, c.ReturnValuesOnConditionCheckFailure
+		if condExpr == nil || *condExpr == "" {
+			return nil, validationError("ConditionExpression is required for ConditionCheck")
+		}
+	}
+	if p := item.Put; p != nil {
+		count++
+		tableName, key, condExpr, names, values, rv = p.TableName, p.Item, p.ConditionExpression, p.ExpressionAttributeNames, p.ExpressionAttributeValues, p.ReturnValuesOnConditionCheckFailure
+		op.put = p.Item
+	}
+	if u := item.Update; u != nil {
+		count++
+		tableName, key, condExpr, names, values, rv = u.TableName, u.Key, u.ConditionExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues, u.ReturnValuesOnConditionCheckFailure
+	}
+	if d := item.Delete; d != nil {
+		count++
+		tableName, key, condExpr, names, values, rv = d.TableName, d.Key, d.ConditionExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues, d.ReturnValuesOnConditionCheckFailure
+		op.remove = true
+	}
+	if count != 1 {
+		return nil, validationError("TransactItems can only contain one of Check, Put, Update or Delete")
+	}
+
+	t, err := db.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	op.t = t
+	if op.put != nil {
+		op.key, err = t.keyOf(key)
+	} else {
+		op.key, err = t.keyFromKey(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	op.keyAttrs = t.keyAttrs(key)
+	op.returnOld = rv == types.ReturnValuesOnConditionCheckFailureAllOld
+
+	ph := newPlaceholders(names, values)
+	if u := item.Update; u != nil {
+		if u.UpdateExpression == nil {
+			return nil, validationError("UpdateExpression is required")
+		}
+		if op.update, err = parseUpdate(*u.UpdateExpression, ph); err != nil {
+			return nil, err
+		}
+	}
+	if op.cond, err = parseOptionalCondition(condExpr, ph); err != nil {
+		return nil, err
+	}
+	if err := ph.checkUnused(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
@@ -0,0 +1,219 @@
+package storetest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// parseNumber parses a DynamoDB number string exactly.
+func parseNumber(s string) (*big.Rat, bool) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	return r, ok
+}
+
+// formatNumber renders a number the way DynamoDB returns it (no trailing zeros).
+func formatNumber(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := strings.TrimRight(r.FloatString(38), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// compareScalars orders two scalar values of the same type (S, N or B).
+// ok is false when the values are not comparable.
+func compareScalars(a, b types.AttributeValue) (cmp int, ok bool) {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS:
+		bv, isS := b.(*types.AttributeValueMemberS)
+		if !isS {
+			return 0, false
+		}
+		return strings.Compare(av.Value, bv.Value), true
+	case *types.AttributeValueMemberN:
+		bv, isN := b.(*types.AttributeValueMemberN)
+		if !isN {
+			return 0, false
+		}
+		ar, aok := parseNumber(av.Value)
+		br, bok := parseNumber(bv.Value)
+		if !aok || !bok {
+			return 0, false
+		}
+		return ar.Cmp(br), true
+	case *types.AttributeValueMemberB:
+		bv, isB := b.(*types.AttributeValueMemberB)
+		if !isB {
+			return 0, false
+		}
+		return bytes.Compare(av.Value, bv.Value), true
+	}
+	return 0, false
+}
+
+// equalValues reports whether two attribute values are equal, comparing
+// numbers numerically and sets without regard to order.
+func equalValues(a, b types.AttributeValue) bool {
+	switch av := a.(type) {
+	case *types.AttributeValueMemberS, *types.AttributeValueMemberN, *types.AttributeValueMemberB:
+		cmp, ok := compareScalars(a, b)
+		return ok && cmp == 0
+	case *types.AttributeValueMemberBOOL:
+		bv, ok := b.(*types.AttributeValueMemberBOOL)
+		return ok && av.Value == bv.Value
+	case *types.AttributeValueMemberNULL:
+		_, ok := b.(*types.AttributeValueMemberNULL)
+		return ok
+	case *types.AttributeValueMemberSS:
+		bv, ok := b.(*types.AttributeValueMemberSS)
+		return ok && equalSets(av.Value, bv.Value, func(s string) string { return s })
+	case *types.AttributeValueMemberNS:
+		bv, ok := b.(*types.AttributeValueMemberNS)
+		return ok && equalSets(av.Value, bv.Value, normalizeNumber)
+	case *types.AttributeValueMemberBS:
+		bv, ok := b.(*types.AttributeValueMemberBS)
+		enc := base64.StdEncoding.EncodeToString
+		return ok && equalSets(av.Value, bv.Value, enc)
+	case *types.AttributeValueMemberL:
+		bv, ok := b.(*types.AttributeValueMemberL)
+		if !ok || len(av.Value) != len(bv.Value) {
+			return false
+		}
+		for i := range av.Value {
+			if !equalValues(av.Value[i], bv.Value[i]) {
+				return false
+			}
+		}
+		return true
+	case *types.AttributeValueMemberM:
+		bv, ok := b.(*types.AttributeValueMemberM)
+		if !ok || len(av.Value) != len(bv.Value) {
+			return false
+		}
+		for k, v := range av.Value {
+			other, exists := bv.Value[k]
+			if !exists || !equalValues(v, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func equalSets[T any](a, b []T, key func(T) string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int, len(a))
+	for _, v := range a {
+		seen[key(v)]++
+	}
+	for _, v := range b {
+		k := key(v)
+		if seen[k] == 0 {
+			return false
+		}
+		seen[k]--
+	}
+	return true
+}
+
+func normalizeNumber(s string) string {
+	if r, ok := parseNumber(s); ok {
+		return formatNumber(r)
+	}
+	return s
+}
+
+// copyValue returns a deep copy of an attribute value so that stored items
+// never share memory with caller-owned inputs or outputs.
+func copyValue(v types.AttributeValue) types.AttributeValue {
+	switch tv := v.(type) {
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: tv.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: tv.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: bytes.Clone(tv.Value)}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: tv.Value}
+	case *types.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: tv.Value}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: append([]string(nil), tv.Value...)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: append([]string(nil), tv.Value...)}
+	case *types.AttributeValueMemberBS:
+		out := make([][]byte, len(tv.Value))
+		for i, b := range tv.Value {
+			out[i] = bytes.Clone(b)
+		}
+		return &types.AttributeValueMemberBS{Value: out}
+	case *types.AttributeValueMemberL:
+		out := make([]types.AttributeValue, len(tv.Value))
+		for i, e := range tv.Value {
+			out[i] = copyValue(e)
+		}
+		return &types.AttributeValueMemberL{Value: out}
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(tv.Value)}
+	}
+	return v
+}
+
+// copyItem deep-copies an item. A nil item stays nil.
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	out := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		out[k] = copyValue(v)
+	}
+	return out
+}
+
+// keyPart renders a key attribute value as a stable string.
+func keyPart(v types.AttributeValue) (string, bool) {
+	switch tv := v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S:" + tv.Value, true
+	case *types.AttributeValueMemberN:
+		return "N:" + normalizeNumber(tv.Value), true
+	case *types.AttributeValueMemberB:
+		return "B:" + base64.StdEncoding.EncodeToString(tv.Value), true
+	}
+	return "", false
+}
+
+// typeName returns the DynamoDB type descriptor of a value (S, N, M, ...).
+func typeName(v types.AttributeValue) string {
+	switch v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberM:
+		return "M"
+	}
+	return ""
+}
@@ -3,9 +3,15 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
@@ -13,21 +19,144 @@ import (
 	"github.com/jacentio/trellis/store"
 )
 
+// FailurePolicy decides what the handler does with a record when the TTL
+// cannot be set on one of its children, its relationship record or its
+// unique constraints, after retrying.
+type FailurePolicy int
+
+const (
+	// FailRecord fails the record with a *CascadeError, so Lambda retries
+	// it and eventually sends it to the DLQ.
+	FailRecord FailurePolicy = iota
+
+	// LogAndContinue logs each failure and reports the record as processed.
+	LogAndContinue
+)
+
+// Options configures a Handler.
+type Options struct {
+	// Logger receives the handler's logs.
+	// Default: slog.Default()
+	Logger *slog.Logger
+
+	// FailurePolicy applies to a record once a TTL write has failed all its
+	// retries.
+	// Default: FailRecord
+	FailurePolicy FailurePolicy
+
+	// MaxRetries is how many times a failed TTL write is retried in-process
+	// (negative = no retries).
+	// Default: 3
+	MaxRetries int
+
+	// RetryBaseDelay is the first retry delay; it doubles on each retry.
+	// Default: 50ms
+	RetryBaseDelay time.Duration
+
+	// RetryMaxDelay caps the retry delay.
+	// Default: 2s
+	RetryMaxDelay time.Duration
+
+	// Concurrency is how many children of a record have their TTL set in
+	// parallel.
+	// Default: 8
+	Concurrency int
+
+	// RateLimit caps the child TTL writes per second made by the handler,
+	// retries included, so a large cascade leaves capacity for regular
+	// traffic (0 = unlimited). Each Lambda instance has its own limit.
+	// Default: 0
+	RateLimit float64
+
+	// RateBurst is how many child TTL writes may be made at once before
+	// RateLimit applies.
+	// Default: Concurrency
+	RateBurst int
+
+	// ChunkSize is how many relationship records of a record's children are
+	// read and processed at a time; a cascade can checkpoint between chunks.
+	// Default: 1000
+	ChunkSize int
+
+	// MaxChildrenPerRecord caps the children processed for a record in one
+	// invocation (0 = unlimited). Once reached, the cascade checkpoints and
+	// continues in a later invocation. Like CheckpointMargin, it only
+	// applies with store.Config.CheckpointCascades.
+	// Default: 0
+	MaxChildrenPerRecord int
+
+	// CheckpointMargin is the time that must be left before the context's
+	// deadline to start another chunk; with less, the cascade checkpoints
+	// and continues in a later invocation (negative = never). It should
+	// cover processing a chunk and the rest of the batch. A fixed margin
+	// must be well below the function timeout: one longer than the time
+	// left checkpoints every record after its first chunk.
+	// Default: a quarter of the time left when a record starts
+	CheckpointMargin time.Duration
+
+	// OnCascadeComplete, if set, is called with the finished job when a
+	// cascade tracked with store.Config.TrackCascadeJobs has deleted its
+	// whole subtree. It runs at least once: if it returns an error, the
+	// record fails and the hook runs again on its retry.
+	OnCascadeComplete func(ctx context.Context, job *store.CascadeJob) error
+}
+
 // Handler processes DynamoDB stream events for cascade deletes.
 type Handler struct {
-	store  *store.Store
-	logger *slog.Logger
+	store   *store.Store
+	logger  *slog.Logger
+	opts    Options
+	limiter *rateLimiter
+
+	// cascadeTable is the store's cascade job table, whose records resume
+	// checkpointed cascades; checkpoints is whether the store records them.
+	cascadeTable string
+	checkpoints  bool
 }
 
-// NewHandler creates a new stream handler.
+// NewHandler creates a new stream handler with default options.
 func NewHandler(s *store.Store, logger *slog.Logger) *Handler {
-	if logger == nil {
-		logger = slog.Default()
+	return NewHandlerWithOptions(s, Options{Logger: logger})
+}
+
+// NewHandlerWithOptions creates a new stream handler.
+func NewHandlerWithOptions(s *store.Store, opts Options) *Handler {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.RetryBaseDelay <= 0 {
+		opts.RetryBaseDelay = 50 * time.Millisecond
+	}
+	if opts.RetryMaxDelay <= 0 {
+		opts.RetryMaxDelay = 2 * time.Second
 	}
-	return &Handler{
-		store:  s,
-		logger: logger,
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 8
 	}
+	if opts.RateBurst < 1 {
+		opts.RateBurst = opts.Concurrency
+	}
+	if opts.ChunkSize < 1 {
+		opts.ChunkSize = 1000
+	}
+	h := &Handler{
+		store:   s,
+		logger:  opts.Logger,
+		opts:    opts,
+		limiter: newRateLimiter(opts.RateLimit, opts.RateBurst),
+	}
+	if s != nil {
+		cfg := s.Config()
+		h.cascadeTable = cfg.CascadeJobTable
+		h.checkpoints = cfg.CheckpointCascades
+	}
+	return h
 }
 
 // HandleCascadeDelete processes DynamoDB stream events to propagate TTL to children.
@@ -45,8 +174,71 @@ func (h *Handler) HandleCascadeDelete(ctx context.Context, event events.DynamoDB
 	return nil
 }
 
+// HandleCascadeDeleteBatch processes DynamoDB stream events like
+// HandleCascadeDelete, but reports the records to retry instead of failing
+// the whole batch. It is designed to be used as an AWS Lambda handler for
+// an event source mapping with ReportBatchItemFailures enabled.
+//
+// Records are processed in order. When a record fails, the remaining
+// records from its event source are skipped and only the failed record's
+// sequence number is reported, so Lambda retries from it: ordering is
+// preserved and records processed before it are not retried. A Lambda batch
+// holds the records of a single shard, so this is the first failure per
+// shard.
+func (h *Handler) HandleCascadeDeleteBatch(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	var resp events.DynamoDBEventResponse
+	failed := make(map[string]bool)
+	for i := range event.Records {
+		record := &event.Records[i]
+		if failed[record.EventSourceArn] {
+			continue
+		}
+		if err := h.processRecord(ctx, record); err != nil {
+			h.logger.Error("failed to process record",
+				"eventID", record.EventID,
+				"sequenceNumber", record.Change.SequenceNumber,
+				"error", err,
+			)
+			failed[record.EventSourceArn] = true
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.DynamoDBBatchItemFailure{
+				ItemIdentifier: record.Change.SequenceNumber,
+			})
+		}
+	}
+	return resp, nil
+}
+
+// cascadeRecord is an entity whose deletion a record cascades to its
+// subtree.
+type cascadeRecord struct {
+	entityRef string
+	parentRef string
+	jobRef    string
+	uniquePKs []string
+	table     string
+	key       store.PK
+	ttl       int64
+
+	// source is the event source ARN of the record, the table comes from
+	// it for a new cascade.
+	source string
+
+	// cursor is where a resumed cascade continues from; empty for a new one.
+	cursor   string
+	resuming bool
+}
+
 // processRecord processes a single DynamoDB stream record.
 func (h *Handler) processRecord(ctx context.Context, record *events.DynamoDBEventRecord) error {
+	table := tableFromStreamARN(record.EventSourceArn)
+	if table != "" && table == h.cascadeTable {
+		c, err := h.resumedCascade(ctx, record)
+		if err != nil || c == nil {
+			return err
+		}
+		return h.cascade(ctx, c)
+	}
+
 	// Only process MODIFY events where TTL was added
 	if record.EventName != "MODIFY" {
 		return nil
@@ -55,74 +247,394 @@ func (h *Handler) processRecord(ctx context.Context, record *events.DynamoDBEven
 	oldTTL := getNumberAttr(record.Change.OldImage, "ttl")
 	newTTL := getNumberAttr(record.Change.NewImage, "ttl")
 
-	// Only process when TTL is newly set (was absent/0, now present)
+	// Process only when TTL is newly set (was absent/0, now present)
 	if oldTTL != 0 || newTTL == 0 {
 		return nil
 	}
 
-	entityRef := getStringAttr(record.Change.NewImage, "entity_ref")
-	parentRef := getStringAttr(record.Change.NewImage, "parent_ref")
-	uniquePKs := getStringListAttr(record.Change.NewImage, "_unique_pks")
+	return h.cascade(ctx, &cascadeRecord{
+		entityRef: getStringAttr(record.Change.NewImage, "entity_ref"),
+		parentRef: getStringAttr(record.Change.NewImage, "parent_ref"),
+		jobRef:    getStringAttr(record.Change.NewImage, "_cascade_job"),
+		uniquePKs: getStringListAttr(record.Change.NewImage, "_unique_pks"),
+		table:     table,
+		key:       ConvertStreamKey(record.Change.Keys),
+		ttl:       newTTL,
+		source:    record.EventSourceArn,
+	})
+}
 
-	h.logger.Info("processing cascade delete",
-		"entityRef", entityRef,
-		"parentRef", parentRef,
-		"ttl", newTTL,
-	)
+// resumedCascade returns the cascade to continue for a record of the
+// cascade job table, or nil if the record does not advance a checkpoint or
+// the checkpoint was superseded. Job records have no cursor.
+func (h *Handler) resumedCascade(ctx context.Context, record *events.DynamoDBEventRecord) (*cascadeRecord, error) {
+	if record.EventName != "INSERT" && record.EventName != "MODIFY" {
+		return nil, nil
+	}
+	image := record.Change.NewImage
+	entityRef := getStringAttr(image, "entity_ref")
+	cursor := getStringAttr(image, "cursor")
+	if entityRef == "" || cursor == "" || cursor == getStringAttr(record.Change.OldImage, "cursor") {
+		return nil, nil
+	}
 
-	// 1. Query all children (including already-deleted ones - idempotent)
-	children, err := h.store.QueryAllChildren(ctx, entityRef)
+	// Skip checkpoints that were superseded or whose entity was restored
+	cp, ok, err := h.store.CascadeCheckpoint(ctx, entityRef, getNumberAttr(image, "cascade_ttl"))
 	if err != nil {
-		return fmt.Errorf("query children: %w", err)
+		return nil, fmt.Errorf("read cascade checkpoint: %w", err)
+	}
+	if !ok || cp.Cursor != cursor {
+		h.logger.Info("skipping stale cascade checkpoint",
+			"entityRef", entityRef,
+		)
+		return nil, nil
 	}
+	return &cascadeRecord{
+		entityRef: cp.EntityRef,
+		parentRef: cp.ParentRef,
+		jobRef:    cp.JobRef,
+		table:     cp.TableName,
+		key:       cp.Key,
+		ttl:       cp.TTL,
+		cursor:    cp.Cursor,
+		resuming:  true,
+	}, nil
+}
 
-	h.logger.Info("found children to cascade",
-		"entityRef", entityRef,
-		"childCount", len(children),
+// cascade propagates the TTL of the entity of c to its children, its
+// relationship record and its unique constraints.
+func (h *Handler) cascade(ctx context.Context, c *cascadeRecord) error {
+	h.logger.Info("processing cascade delete",
+		"entityRef", c.entityRef,
+		"parentRef", c.parentRef,
+		"ttl", c.ttl,
+		"resuming", c.resuming,
 	)
 
-	// 2. Set same TTL on all children (triggers their cascade via stream)
-	for _, child := range children {
-		if err := h.store.SetTTLByKey(ctx, child.TableName, child.Key, newTTL); err != nil {
-			h.logger.Warn("failed to set TTL on child",
-				"child", child.Ref,
-				"error", err,
-			)
-			// Continue - idempotent, will retry
+	// Failed writes are retried, then collected so the rest still get the TTL
+	cascadeErr := &CascadeError{EntityRef: c.entityRef}
+	failRecord := func() bool {
+		return cascadeErr.Err != nil && h.opts.FailurePolicy == FailRecord
+	}
+
+	// 1. Query children a chunk at a time (including already-deleted ones -
+	//    idempotent) and 2. set the same TTL on them, which triggers their
+	//    cascade via stream. Stop early to checkpoint if the invocation is
+	//    running out of time or child budget; records that will fail are not
+	//    checkpointed, so their retry covers the failed chunk.
+	//
+	//    With a cascade job, a chunk's children are counted as pending
+	//    before they are deleted, so the job cannot finish before they do;
+	//    those this record did not delete are uncounted after.
+	next := c.cursor
+	processed := 0
+	margin := h.checkpointMargin(ctx)
+	for {
+		page, err := h.store.ListChildren(ctx, c.entityRef, store.ListChildrenOptions{
+			ChildFilter: store.ChildFilter{IncludeDeleted: true},
+			Limit:       int32(h.opts.ChunkSize),
+			Cursor:      next,
+		})
+		if err != nil {
+			return fmt.Errorf("query children: %w", err)
+		}
+		n := len(page.Children)
+		if n > 0 {
+			if _, err := h.updateJob(ctx, c.jobRef, store.CascadeJobUpdate{Pending: int64(n)}); err != nil {
+				return fmt.Errorf("update cascade job: %w", err)
+			}
+		}
+		failedBefore := len(cascadeErr.Children)
+		deleted := h.setChildTTLs(ctx, page.Children, c.ttl, c.jobRef, cascadeErr)
+		if n > 0 {
+			update := store.CascadeJobUpdate{
+				Pending:           int64(deleted - n),
+				ChildrenProcessed: int64(deleted),
+			}
+			if failed := len(cascadeErr.Children) - failedBefore; failed > 0 {
+				update.LastError = cascadeErr.Err.Error()
+				if h.opts.FailurePolicy == LogAndContinue {
+					update.Failures = int64(failed)
+				}
+			}
+			if _, err := h.updateJob(ctx, c.jobRef, update); err != nil {
+				return fmt.Errorf("update cascade job: %w", err)
+			}
+		}
+		processed += n
+		next = page.Cursor
+
+		if next == "" || failRecord() || h.yield(ctx, processed, margin) {
+			break
 		}
 	}
 
 	// 3. Set TTL on this entity's relationship record (as a child)
 	//    Uses parent_ref from stream record - no lookup needed!
-	if parentRef != "" {
-		if err := h.store.SetRelationshipTTL(ctx, entityRef, parentRef, newTTL); err != nil {
+	if c.parentRef != "" && !c.resuming {
+		err := h.retry(ctx, func() error {
+			return h.store.SetRelationshipTTL(ctx, c.entityRef, c.parentRef, c.ttl)
+		})
+		if err != nil {
 			h.logger.Warn("failed to set relationship TTL",
-				"entity", entityRef,
-				"parent", parentRef,
+				"entity", c.entityRef,
+				"parent", c.parentRef,
 				"error", err,
 			)
+			cascadeErr.Relationship = true
+			cascadeErr.Err = err
 		}
 	}
 
 	// 4. Set TTL on unique constraint records
-	for _, constraintPK := range uniquePKs {
-		if err := h.store.SetUniqueConstraintTTL(ctx, constraintPK, newTTL); err != nil {
-			h.logger.Warn("failed to set unique constraint TTL",
-				"pk", constraintPK,
+	if !c.resuming {
+		for _, constraintPK := range c.uniquePKs {
+			err := h.retry(ctx, func() error {
+				return h.store.SetUniqueConstraintTTL(ctx, constraintPK, c.ttl)
+			})
+			if err != nil {
+				h.logger.Warn("failed to set unique constraint TTL",
+					"pk", constraintPK,
+					"error", err,
+				)
+				cascadeErr.UniqueConstraints = append(cascadeErr.UniqueConstraints, constraintPK)
+				cascadeErr.Err = err
+			}
+		}
+	}
+
+	if failRecord() {
+		if _, err := h.updateJob(ctx, c.jobRef, store.CascadeJobUpdate{LastError: cascadeErr.Error()}); err != nil {
+			h.logger.Warn("failed to update cascade job",
+				"job", c.jobRef,
 				"error", err,
 			)
 		}
+		return cascadeErr
+	}
+
+	// 5. Record where the next invocation resumes, or mark the checkpoint
+	//    done. The checkpoint is kept in the cascade job table, so it
+	//    outlives the entity, and its write produces the stream record that
+	//    resumes the cascade.
+	if next != "" || c.resuming {
+		if c.table == "" {
+			return fmt.Errorf("checkpoint cascade of %s: no table in event source ARN %q", c.entityRef, c.source)
+		}
+		err := h.store.SetCascadeCheckpoint(ctx, &store.CascadeCheckpoint{
+			EntityRef: c.entityRef,
+			ParentRef: c.parentRef,
+			TableName: c.table,
+			Key:       c.key,
+			TTL:       c.ttl,
+			JobRef:    c.jobRef,
+			Cursor:    next,
+		}, c.cursor)
+		if errors.Is(err, store.ErrConcurrentModification) {
+			// Another run of this cascade checkpointed first, or the entity
+			// was deleted again; either way it is no longer ours to continue
+			h.logger.Info("cascade checkpoint superseded",
+				"entityRef", c.entityRef,
+			)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("checkpoint cascade: %w", err)
+		}
+	}
+
+	if next != "" {
+		h.logger.Info("cascade delete checkpointed",
+			"entityRef", c.entityRef,
+			"childrenProcessed", processed,
+		)
+		return nil
+	}
+
+	// 6. Count this entity as done on its cascade job, abandoned writes
+	//    included, and run the completion hook if that finished the job
+	if c.jobRef != "" {
+		update := store.CascadeJobUpdate{}
+		if cascadeErr.Relationship {
+			update.Failures++
+		}
+		update.Failures += int64(len(cascadeErr.UniqueConstraints))
+		if update.Failures > 0 {
+			update.LastError = cascadeErr.Error()
+		}
+		if c.table == "" {
+			return fmt.Errorf("update cascade job of %s: no table in event source ARN %q", c.entityRef, c.source)
+		}
+		if err := h.finishEntity(ctx, c.jobRef, c.table, c.key, update); err != nil {
+			return err
+		}
 	}
 
 	h.logger.Info("cascade delete completed",
-		"entityRef", entityRef,
-		"childrenProcessed", len(children),
-		"uniqueConstraints", len(uniquePKs),
+		"entityRef", c.entityRef,
+		"childrenProcessed", processed,
+		"uniqueConstraints", len(c.uniquePKs),
 	)
 
 	return nil
 }
 
+// finishEntity records on the job jobRef that the entity at key in table
+// has been processed, along with update. If that finishes the job, the
+// completion hook is run unless it already has.
+func (h *Handler) finishEntity(ctx context.Context, jobRef, table string, key store.PK, update store.CascadeJobUpdate) error {
+	update.Pending = -1
+	update.Done = &store.CascadeEntity{TableName: table, Key: key}
+	job, err := h.updateJob(ctx, jobRef, update)
+	if err != nil {
+		return fmt.Errorf("update cascade job: %w", err)
+	}
+	if job == nil || job.Status == store.CascadeRunning {
+		return nil
+	}
+
+	h.logger.Info("cascade job finished",
+		"job", job.EntityRef,
+		"status", job.Status,
+		"childrenProcessed", job.ChildrenProcessed,
+		"failures", job.Failures,
+	)
+	if h.opts.OnCascadeComplete == nil || job.Notified {
+		return nil
+	}
+	if err := h.opts.OnCascadeComplete(ctx, job); err != nil {
+		return fmt.Errorf("cascade completion hook: %w", err)
+	}
+	return h.retry(ctx, func() error {
+		return h.store.MarkCascadeJobNotified(ctx, jobRef)
+	})
+}
+
+// updateJob records progress on the cascade job jobRef and returns the job.
+// It returns nil without error if jobRef is empty or the job is gone, such
+// as after its root was restored.
+func (h *Handler) updateJob(ctx context.Context, jobRef string, update store.CascadeJobUpdate) (*store.CascadeJob, error) {
+	if jobRef == "" {
+		return nil, nil
+	}
+	var job *store.CascadeJob
+	err := h.retry(ctx, func() error {
+		var err error
+		job, err = h.store.UpdateCascadeJob(ctx, jobRef, update)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+	return job, err
+}
+
+// setChildTTLs sets ttl on children, Concurrency at a time and within the
+// rate limit, and records the children that still fail after retrying in
+// cascadeErr. With a cascade job, the children are stamped as part of it
+// and the number this call deleted is returned.
+func (h *Handler) setChildTTLs(ctx context.Context, children []store.ChildRef, ttl int64, jobRef string, cascadeErr *CascadeError) int {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, h.opts.Concurrency)
+		failed  []string
+		deleted atomic.Int64
+	)
+	for _, child := range children {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			applied, retried := false, false
+			err := h.retry(ctx, func() error {
+				if err := h.limiter.wait(ctx); err != nil {
+					return err
+				}
+				if jobRef == "" {
+					return h.store.SetTTLByKey(ctx, child.TableName, child.Key, ttl)
+				}
+				ok, stamped, err := h.store.SetCascadeTTL(ctx, child.TableName, child.Key, ttl, jobRef)
+				// A failed try may still have been applied, leaving the
+				// child stamped with this job. One stamped before the
+				// first try was counted by an earlier run of the record
+				applied = ok || (retried && stamped)
+				retried = retried || err != nil
+				return err
+			})
+			if applied {
+				deleted.Add(1)
+			}
+			if err != nil {
+				h.logger.Warn("failed to set TTL on child",
+					"child", child.Ref,
+					"error", err,
+				)
+				mu.Lock()
+				failed = append(failed, child.Ref)
+				cascadeErr.Err = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Strings(failed)
+	cascadeErr.Children = append(cascadeErr.Children, failed...)
+	return int(deleted.Load())
+}
+
+// yield reports whether a cascade that has processed n children in this
+// invocation should checkpoint and leave the rest to a later invocation,
+// given the record's checkpoint margin.
+func (h *Handler) yield(ctx context.Context, n int, margin time.Duration) bool {
+	if !h.checkpoints {
+		return false
+	}
+	if h.opts.MaxChildrenPerRecord > 0 && n >= h.opts.MaxChildrenPerRecord {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && margin >= 0 && time.Until(deadline) < margin
+}
+
+// checkpointMargin returns the checkpoint margin of a record starting now:
+// Options.CheckpointMargin if set, and otherwise a quarter of the time left
+// before the context's deadline, so short function timeouts still process
+// several chunks per invocation.
+func (h *Handler) checkpointMargin(ctx context.Context) time.Duration {
+	if h.opts.CheckpointMargin != 0 {
+		return h.opts.CheckpointMargin
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	return time.Until(deadline) / 4
+}
+
+// retry calls fn until it succeeds or has been retried MaxRetries times,
+// with exponential backoff, and returns its last error.
+func (h *Handler) retry(ctx context.Context, fn func() error) error {
+	delay := h.opts.RetryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == h.opts.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, h.opts.RetryMaxDelay)
+	}
+}
+
 // getStringAttr extracts a string attribute from a DynamoDB stream image.
 func getStringAttr(image map[string]events.DynamoDBAttributeValue, key string) string {
 	if v, ok := image[key]; ok {
@@ -160,6 +672,18 @@ func getStringListAttr(image map[string]events.DynamoDBAttributeValue, key strin
 	return nil
 }
 
+// tableFromStreamARN returns the table name in a DynamoDB stream ARN,
+// arn:aws:dynamodb:region:account:table/name/stream/label, or empty if arn
+// is not one.
+func tableFromStreamARN(arn string) string {
+	_, resource, ok := strings.Cut(arn, ":table/")
+	if !ok {
+		return ""
+	}
+	table, _, _ := strings.Cut(resource, "/")
+	return table
+}
+
 // ConvertStreamKey converts a DynamoDB stream key to a store.PK.
 // Use this when you need to convert keys from stream records to store operations.
 func ConvertStreamKey(streamKey map[string]events.DynamoDBAttributeValue) store.PK {
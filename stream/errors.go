@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CascadeError is returned for a cascade record whose TTL writes failed
+// after retrying, under the FailRecord policy. It lists what did not get
+// the TTL and wraps the last error.
+type CascadeError struct {
+	// EntityRef is the deleted entity the record is for.
+	EntityRef string
+
+	// Children are the references of children whose TTL was not set.
+	Children []string
+
+	// Relationship is true if the entity's own relationship record was not
+	// updated.
+	Relationship bool
+
+	// UniqueConstraints are the keys of unique constraints whose TTL was
+	// not set.
+	UniqueConstraints []string
+
+	// Err is the last error.
+	Err error
+}
+
+func (e *CascadeError) Error() string {
+	var parts []string
+	if len(e.Children) > 0 {
+		parts = append(parts, "children "+strings.Join(e.Children, ", "))
+	}
+	if e.Relationship {
+		parts = append(parts, "relationship record")
+	}
+	if len(e.UniqueConstraints) > 0 {
+		parts = append(parts, "unique constraints "+strings.Join(e.UniqueConstraints, ", "))
+	}
+	return fmt.Sprintf("cascade %s: TTL not set on %s: %v", e.EntityRef, strings.Join(parts, "; "), e.Err)
+}
+
+func (e *CascadeError) Unwrap() error {
+	return e.Err
+}
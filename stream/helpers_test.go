@@ -3,6 +3,7 @@ package stream
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 )
@@ -437,3 +438,58 @@ func BenchmarkGetStringListAttr(b *testing.B) {
 		getStringListAttr(image, "_unique_pks")
 	}
 }
+
+func TestTableFromStreamARN(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:dynamodb:eu-west-1:123456789012:table/nodes/stream/2024-01-01T00:00:00.000", "nodes"},
+		{"arn:aws:dynamodb:eu-west-1:123456789012:table/nodes", "nodes"},
+		{"arn:aws:sqs:eu-west-1:123456789012:queue", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := tableFromStreamARN(tt.arn); got != tt.want {
+			t.Errorf("tableFromStreamARN(%q) = %q, expected %q", tt.arn, got, tt.want)
+		}
+	}
+}
+
+func TestCheckpointMargin(t *testing.T) {
+	// A 3s deadline, Lambda's default function timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	h := NewHandlerWithOptions(nil, Options{})
+	h.checkpoints = true
+	margin := h.checkpointMargin(ctx)
+	if margin <= 0 || margin > 750*time.Millisecond {
+		t.Errorf("expected a quarter of the time left by default, got %v", margin)
+	}
+	if h.yield(ctx, 1, margin) {
+		t.Error("expected the default margin to process another chunk")
+	}
+	if margin := h.checkpointMargin(context.Background()); margin != 0 {
+		t.Errorf("expected no margin without a deadline, got %v", margin)
+	}
+	if NewHandlerWithOptions(nil, Options{CheckpointMargin: 10 * time.Second}).yield(ctx, 1, 10*time.Second) {
+		t.Error("expected no checkpoints unless the store records them")
+	}
+
+	for _, tt := range []struct {
+		margin time.Duration
+		yield  bool
+	}{
+		{10 * time.Second, true},
+		{time.Second, false},
+		{-1, false},
+	} {
+		h := NewHandlerWithOptions(nil, Options{CheckpointMargin: tt.margin})
+		h.checkpoints = true
+		if got := h.yield(ctx, 1, h.checkpointMargin(ctx)); got != tt.yield {
+			t.Errorf("CheckpointMargin %v: yield = %v, expected %v", tt.margin, got, tt.yield)
+		}
+	}
+}
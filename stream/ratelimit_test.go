@@ -0,0 +1,43 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Nil(t *testing.T) {
+	l := newRateLimiter(0, 1)
+	if l != nil {
+		t.Fatal("expected no limiter for a zero rate")
+	}
+	if err := l.wait(context.Background()); err != nil {
+		t.Errorf("expected nil limiter to allow, got %v", err)
+	}
+}
+
+func TestRateLimiter_Rate(t *testing.T) {
+	l := newRateLimiter(200, 2)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	// The burst is free; the other 4 take 5ms each
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected about 20ms, took %v", elapsed)
+	}
+}
+
+func TestRateLimiter_Cancel(t *testing.T) {
+	l := newRateLimiter(1, 1)
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
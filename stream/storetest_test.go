@@ -0,0 +1,699 @@
+package stream_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/jacentio/trellis/store"
+	"github.com/jacentio/trellis/storetest"
+	"github.com/jacentio/trellis/stream"
+)
+
+// node is a generic test entity stored in the "nodes" table.
+type node struct {
+	ID       string
+	ParentID string
+	Slug     string
+}
+
+func (n node) TableName() string  { return "nodes" }
+func (n node) EntityType() string { return "node" }
+func (n node) EntityRef() string  { return "node#" + n.ID }
+func (n node) GetKey() store.PK {
+	return store.PK{"id": &types.AttributeValueMemberS{Value: n.ID}}
+}
+
+func (n node) ParentRef() string {
+	if n.ParentID == "" {
+		return ""
+	}
+	return "node#" + n.ParentID
+}
+
+func (n node) ParentCheck() *store.ConditionCheck {
+	if n.ParentID == "" {
+		return nil
+	}
+	return &store.ConditionCheck{
+		TableName: "nodes",
+		Key:       store.PK{"id": &types.AttributeValueMemberS{Value: n.ParentID}},
+	}
+}
+
+func (n node) UniqueFields() map[string]string {
+	if n.Slug == "" {
+		return nil
+	}
+	return map[string]string{"slug": n.Slug}
+}
+
+// drain feeds stream records to the handler until no more are produced.
+func drain(t *testing.T, h *stream.Handler, db *storetest.DB) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		event := db.DrainStream()
+		if len(event.Records) == 0 {
+			return
+		}
+		if err := h.HandleCascadeDelete(context.Background(), event); err != nil {
+			t.Fatalf("handle cascade: %v", err)
+		}
+	}
+	t.Fatal("stream did not settle")
+}
+
+func TestHandler_CascadeDeleteWithStoretest(t *testing.T) {
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 4
+	db := storetest.New()
+	db.MustCreateTable(storetest.EntityTable("nodes"))
+	db.MustCreateTable(storetest.RelationshipTable(cfg.RelationshipTable))
+	db.MustCreateTable(storetest.UniqueTable(cfg.UniqueTable))
+	s := store.New(db, cfg)
+	h := stream.NewHandler(s, nil)
+	ctx := context.Background()
+
+	nodes := []node{
+		{ID: "root"},
+		{ID: "a", ParentID: "root", Slug: "a"},
+		{ID: "b", ParentID: "root", Slug: "b"},
+		{ID: "a1", ParentID: "a", Slug: "a1"},
+		{ID: "other"},
+	}
+	for _, n := range nodes {
+		if err := s.Create(ctx, n, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: n.ID}}); err != nil {
+			t.Fatalf("create %s: %v", n.ID, err)
+		}
+	}
+	drain(t, h, db)
+
+	if err := s.Delete(ctx, nodes[0], store.DeleteOptions{Cascade: true}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+	drain(t, h, db)
+
+	for _, item := range db.Items("nodes") {
+		id := item["id"].(*types.AttributeValueMemberS).Value
+		if deleted := store.IsDeleted(item); deleted != (id != "other") {
+			t.Errorf("node %s: expected deleted=%v", id, id != "other")
+		}
+	}
+	for _, item := range db.Items(cfg.RelationshipTable) {
+		if !store.IsDeleted(item) {
+			t.Errorf("expected relationship %v to be deleted", item["child_ref"])
+		}
+	}
+	if uniques := db.Items(cfg.UniqueTable); len(uniques) != 3 {
+		t.Errorf("expected 3 unique constraints, got %d", len(uniques))
+	} else {
+		for _, item := range uniques {
+			if !store.IsDeleted(item) {
+				t.Errorf("expected unique constraint %v to be deleted", item["field_value"])
+			}
+		}
+	}
+}
+
+func TestHandler_CascadeDeleteKeepsRetention(t *testing.T) {
+	cfg := store.DefaultConfig()
+	db := storetest.New()
+	db.MustCreateTable(storetest.EntityTable("nodes"))
+	db.MustCreateTable(storetest.RelationshipTable(cfg.RelationshipTable))
+	db.MustCreateTable(storetest.UniqueTable(cfg.UniqueTable))
+	s := store.New(db, cfg)
+	h := stream.NewHandler(s, nil)
+	ctx := context.Background()
+
+	for _, n := range []node{{ID: "root"}, {ID: "a", ParentID: "root"}, {ID: "a1", ParentID: "a"}} {
+		if err := s.Create(ctx, n, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: n.ID}}); err != nil {
+			t.Fatalf("create %s: %v", n.ID, err)
+		}
+	}
+	drain(t, h, db)
+
+	if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true, Retention: time.Hour}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+	drain(t, h, db)
+
+	// Every node is hidden, and none is purged before the retention period
+	for _, item := range db.Items("nodes") {
+		if !store.IsDeleted(item) {
+			t.Errorf("expected node %v to be deleted", item["id"])
+		}
+	}
+	if n := db.SweepTTL(time.Now()); n != 0 {
+		t.Errorf("expected nothing purged yet, got %d", n)
+	}
+	trash := 0
+	for _, err := range s.ListTrash(ctx, &store.ScanInput{TableName: "nodes"}) {
+		if err != nil {
+			t.Fatalf("list trash: %v", err)
+		}
+		trash++
+	}
+	if trash != 3 {
+		t.Errorf("expected 3 nodes in trash, got %d", trash)
+	}
+}
+
+func TestHandler_CascadeDeleteBatchReportsFirstFailure(t *testing.T) {
+	cfg := store.DefaultConfig()
+	db := storetest.New()
+	db.MustCreateTable(storetest.EntityTable("nodes"))
+	db.MustCreateTable(storetest.RelationshipTable(cfg.RelationshipTable))
+	db.MustCreateTable(storetest.UniqueTable(cfg.UniqueTable))
+	s := store.New(db, cfg)
+	h := stream.NewHandler(s, nil)
+	ctx := context.Background()
+
+	for _, n := range []node{{ID: "r1"}, {ID: "a", ParentID: "r1"}, {ID: "r2"}, {ID: "b", ParentID: "r2"}} {
+		if err := s.Create(ctx, n, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: n.ID}}); err != nil {
+			t.Fatalf("create %s: %v", n.ID, err)
+		}
+	}
+	drain(t, h, db)
+
+	for _, id := range []string{"r1", "r2"} {
+		if err := s.Delete(ctx, node{ID: id}, store.DeleteOptions{Cascade: true}); err != nil {
+			t.Fatalf("delete %s: %v", id, err)
+		}
+	}
+	event := db.DrainStream()
+	if len(event.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(event.Records))
+	}
+
+	// The first record fails; the second must wait for its retry
+	db.SetInterceptor(func(_ context.Context, op string, _ any) error {
+		if op == "Query" {
+			db.SetInterceptor(nil)
+			return errors.New("throttled")
+		}
+		return nil
+	})
+	resp, err := h.HandleCascadeDeleteBatch(ctx, event)
+	if err != nil {
+		t.Fatalf("handle batch: %v", err)
+	}
+	if len(resp.BatchItemFailures) != 1 || resp.BatchItemFailures[0].ItemIdentifier != event.Records[0].Change.SequenceNumber {
+		t.Fatalf("expected the first record to be reported, got %+v", resp.BatchItemFailures)
+	}
+	if store.IsDeleted(db.Item("nodes", node{ID: "b"}.GetKey())) {
+		t.Error("expected the record after the failure to be skipped")
+	}
+
+	resp, err = h.HandleCascadeDeleteBatch(ctx, event)
+	if err != nil || len(resp.BatchItemFailures) != 0 {
+		t.Fatalf("expected retry to succeed, got %+v (err %v)", resp.BatchItemFailures, err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if !store.IsDeleted(db.Item("nodes", node{ID: id}.GetKey())) {
+			t.Errorf("expected %s to be deleted", id)
+		}
+	}
+}
+
+func TestHandler_CascadeDeleteFailurePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   stream.FailurePolicy
+		failures int
+		wantErr  bool
+	}{
+		{"transient failure is retried", stream.FailRecord, 2, false},
+		{"persistent failure fails the record", stream.FailRecord, 100, true},
+		{"persistent failure is logged", stream.LogAndContinue, 100, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := store.DefaultConfig()
+			db := storetest.New()
+			db.MustCreateTable(storetest.EntityTable("nodes"))
+			db.MustCreateTable(storetest.RelationshipTable(cfg.RelationshipTable))
+			db.MustCreateTable(storetest.UniqueTable(cfg.UniqueTable))
+			s := store.New(db, cfg)
+			h := stream.NewHandlerWithOptions(s, stream.Options{
+				FailurePolicy:  tc.policy,
+				RetryBaseDelay: time.Millisecond,
+			})
+			ctx := context.Background()
+
+			for _, n := range []node{{ID: "root"}, {ID: "a", ParentID: "root"}, {ID: "b", ParentID: "root"}} {
+				if err := s.Create(ctx, n, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: n.ID}}); err != nil {
+					t.Fatalf("create %s: %v", n.ID, err)
+				}
+			}
+			drain(t, h, db)
+			if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true}); err != nil {
+				t.Fatalf("delete root: %v", err)
+			}
+
+			// Setting the TTL on child a is throttled
+			var failures atomic.Int32
+			failures.Store(int32(tc.failures))
+			db.SetInterceptor(func(_ context.Context, op string, input any) error {
+				if update, ok := input.(*dynamodb.UpdateItemInput); ok && op == "UpdateItem" {
+					if id, ok := update.Key["id"].(*types.AttributeValueMemberS); ok && id.Value == "a" && failures.Add(-1) >= 0 {
+						return errors.New("throttled")
+					}
+				}
+				return nil
+			})
+			err := h.HandleCascadeDelete(ctx, db.DrainStream())
+			db.SetInterceptor(nil)
+
+			var cascadeErr *stream.CascadeError
+			if !tc.wantErr {
+				if err != nil {
+					t.Fatalf("expected success, got %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &cascadeErr) {
+				t.Fatalf("expected CascadeError, got %v", err)
+			}
+			if cascadeErr.EntityRef != "node#root" || len(cascadeErr.Children) != 1 || cascadeErr.Children[0] != "node#a" {
+				t.Errorf("expected node#a to be reported for node#root, got %+v", cascadeErr)
+			}
+			if !store.IsDeleted(db.Item("nodes", node{ID: "b"}.GetKey())) {
+				t.Error("expected the other child to be deleted")
+			}
+		})
+	}
+}
+
+func TestHandler_CascadeDeleteConcurrency(t *testing.T) {
+	cfg := store.DefaultConfig()
+	db := storetest.New()
+	db.MustCreateTable(storetest.EntityTable("nodes"))
+	db.MustCreateTable(storetest.RelationshipTable(cfg.RelationshipTable))
+	db.MustCreateTable(storetest.UniqueTable(cfg.UniqueTable))
+	s := store.New(db, cfg)
+	h := stream.NewHandlerWithOptions(s, stream.Options{Concurrency: 4})
+	ctx := context.Background()
+
+	if err := s.Create(ctx, node{ID: "root"}, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "root"}}); err != nil {
+		t.Fatalf("create root: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("c%02d", i)
+		if err := s.Create(ctx, node{ID: id, ParentID: "root"}, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: id}}); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+	db.DrainStream()
+	if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+
+	var inFlight, peak atomic.Int32
+	db.SetInterceptor(func(_ context.Context, op string, _ any) error {
+		if op == "UpdateItem" {
+			n := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			inFlight.Add(-1)
+		}
+		return nil
+	})
+	if err := h.HandleCascadeDelete(ctx, db.DrainStream()); err != nil {
+		t.Fatalf("handle cascade: %v", err)
+	}
+	db.SetInterceptor(nil)
+
+	if p := peak.Load(); p < 2 || p > 4 {
+		t.Errorf("expected 2 to 4 children updated at once, got %d", p)
+	}
+	for i := 0; i < 20; i++ {
+		if key := (node{ID: fmt.Sprintf("c%02d", i)}).GetKey(); !store.IsDeleted(db.Item("nodes", key)) {
+			t.Errorf("expected c%02d to be deleted", i)
+		}
+	}
+}
+
+// newCheckpointStore returns a store that checkpoints cascades, with root
+// and n children c00, c01, ... under it.
+func newCheckpointStore(t *testing.T, n int) (*store.Store, *storetest.DB) {
+	t.Helper()
+	cfg := store.DefaultConfig()
+	cfg.NumShards = 4
+	cfg.CheckpointCascades = true
+	db := storetest.New()
+	db.MustCreateTable(storetest.EntityTable("nodes"))
+	db.MustCreateTable(storetest.RelationshipTable(cfg.RelationshipTable))
+	db.MustCreateTable(storetest.UniqueTable(cfg.UniqueTable))
+	db.MustCreateTable(storetest.CascadeJobTable(cfg.CascadeJobTable))
+	s := store.New(db, cfg)
+
+	nodes := []node{{ID: "root"}}
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, node{ID: fmt.Sprintf("c%02d", i), ParentID: "root"})
+	}
+	for _, n := range nodes {
+		if err := s.Create(context.Background(), n, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: n.ID}}); err != nil {
+			t.Fatalf("create %s: %v", n.ID, err)
+		}
+	}
+	db.DrainStream()
+	return s, db
+}
+
+// deletedChildren returns how many of the n children of newCheckpointStore
+// have a TTL.
+func deletedChildren(db *storetest.DB, n int) int {
+	deleted := 0
+	for i := 0; i < n; i++ {
+		if _, ok := db.Item("nodes", node{ID: fmt.Sprintf("c%02d", i)}.GetKey())["ttl"]; ok {
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// checkpointRecord returns the last record of event that advances a
+// cascade checkpoint, or nil.
+func checkpointRecord(event events.DynamoDBEvent) *events.DynamoDBEventRecord {
+	var resume *events.DynamoDBEventRecord
+	for i := range event.Records {
+		if _, ok := event.Records[i].Change.NewImage["cursor"]; ok {
+			resume = &event.Records[i]
+		}
+	}
+	return resume
+}
+
+func TestHandler_CascadeDeleteCheckpoints(t *testing.T) {
+	s, db := newCheckpointStore(t, 10)
+	h := stream.NewHandlerWithOptions(s, stream.Options{ChunkSize: 2, MaxChildrenPerRecord: 3})
+	ctx := context.Background()
+
+	if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+
+	// The first invocation stops after a few chunks and checkpoints
+	if err := h.HandleCascadeDelete(ctx, db.DrainStream()); err != nil {
+		t.Fatalf("handle cascade: %v", err)
+	}
+	if n := deletedChildren(db, 10); n < 3 || n == 10 {
+		t.Fatalf("expected a partial cascade, got %d children deleted", n)
+	}
+	event := db.DrainStream()
+	resume := checkpointRecord(event)
+	if resume == nil {
+		t.Fatal("expected a checkpoint record")
+	}
+
+	// Later invocations resume from the checkpoint until done
+	if err := h.HandleCascadeDelete(ctx, event); err != nil {
+		t.Fatalf("handle cascade: %v", err)
+	}
+	drain(t, h, db)
+	if n := deletedChildren(db, 10); n != 10 {
+		t.Errorf("expected all 10 children deleted, got %d", n)
+	}
+	if _, ok, err := s.CascadeCheckpoint(ctx, "node#root", getTTL(t, db, node{ID: "root"})); ok || err != nil {
+		t.Errorf("expected the checkpoint to be done, got ok=%v (err %v)", ok, err)
+	}
+
+	// A superseded checkpoint is not resumed
+	var writes atomic.Int32
+	db.SetInterceptor(func(_ context.Context, op string, _ any) error {
+		if op == "UpdateItem" || op == "PutItem" {
+			writes.Add(1)
+		}
+		return nil
+	})
+	defer db.SetInterceptor(nil)
+	if err := h.HandleCascadeDelete(ctx, events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{*resume}}); err != nil {
+		t.Fatalf("handle stale checkpoint: %v", err)
+	}
+	if n := writes.Load(); n != 0 {
+		t.Errorf("expected no writes for a stale checkpoint, got %d", n)
+	}
+}
+
+func TestHandler_CascadeDeleteCheckpointAfterPurge(t *testing.T) {
+	s, db := newCheckpointStore(t, 5)
+	h := stream.NewHandlerWithOptions(s, stream.Options{ChunkSize: 1, MaxChildrenPerRecord: 2})
+	ctx := context.Background()
+
+	if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+	if err := h.HandleCascadeDelete(ctx, db.DrainStream()); err != nil {
+		t.Fatalf("handle cascade: %v", err)
+	}
+	if n := deletedChildren(db, 5); n != 2 {
+		t.Fatalf("expected 2 children deleted, got %d", n)
+	}
+
+	// DynamoDB purges the root before the cascade resumes
+	if _, err := db.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String("nodes"),
+		Key:       node{ID: "root"}.GetKey(),
+	}); err != nil {
+		t.Fatalf("purge root: %v", err)
+	}
+	drain(t, h, db)
+	if n := deletedChildren(db, 5); n != 5 {
+		t.Errorf("expected all 5 children deleted, got %d", n)
+	}
+}
+
+func TestHandler_CascadeDeleteCheckpointAfterRestore(t *testing.T) {
+	s, db := newCheckpointStore(t, 2)
+	h := stream.NewHandlerWithOptions(s, stream.Options{ChunkSize: 1, MaxChildrenPerRecord: 1})
+	ctx := context.Background()
+
+	if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true, Retention: time.Hour}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+	if err := h.HandleCascadeDelete(ctx, db.DrainStream()); err != nil {
+		t.Fatalf("handle cascade: %v", err)
+	}
+
+	// Restoring the root stops the cascade before the second child
+	if err := s.Restore(ctx, node{ID: "root"}, 2, store.RestoreOptions{}); err != nil {
+		t.Fatalf("restore root: %v", err)
+	}
+	drain(t, h, db)
+	if n := deletedChildren(db, 2); n != 1 {
+		t.Errorf("expected the second child to survive the restored cascade, got %d deleted", n)
+	}
+}
+
+// getTTL returns the TTL of a node.
+func getTTL(t *testing.T, db *storetest.DB, n node) int64 {
+	t.Helper()
+	v, ok := db.Item("nodes", n.GetKey())["ttl"].(*types.AttributeValueMemberN)
+	if !ok {
+		t.Fatalf("expected %s to have a TTL", n.ID)
+	}
+	ttl, err := strconv.ParseInt(v.Value, 10, 64)
+	if err != nil {
+		t.Fatalf("parse ttl: %v", err)
+	}
+	return ttl
+}
+
+// newJobStore returns a store tracking cascade jobs over a tree of nodes:
+// root -> a, b; a -> a1.
+func newJobStore(t *testing.T) (*store.Store, *storetest.DB) {
+	t.Helper()
+	cfg := store.DefaultConfig()
+	cfg.TrackCascadeJobs = true
+	db := storetest.New()
+	db.MustCreateTable(storetest.EntityTable("nodes"))
+	db.MustCreateTable(storetest.RelationshipTable(cfg.RelationshipTable))
+	db.MustCreateTable(storetest.UniqueTable(cfg.UniqueTable))
+	db.MustCreateTable(storetest.CascadeJobTable(cfg.CascadeJobTable))
+	s := store.New(db, cfg)
+
+	for _, n := range []node{{ID: "root"}, {ID: "a", ParentID: "root"}, {ID: "b", ParentID: "root"}, {ID: "a1", ParentID: "a"}} {
+		if err := s.Create(context.Background(), n, map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: n.ID}}); err != nil {
+			t.Fatalf("create %s: %v", n.ID, err)
+		}
+	}
+	db.DrainStream()
+	return s, db
+}
+
+func TestHandler_CascadeJob(t *testing.T) {
+	s, db := newJobStore(t)
+	ctx := context.Background()
+
+	var notified []*store.CascadeJob
+	hookErr := errors.New("notify failed")
+	h := stream.NewHandlerWithOptions(s, stream.Options{
+		ChunkSize:            1,
+		MaxChildrenPerRecord: 1,
+		OnCascadeComplete: func(_ context.Context, job *store.CascadeJob) error {
+			notified = append(notified, job)
+			if len(notified) == 1 {
+				return hookErr
+			}
+			return nil
+		},
+	})
+
+	if _, err := s.CascadeStatus(ctx, "node#root"); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected no job before the delete, got %v", err)
+	}
+	if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+	job, err := s.CascadeStatus(ctx, "node#root")
+	if err != nil || job.Status != store.CascadeRunning || job.Pending != 1 || job.StartedAt.IsZero() {
+		t.Fatalf("expected a running job, got %+v (err %v)", job, err)
+	}
+
+	// A failed hook fails the record and runs again on its retry
+	for i := 0; i < 20; i++ {
+		event := db.DrainStream()
+		if len(event.Records) == 0 {
+			break
+		}
+		err := h.HandleCascadeDelete(ctx, event)
+		if errors.Is(err, hookErr) {
+			err = h.HandleCascadeDelete(ctx, event)
+		}
+		if err != nil {
+			t.Fatalf("handle cascade: %v", err)
+		}
+	}
+
+	job, err = s.CascadeStatus(ctx, "node#root")
+	if err != nil {
+		t.Fatalf("cascade status: %v", err)
+	}
+	if job.Status != store.CascadeCompleted || job.Pending != 0 || job.ChildrenProcessed != 3 || job.FinishedAt.IsZero() || !job.Notified {
+		t.Errorf("expected a completed job with 3 children processed, got %+v", job)
+	}
+	if len(notified) != 2 || notified[1].Status != store.CascadeCompleted {
+		t.Errorf("expected the hook to run twice for the completed job, got %d calls", len(notified))
+	}
+	for _, item := range db.Items("nodes") {
+		if !store.IsDeleted(item) {
+			t.Errorf("expected node %v to be deleted", item["id"])
+		}
+		if _, ok := item["_cascade_job"]; ok {
+			t.Errorf("expected node %v to be unstamped", item["id"])
+		}
+	}
+}
+
+func TestHandler_CascadeJobRetriedRecord(t *testing.T) {
+	for _, policy := range []stream.FailurePolicy{stream.FailRecord, stream.LogAndContinue} {
+		s, db := newJobStore(t)
+		h := stream.NewHandlerWithOptions(s, stream.Options{
+			FailurePolicy:  policy,
+			MaxRetries:     -1,
+			RetryBaseDelay: time.Millisecond,
+		})
+		ctx := context.Background()
+
+		if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true}); err != nil {
+			t.Fatalf("delete root: %v", err)
+		}
+
+		// Setting the TTL on child b fails once
+		var failures atomic.Int32
+		failures.Store(1)
+		db.SetInterceptor(func(_ context.Context, op string, input any) error {
+			if update, ok := input.(*dynamodb.UpdateItemInput); ok && op == "UpdateItem" {
+				if id, ok := update.Key["id"].(*types.AttributeValueMemberS); ok && id.Value == "b" && failures.Add(-1) >= 0 {
+					return errors.New("throttled")
+				}
+			}
+			return nil
+		})
+		event := db.DrainStream()
+		err := h.HandleCascadeDelete(ctx, event)
+		db.SetInterceptor(nil)
+		if policy == stream.FailRecord {
+			if err == nil {
+				t.Fatal("expected the record to fail")
+			}
+			job, err := s.CascadeStatus(ctx, "node#root")
+			if err != nil || job.Status != store.CascadeRunning || job.LastError == "" {
+				t.Errorf("expected a running job with the error, got %+v (err %v)", job, err)
+			}
+			if err := h.HandleCascadeDelete(ctx, event); err != nil {
+				t.Fatalf("retry: %v", err)
+			}
+		} else if err != nil {
+			t.Fatalf("handle cascade: %v", err)
+		}
+		drain(t, h, db)
+
+		// Retrying counts every child once; abandoned writes fail the job
+		want := store.CascadeJob{Status: store.CascadeCompleted, ChildrenProcessed: 3}
+		if policy == stream.LogAndContinue {
+			want = store.CascadeJob{Status: store.CascadeFailed, ChildrenProcessed: 2, Failures: 1}
+		}
+		job, err := s.CascadeStatus(ctx, "node#root")
+		if err != nil {
+			t.Fatalf("cascade status: %v", err)
+		}
+		if job.Status != want.Status || job.ChildrenProcessed != want.ChildrenProcessed || job.Failures != want.Failures || job.Pending != 0 {
+			t.Errorf("policy %v: expected %+v, got %+v", policy, want, job)
+		}
+	}
+}
+
+func TestHandler_CascadeJobChildDeletedBetweenTries(t *testing.T) {
+	s, db := newJobStore(t)
+	h := stream.NewHandlerWithOptions(s, stream.Options{RetryBaseDelay: time.Millisecond})
+	ctx := context.Background()
+
+	if err := s.Delete(ctx, node{ID: "root"}, store.DeleteOptions{Cascade: true}); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+
+	// Setting the TTL on child b fails once, and b is deleted outside the
+	// cascade before the retry
+	var failures atomic.Int32
+	failures.Store(1)
+	db.SetInterceptor(func(ctx context.Context, op string, input any) error {
+		if update, ok := input.(*dynamodb.UpdateItemInput); ok && op == "UpdateItem" {
+			if id, ok := update.Key["id"].(*types.AttributeValueMemberS); ok && id.Value == "b" && failures.Add(-1) >= 0 {
+				if err := s.Delete(ctx, node{ID: "b", ParentID: "root"}, store.DeleteOptions{}); err != nil {
+					t.Errorf("delete b: %v", err)
+				}
+				return errors.New("throttled")
+			}
+		}
+		return nil
+	})
+	err := h.HandleCascadeDelete(ctx, db.DrainStream())
+	db.SetInterceptor(nil)
+	if err != nil {
+		t.Fatalf("handle cascade: %v", err)
+	}
+	drain(t, h, db)
+
+	// b was not deleted by the job, so no record of it finishes the job
+	job, err := s.CascadeStatus(ctx, "node#root")
+	if err != nil {
+		t.Fatalf("cascade status: %v", err)
+	}
+	if job.Status != store.CascadeCompleted || job.ChildrenProcessed != 2 || job.Pending != 0 {
+		t.Errorf("expected a completed job with 2 children, got %+v", job)
+	}
+}